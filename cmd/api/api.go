@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -13,11 +14,17 @@ import (
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/routes"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
-	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
 )
 
 type config struct {
 	addr string
+	// mediaGCInterval is how often unreferenced uploads are removed; 0,
+	// the default, leaves it to POST /media/gc.
+	mediaGCInterval time.Duration
+	// priceScheduleInterval is how often scheduled prices are applied and
+	// reverted; 0 turns scheduled prices off.
+	priceScheduleInterval time.Duration
 }
 
 type application struct {
@@ -31,6 +38,8 @@ func (app *application) mount() *chi.Mux {
 	router.Use(middleware.Logger)
 
 	service := services.New(app.db)
+	router.Use(middlewares.ActiveSession(service.UserSession))
+
 	routes.GenerateEntityRoutes(router, service)
 	routes.GenerateProductRoutes(router, service)
 	routes.GenerateCategoryRoutes(router, service)
@@ -42,9 +51,23 @@ func (app *application) mount() *chi.Mux {
 	routes.GenerateArticleRoutes(router, service)
 	routes.GenerateInvoiceRoutes(router, service)
 	routes.GeneratePersonRoutes(router, service)
-	router.Post("/upload-file", func(w http.ResponseWriter, r *http.Request) {
-		_ = utils.Uploader(w, r)
-	})
+	routes.GenerateUserRoutes(router, service)
+	routes.GenerateAuditRoutes(router, service)
+	routes.GenerateFileRoutes(router, service)
+	routes.GenerateMediaRoutes(router, service)
+	routes.GenerateVehicleRoutes(router, service)
+	routes.GenerateVariantRoutes(router, service)
+	routes.GenerateReviewRoutes(router, service)
+	routes.GenerateQuestionRoutes(router, service)
+	routes.GenerateStorefrontRoutes(router, service)
+
+	if app.config.mediaGCInterval > 0 {
+		go service.RunMediaGC(app.config.mediaGCInterval)
+	}
+
+	if app.config.priceScheduleInterval > 0 {
+		go service.RunPriceSchedules(app.config.priceScheduleInterval)
+	}
 
 	return router
 }
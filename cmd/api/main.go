@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -9,8 +10,20 @@ import (
 )
 
 func main() {
+	mediaGCInterval, err := time.ParseDuration(env.GetString("MEDIA_GC_INTERVAL", "0"))
+	if err != nil {
+		log.Fatalf("MEDIA_GC_INTERVAL: %v", err)
+	}
+
+	priceScheduleInterval, err := time.ParseDuration(env.GetString("PRICE_SCHEDULE_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("PRICE_SCHEDULE_INTERVAL: %v", err)
+	}
+
 	cfg := &config{
-		addr: env.GetString("ADDR", "8080"),
+		addr:                  env.GetString("ADDR", "8080"),
+		mediaGCInterval:       mediaGCInterval,
+		priceScheduleInterval: priceScheduleInterval,
 	}
 	app := &application{
 		config: *cfg,
@@ -21,7 +34,7 @@ func main() {
 	mux := app.mount()
 	defer app.db.Close()
 
-	err := app.run(mux)
+	err = app.run(mux)
 	if err != nil {
 		log.Panicln(err.Error())
 	}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS otp_codes CASCADE;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS fk_users_person;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS person_id;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS phone_number;
@@ -0,0 +1,20 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS phone_number text UNIQUE;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS person_id uuid;
+
+ALTER TABLE IF EXISTS users
+    ADD CONSTRAINT fk_users_person FOREIGN KEY (person_id) REFERENCES persons (id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS otp_codes (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    phone_number text NOT NULL,
+    code_hash text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    consumed_at timestamptz,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_otp_codes_phone_number ON otp_codes (phone_number, created_at DESC);
@@ -0,0 +1,8 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS verification_token_expires;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS verification_token;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS email_verified;
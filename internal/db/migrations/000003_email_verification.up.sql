@@ -0,0 +1,16 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified boolean DEFAULT FALSE;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS verification_token text;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS verification_token_expires timestamptz;
+
+-- Accounts created before verification existed stay usable.
+UPDATE
+    users
+SET
+    email_verified = TRUE
+WHERE
+    email IS NOT NULL;
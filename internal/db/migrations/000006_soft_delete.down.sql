@@ -0,0 +1,20 @@
+ALTER TABLE persons
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE invoice_items
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE invoices
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE entities
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE brands
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE categories
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS deleted_at;
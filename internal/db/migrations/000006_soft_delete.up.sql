@@ -0,0 +1,20 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE brands
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE entities
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE invoice_items
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE persons
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
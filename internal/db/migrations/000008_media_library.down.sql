@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS images_checksum_idx;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS checksum;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS mime;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS height;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS width;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS size;
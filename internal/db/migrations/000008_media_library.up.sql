@@ -0,0 +1,16 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS size bigint;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS width integer;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS height integer;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS mime text;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS checksum text;
+
+CREATE INDEX IF NOT EXISTS images_checksum_idx ON images (checksum);
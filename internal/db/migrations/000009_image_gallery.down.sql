@@ -0,0 +1,20 @@
+DROP INDEX IF EXISTS images_article_position_idx;
+
+DROP INDEX IF EXISTS images_category_position_idx;
+
+DROP INDEX IF EXISTS images_product_position_idx;
+
+ALTER TABLE IF EXISTS images
+    DROP CONSTRAINT IF EXISTS fk_images_article;
+
+ALTER TABLE IF EXISTS images
+    DROP CONSTRAINT IF EXISTS fk_images_category;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS alt;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS position;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS article_id;
@@ -0,0 +1,42 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS category_id uuid;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS article_id uuid;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS position integer NOT NULL DEFAULT 0;
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS alt text;
+
+ALTER TABLE IF EXISTS images
+    DROP CONSTRAINT IF EXISTS fk_images_category;
+
+ALTER TABLE IF EXISTS images
+    ADD CONSTRAINT fk_images_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+ALTER TABLE IF EXISTS images
+    ADD CONSTRAINT fk_images_article FOREIGN KEY (article_id) REFERENCES articles (id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- Keep the order galleries had so far, which was insertion order.
+UPDATE
+    images
+SET
+    position = ordered.position
+FROM (
+    SELECT
+        id,
+        row_number() OVER (PARTITION BY product_id ORDER BY created_at, id) - 1 AS position
+    FROM
+        images
+    WHERE
+        product_id IS NOT NULL) ordered
+WHERE
+    images.id = ordered.id;
+
+CREATE INDEX IF NOT EXISTS images_product_position_idx ON images (product_id, position);
+
+CREATE INDEX IF NOT EXISTS images_category_position_idx ON images (category_id, position);
+
+CREATE INDEX IF NOT EXISTS images_article_position_idx ON images (article_id, position);
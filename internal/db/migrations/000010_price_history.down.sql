@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS product_price_history ON products;
+
+DROP FUNCTION IF EXISTS record_product_price ();
+
+DROP TABLE IF EXISTS scheduled_prices;
+
+DROP TABLE IF EXISTS product_price_history;
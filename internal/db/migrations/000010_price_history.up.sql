@@ -0,0 +1,65 @@
+CREATE TABLE IF NOT EXISTS product_price_history (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id uuid NOT NULL,
+    old_price text,
+    new_price text,
+    source varchar NOT NULL DEFAULT 'manual',
+    created_at timestamptz DEFAULT now(),
+    CONSTRAINT fk_product_price_history_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_price_history_product ON product_price_history (product_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS scheduled_prices (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id uuid NOT NULL,
+    price text NOT NULL,
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz,
+    -- The price replaced when the schedule was applied, restored at ends_at.
+    previous_price text,
+    applied_at timestamptz,
+    reverted_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    CONSTRAINT fk_scheduled_prices_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT scheduled_prices_period CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_product ON scheduled_prices (product_id, starts_at);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_pending ON scheduled_prices (starts_at)
+WHERE
+    applied_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_prices_active ON scheduled_prices (ends_at)
+WHERE
+    applied_at IS NOT NULL AND reverted_at IS NULL;
+
+-- Every price change is recorded, whatever made it. Writers name
+-- themselves with set_config('caroption.price_source', ..., TRUE) in their
+-- transaction; anything else counts as a manual change.
+CREATE OR REPLACE FUNCTION record_product_price ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.price IS NOT DISTINCT FROM NEW.price THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'INSERT' AND NEW.price IS NULL THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO product_price_history (product_id, old_price, new_price, source)
+        VALUES (NEW.id, CASE WHEN TG_OP = 'UPDATE' THEN
+                OLD.price
+            END, NEW.price, COALESCE(NULLIF (current_setting('caroption.price_source', TRUE), ''), 'manual'));
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_price_history ON products;
+
+CREATE TRIGGER product_price_history
+    AFTER INSERT OR UPDATE OF price ON products
+    FOR EACH ROW
+    EXECUTE FUNCTION record_product_price ();
@@ -0,0 +1,9 @@
+DROP FUNCTION IF EXISTS vehicle_lineage (uuid);
+
+DROP TABLE IF EXISTS product_fitments;
+
+DROP TRIGGER IF EXISTS vehicles_parent ON vehicles;
+
+DROP FUNCTION IF EXISTS check_vehicle_parent ();
+
+DROP TABLE IF EXISTS vehicles;
@@ -0,0 +1,116 @@
+CREATE TABLE IF NOT EXISTS vehicles (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    parent_id uuid,
+    kind varchar NOT NULL,
+    name text NOT NULL,
+    slug text,
+    year_from integer,
+    year_to integer,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    CONSTRAINT fk_vehicles_parent FOREIGN KEY (parent_id) REFERENCES vehicles (id) ON DELETE RESTRICT,
+    CONSTRAINT vehicles_kind CHECK (kind IN ('make', 'model', 'generation', 'trim')),
+    CONSTRAINT vehicles_years CHECK (year_to IS NULL OR year_from IS NULL OR year_to >= year_from));
+
+CREATE INDEX IF NOT EXISTS idx_vehicles_parent ON vehicles (parent_id);
+
+-- Names are unique among the children of a vehicle and among makes.
+CREATE UNIQUE INDEX IF NOT EXISTS vehicles_name_unique ON vehicles (COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), name);
+
+CREATE TABLE IF NOT EXISTS product_fitments (
+    product_id uuid NOT NULL,
+    vehicle_id uuid NOT NULL,
+    created_at timestamptz DEFAULT now(),
+    PRIMARY KEY (product_id, vehicle_id),
+    CONSTRAINT fk_product_fitments_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_fitments_vehicle FOREIGN KEY (vehicle_id) REFERENCES vehicles (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_fitments_vehicle ON product_fitments (vehicle_id);
+
+-- A make has no parent; models belong to a make, generations to a model
+-- and trims to a generation.
+CREATE OR REPLACE FUNCTION check_vehicle_parent ()
+    RETURNS TRIGGER
+    AS $$
+DECLARE
+    parent_kind varchar;
+BEGIN
+    SELECT
+        kind INTO parent_kind
+    FROM
+        vehicles
+    WHERE
+        id = NEW.parent_id;
+    IF parent_kind IS DISTINCT FROM CASE NEW.kind
+        WHEN 'model' THEN
+            'make'
+        WHEN 'generation' THEN
+            'model'
+        WHEN 'trim' THEN
+            'generation'
+        END THEN
+        RAISE EXCEPTION 'a % cannot belong to a %', NEW.kind, COALESCE(parent_kind, 'nothing')
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS vehicles_parent ON vehicles;
+
+CREATE TRIGGER vehicles_parent
+    BEFORE INSERT OR UPDATE OF parent_id, kind ON vehicles
+    FOR EACH ROW
+    EXECUTE FUNCTION check_vehicle_parent ();
+
+-- vehicle_lineage returns the vehicles whose fitments fit vehicle: itself,
+-- what it belongs to (a part fitting a whole model fits each of its
+-- trims) and its own parts (a part fitting one trim may fit a customer
+-- who only picked the model).
+CREATE OR REPLACE FUNCTION vehicle_lineage (vehicle uuid)
+    RETURNS SETOF uuid
+    AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT
+            id,
+            parent_id
+        FROM
+            vehicles
+        WHERE
+            id = vehicle
+        UNION ALL
+        SELECT
+            v.id,
+            v.parent_id
+        FROM
+            vehicles v
+            JOIN ancestors a ON v.id = a.parent_id
+),
+descendants AS (
+    SELECT
+        id
+    FROM
+        vehicles
+    WHERE
+        parent_id = vehicle
+    UNION ALL
+    SELECT
+        v.id
+    FROM
+        vehicles v
+        JOIN descendants d ON v.parent_id = d.id
+)
+SELECT
+    id
+FROM
+    ancestors
+UNION
+SELECT
+    id
+FROM
+    descendants;
+$$
+LANGUAGE sql
+STABLE;
@@ -0,0 +1,23 @@
+DROP TRIGGER IF EXISTS product_variant_price_history ON product_variants;
+
+DROP FUNCTION IF EXISTS record_variant_price ();
+
+ALTER TABLE IF EXISTS product_price_history
+    DROP CONSTRAINT IF EXISTS fk_product_price_history_variant;
+
+ALTER TABLE product_price_history
+    DROP COLUMN IF EXISTS variant_id;
+
+DROP INDEX IF EXISTS images_variant_position_idx;
+
+ALTER TABLE IF EXISTS images
+    DROP CONSTRAINT IF EXISTS fk_images_variant;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variant_values;
+
+DROP TABLE IF EXISTS product_variants;
+
+DROP TABLE IF EXISTS product_variant_axes;
@@ -0,0 +1,76 @@
+-- The selectable parameters a product varies by, in display order.
+CREATE TABLE IF NOT EXISTS product_variant_axes (
+    product_id uuid NOT NULL,
+    parameter_id uuid NOT NULL,
+    position integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (product_id, parameter_id),
+    CONSTRAINT fk_product_variant_axes_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_variant_axes_parameter FOREIGN KEY (parameter_id) REFERENCES parameters (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id uuid NOT NULL,
+    code text,
+    price text,
+    count text,
+    show boolean NOT NULL DEFAULT TRUE,
+    position integer NOT NULL DEFAULT 0,
+    image_id uuid,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    CONSTRAINT fk_product_variants_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_variants_image FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_variants_product ON product_variants (product_id, position);
+
+CREATE TABLE IF NOT EXISTS product_variant_values (
+    variant_id uuid NOT NULL,
+    parameter_id uuid NOT NULL,
+    value text NOT NULL,
+    PRIMARY KEY (variant_id, parameter_id),
+    CONSTRAINT fk_product_variant_values_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_variant_values_parameter FOREIGN KEY (parameter_id) REFERENCES parameters (id) ON DELETE CASCADE
+);
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS variant_id uuid;
+
+ALTER TABLE IF EXISTS images
+    ADD CONSTRAINT fk_images_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS images_variant_position_idx ON images (variant_id, position);
+
+-- Variant prices are recorded in the history of their product.
+ALTER TABLE product_price_history
+    ADD COLUMN IF NOT EXISTS variant_id uuid;
+
+ALTER TABLE IF EXISTS product_price_history
+    ADD CONSTRAINT fk_product_price_history_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id) ON DELETE CASCADE;
+
+CREATE OR REPLACE FUNCTION record_variant_price ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.price IS NOT DISTINCT FROM NEW.price THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'INSERT' AND NEW.price IS NULL THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO product_price_history (product_id, variant_id, old_price, new_price, source)
+        VALUES (NEW.product_id, NEW.id, CASE WHEN TG_OP = 'UPDATE' THEN
+                OLD.price
+            END, NEW.price, COALESCE(NULLIF (current_setting('caroption.price_source', TRUE), ''), 'manual'));
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_variant_price_history ON product_variants;
+
+CREATE TRIGGER product_variant_price_history
+    AFTER INSERT OR UPDATE OF price ON product_variants
+    FOR EACH ROW
+    EXECUTE FUNCTION record_variant_price ();
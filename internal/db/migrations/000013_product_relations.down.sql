@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_invoice_items_invoice;
+
+DROP INDEX IF EXISTS idx_invoice_items_product_invoice;
+
+DROP TABLE IF EXISTS product_relations;
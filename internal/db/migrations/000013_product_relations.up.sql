@@ -0,0 +1,19 @@
+-- Curated links from a product to others shown with it.
+CREATE TABLE IF NOT EXISTS product_relations (
+    product_id uuid NOT NULL,
+    related_id uuid NOT NULL,
+    kind varchar(20) NOT NULL CHECK (kind IN ('related', 'accessory', 'replacement', 'upsell')),
+    position integer NOT NULL DEFAULT 0,
+    created_at timestamptz DEFAULT now(),
+    PRIMARY KEY (product_id, kind, related_id),
+    CONSTRAINT product_relations_not_self CHECK (product_id <> related_id),
+    CONSTRAINT fk_product_relations_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_relations_related FOREIGN KEY (related_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_relations_related ON product_relations (related_id);
+
+-- Finds the invoices a product was sold in, and what else they sold.
+CREATE INDEX IF NOT EXISTS idx_invoice_items_product_invoice ON invoice_items (product_id, invoice_id);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_items_invoice ON invoice_items (invoice_id);
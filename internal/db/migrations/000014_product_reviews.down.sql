@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS product_reviews_rating ON product_reviews;
+
+DROP FUNCTION IF EXISTS record_product_rating ();
+
+DROP FUNCTION IF EXISTS refresh_product_rating (uuid);
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS rating_count;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS rating_average;
+
+DROP TABLE IF EXISTS product_reviews;
@@ -0,0 +1,78 @@
+-- A customer reviews a product once; posting again edits the review and
+-- sends it back to moderation.
+CREATE TABLE IF NOT EXISTS product_reviews (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    rating smallint NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body text,
+    status varchar(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reply text,
+    replied_at timestamptz,
+    moderated_by uuid,
+    moderated_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    CONSTRAINT product_reviews_once UNIQUE (product_id, user_id),
+    CONSTRAINT fk_product_reviews_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_reviews_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_reviews_moderator FOREIGN KEY (moderated_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_reviews_status ON product_reviews (status, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_product_reviews_product ON product_reviews (product_id, status, created_at DESC);
+
+-- The approved reviews of a product, kept up to date for listing and
+-- sorting products by rating.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS rating_average double precision;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+
+CREATE OR REPLACE FUNCTION refresh_product_rating (target uuid)
+    RETURNS void
+    AS $$
+    UPDATE
+        products p
+    SET
+        rating_average = approved.average,
+        rating_count = approved.count
+    FROM (
+        SELECT
+            round(avg(rating), 2)::double precision AS average,
+            count(*) AS count
+        FROM
+            product_reviews
+        WHERE
+            product_id = target
+            AND status = 'approved') approved
+WHERE
+    p.id = target;
+$$
+LANGUAGE sql;
+
+CREATE OR REPLACE FUNCTION record_product_rating ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM
+            refresh_product_rating (OLD.product_id);
+    END IF;
+    IF TG_OP = 'INSERT' OR NEW.product_id <> OLD.product_id THEN
+        PERFORM
+            refresh_product_rating (NEW.product_id);
+    END IF;
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_reviews_rating ON product_reviews;
+
+CREATE TRIGGER product_reviews_rating
+    AFTER INSERT OR UPDATE OF rating, status, product_id OR DELETE ON product_reviews
+    FOR EACH ROW
+    EXECUTE FUNCTION record_product_rating ();
@@ -0,0 +1,25 @@
+-- Questions customers ask about a product, shown in the storefront with
+-- their answer once approved.
+CREATE TABLE IF NOT EXISTS product_questions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    question text NOT NULL,
+    answer text,
+    answered_by uuid,
+    answered_at timestamptz,
+    status varchar(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    moderated_by uuid,
+    moderated_at timestamptz,
+    notified_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    CONSTRAINT fk_product_questions_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_questions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_questions_answerer FOREIGN KEY (answered_by) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_product_questions_moderator FOREIGN KEY (moderated_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_questions_status ON product_questions (status, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_product_questions_product ON product_questions (product_id, status, answered_at DESC);
@@ -0,0 +1,30 @@
+DROP TRIGGER IF EXISTS invoices_stock ON invoices;
+
+DROP FUNCTION IF EXISTS record_invoice_stock ();
+
+DROP TRIGGER IF EXISTS invoice_items_stock ON invoice_items;
+
+DROP FUNCTION IF EXISTS record_invoice_item_stock ();
+
+-- Dropping the ledger keeps the counts it moved.
+DROP TRIGGER IF EXISTS stock_movements_count ON stock_movements;
+
+DROP FUNCTION IF EXISTS apply_stock_movement ();
+
+DROP FUNCTION IF EXISTS sync_invoice_item_stock (uuid);
+
+DROP TABLE IF EXISTS stock_movements;
+
+DROP TRIGGER IF EXISTS product_bundle_prices ON products;
+
+DROP FUNCTION IF EXISTS refresh_bundle_prices ();
+
+DROP FUNCTION IF EXISTS refresh_bundle_price (uuid);
+
+DROP TABLE IF EXISTS product_bundle_items;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS bundle_discount;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS bundle_pricing;
@@ -0,0 +1,194 @@
+-- A product with bundle_pricing is a bundle of the products in
+-- product_bundle_items. With 'sum' pricing its price is the sum of theirs
+-- less bundle_discount; with 'fixed' it keeps its own.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS bundle_pricing varchar(10) CHECK (bundle_pricing IN ('fixed', 'sum'));
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS bundle_discount numeric(12, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS product_bundle_items (
+    bundle_id uuid NOT NULL,
+    product_id uuid NOT NULL,
+    quantity integer NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    position integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (bundle_id, product_id),
+    CONSTRAINT product_bundle_items_not_self CHECK (bundle_id <> product_id),
+    CONSTRAINT fk_product_bundle_items_bundle FOREIGN KEY (bundle_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_bundle_items_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_bundle_items_product ON product_bundle_items (product_id);
+
+CREATE OR REPLACE FUNCTION refresh_bundle_price (target uuid)
+    RETURNS void
+    AS $$
+    UPDATE
+        products b
+    SET
+        price = trim_scale(greatest(components.total - b.bundle_discount, 0))::text
+    FROM (
+        SELECT
+            sum(
+                CASE WHEN c.price ~ '^[0-9]+(\.[0-9]+)?$' THEN
+                    c.price::numeric * bi.quantity
+                END) AS total
+        FROM
+            product_bundle_items bi
+            JOIN products c ON bi.product_id = c.id
+        WHERE
+            bi.bundle_id = target
+        HAVING
+            count(*) > 0
+            AND bool_and(c.price ~ '^[0-9]+(\.[0-9]+)?$')) components
+WHERE
+    b.id = target
+    AND b.bundle_pricing = 'sum';
+$$
+LANGUAGE sql;
+
+-- Bundles are never components, so this does not recurse.
+CREATE OR REPLACE FUNCTION refresh_bundle_prices ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    PERFORM
+        refresh_bundle_price (bi.bundle_id)
+    FROM
+        product_bundle_items bi
+    WHERE
+        bi.product_id = NEW.id;
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_bundle_prices ON products;
+
+CREATE TRIGGER product_bundle_prices
+    AFTER UPDATE OF price ON products
+    FOR EACH ROW
+    WHEN (OLD.price IS DISTINCT FROM NEW.price)
+    EXECUTE FUNCTION refresh_bundle_prices ();
+
+-- What each invoice line moved in or out of stock: sells are negative and
+-- buys positive. A bundle moves its components.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id uuid NOT NULL,
+    invoice_item_id uuid NOT NULL,
+    quantity integer NOT NULL,
+    created_at timestamptz DEFAULT now(),
+    CONSTRAINT fk_stock_movements_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+    CONSTRAINT fk_stock_movements_invoice_item FOREIGN KEY (invoice_item_id) REFERENCES invoice_items (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product ON stock_movements (product_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_invoice_item ON stock_movements (invoice_item_id);
+
+CREATE OR REPLACE FUNCTION sync_invoice_item_stock (item uuid)
+    RETURNS void
+    AS $$
+    DELETE FROM stock_movements
+    WHERE invoice_item_id = item;
+    INSERT INTO stock_movements (product_id, invoice_item_id, quantity)
+    SELECT
+        COALESCE(bi.product_id, ii.product_id),
+        ii.id,
+        CASE WHEN inv.type = 'sell' THEN
+            -1
+        ELSE
+            1
+        END * ii.count * COALESCE(bi.quantity, 1)
+    FROM
+        invoice_items ii
+        JOIN invoices inv ON ii.invoice_id = inv.id
+        LEFT JOIN product_bundle_items bi ON bi.bundle_id = ii.product_id
+    WHERE
+        ii.id = item
+        AND ii.product_id IS NOT NULL
+        AND ii.deleted_at IS NULL
+        AND inv.deleted_at IS NULL;
+$$
+LANGUAGE sql;
+
+-- Lines invoiced before stock was tracked get their movements without
+-- changing the counts they were already counted in.
+SELECT
+    sync_invoice_item_stock (id)
+FROM
+    invoice_items;
+
+CREATE OR REPLACE FUNCTION apply_stock_movement ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE
+            products
+        SET
+            count = (count::bigint + NEW.quantity)::text
+        WHERE
+            id = NEW.product_id
+            AND count ~ '^-?[0-9]+$';
+    ELSE
+        UPDATE
+            products
+        SET
+            count = (count::bigint - OLD.quantity)::text
+        WHERE
+            id = OLD.product_id
+            AND count ~ '^-?[0-9]+$';
+    END IF;
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_movements_count ON stock_movements;
+
+CREATE TRIGGER stock_movements_count
+    AFTER INSERT OR DELETE ON stock_movements
+    FOR EACH ROW
+    EXECUTE FUNCTION apply_stock_movement ();
+
+CREATE OR REPLACE FUNCTION record_invoice_item_stock ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    PERFORM
+        sync_invoice_item_stock (NEW.id);
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS invoice_items_stock ON invoice_items;
+
+CREATE TRIGGER invoice_items_stock
+    AFTER INSERT OR UPDATE OF product_id, count, invoice_id, deleted_at ON invoice_items
+    FOR EACH ROW
+    EXECUTE FUNCTION record_invoice_item_stock ();
+
+CREATE OR REPLACE FUNCTION record_invoice_stock ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    PERFORM
+        sync_invoice_item_stock (ii.id)
+    FROM
+        invoice_items ii
+    WHERE
+        ii.invoice_id = NEW.id;
+    RETURN NULL;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS invoices_stock ON invoices;
+
+CREATE TRIGGER invoices_stock
+    AFTER UPDATE OF type, deleted_at ON invoices
+    FOR EACH ROW
+    EXECUTE FUNCTION record_invoice_stock ();
@@ -0,0 +1,19 @@
+DROP TRIGGER IF EXISTS categories_parent_cycle ON categories;
+
+DROP FUNCTION IF EXISTS check_category_parent ();
+
+DROP FUNCTION IF EXISTS category_visible (uuid);
+
+DROP FUNCTION IF EXISTS category_descendants (uuid);
+
+DROP FUNCTION IF EXISTS category_ancestors (uuid);
+
+DROP INDEX IF EXISTS idx_categories_parent;
+
+ALTER TABLE categories
+    ALTER COLUMN priority DROP NOT NULL,
+    ALTER COLUMN priority DROP DEFAULT;
+
+ALTER TABLE categories
+    ALTER COLUMN priority TYPE varchar
+    USING priority::varchar;
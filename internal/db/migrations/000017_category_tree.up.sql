@@ -0,0 +1,137 @@
+ALTER TABLE categories
+    ALTER COLUMN priority TYPE integer
+    USING CASE WHEN trim(priority) ~ '^-?[0-9]+$' THEN
+        trim(priority)::integer
+    ELSE
+        0
+    END;
+
+UPDATE
+    categories
+SET
+    priority = 0
+WHERE
+    priority IS NULL;
+
+ALTER TABLE categories
+    ALTER COLUMN priority SET DEFAULT 0,
+    ALTER COLUMN priority SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories (parent_id, priority);
+
+-- category_ancestors is a category and the categories above it, with how
+-- many levels up each is: 0 for the category itself.
+CREATE OR REPLACE FUNCTION category_ancestors (target uuid)
+    RETURNS TABLE (
+        id uuid,
+        depth integer
+    )
+    AS $$
+    WITH RECURSIVE up AS (
+        SELECT
+            c.id,
+            c.parent_id,
+            0 AS depth
+        FROM
+            categories c
+        WHERE
+            c.id = target
+        UNION ALL
+        SELECT
+            c.id,
+            c.parent_id,
+            up.depth + 1
+        FROM
+            categories c
+            JOIN up ON c.id = up.parent_id)
+CYCLE id SET is_cycle USING path
+SELECT
+    up.id,
+    up.depth
+FROM
+    up
+WHERE
+    NOT up.is_cycle;
+$$
+LANGUAGE sql
+STABLE;
+
+-- category_descendants is a category and the categories below it, with how
+-- many levels down each is: 0 for the category itself.
+CREATE OR REPLACE FUNCTION category_descendants (target uuid)
+    RETURNS TABLE (
+        id uuid,
+        depth integer
+    )
+    AS $$
+    WITH RECURSIVE down AS (
+        SELECT
+            c.id,
+            0 AS depth
+        FROM
+            categories c
+        WHERE
+            c.id = target
+        UNION ALL
+        SELECT
+            c.id,
+            down.depth + 1
+        FROM
+            categories c
+            JOIN down ON c.parent_id = down.id)
+CYCLE id SET is_cycle USING path
+SELECT
+    down.id,
+    down.depth
+FROM
+    down
+WHERE
+    NOT down.is_cycle;
+$$
+LANGUAGE sql
+STABLE;
+
+-- A category is visible in the storefront when it and every category above
+-- it are shown and not in the trash.
+CREATE OR REPLACE FUNCTION category_visible (target uuid)
+    RETURNS boolean
+    AS $$
+    SELECT
+        NOT EXISTS (
+            SELECT
+                1
+            FROM
+                category_ancestors (target) a
+                JOIN categories c ON c.id = a.id
+            WHERE
+                c.show IS NOT TRUE
+                OR c.deleted_at IS NOT NULL);
+$$
+LANGUAGE sql
+STABLE;
+
+CREATE OR REPLACE FUNCTION check_category_parent ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    IF NEW.parent_id IS NOT NULL AND EXISTS (
+        SELECT
+            1
+        FROM
+            category_ancestors (NEW.parent_id) a
+        WHERE
+            a.id = NEW.id) THEN
+        RAISE EXCEPTION 'a category cannot be moved under itself'
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'categories_parent_cycle';
+    END IF;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS categories_parent_cycle ON categories;
+
+CREATE TRIGGER categories_parent_cycle
+    BEFORE INSERT OR UPDATE OF parent_id ON categories
+    FOR EACH ROW
+    EXECUTE FUNCTION check_category_parent ();
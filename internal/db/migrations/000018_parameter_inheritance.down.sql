@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS category_parameters (uuid);
+
+DROP TABLE IF EXISTS category_parameter_overrides;
+
+DROP INDEX IF EXISTS idx_parameter_groups_category;
+
+-- category_id stays: the code used it long before this migration.
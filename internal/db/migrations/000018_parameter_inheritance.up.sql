@@ -0,0 +1,81 @@
+-- The code has always grouped parameters by category; databases created
+-- from 000001 alone only have entity_id.
+ALTER TABLE parameter_groups
+    ADD COLUMN IF NOT EXISTS category_id uuid;
+
+CREATE INDEX IF NOT EXISTS idx_parameter_groups_category ON parameter_groups (category_id);
+
+-- An override changes a parameter inherited from a category above for
+-- category_id and the categories below it: hidden leaves it out, and the
+-- other columns, when set, replace those of the parameter. The nearest
+-- override wins.
+CREATE TABLE IF NOT EXISTS category_parameter_overrides (
+    category_id uuid NOT NULL,
+    parameter_id uuid NOT NULL,
+    hidden boolean NOT NULL DEFAULT FALSE,
+    name varchar,
+    description text,
+    selectables varchar[],
+    priority varchar,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    PRIMARY KEY (category_id, parameter_id),
+    CONSTRAINT fk_category_parameter_overrides_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE,
+    CONSTRAINT fk_category_parameter_overrides_parameter FOREIGN KEY (parameter_id) REFERENCES parameters (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_parameter_overrides_parameter ON category_parameter_overrides (parameter_id);
+
+-- category_parameters is the parameter schema of a category: the parameters
+-- of its groups and of the groups of every category above it, as the
+-- nearest override below where each is defined leaves them.
+CREATE OR REPLACE FUNCTION category_parameters (target uuid)
+    RETURNS TABLE (
+        id uuid,
+        name varchar,
+        description text,
+        type varchar,
+        parameter_group_id uuid,
+        parameter_group varchar,
+        selectables varchar[],
+        priority varchar,
+        created_at timestamptz,
+        category_id uuid,
+        inherited boolean,
+        overridden boolean,
+        hidden boolean
+    )
+    AS $$
+    SELECT
+        prm.id,
+        COALESCE(o.name, prm.name),
+        COALESCE(o.description, prm.description),
+        prm.type,
+        prm.parameter_group_id,
+        pg.name,
+        COALESCE(o.selectables, prm.selectables),
+        COALESCE(o.priority, prm.priority),
+        prm.created_at,
+        pg.category_id,
+        a.depth > 0,
+        o.parameter_id IS NOT NULL,
+        COALESCE(o.hidden, FALSE)
+    FROM
+        category_ancestors (target) a
+        JOIN parameter_groups pg ON pg.category_id = a.id
+        JOIN parameters prm ON prm.parameter_group_id = pg.id
+        LEFT JOIN LATERAL (
+            SELECT
+                cpo.*
+            FROM
+                category_ancestors (target) oa
+                JOIN category_parameter_overrides cpo ON cpo.category_id = oa.id
+            WHERE
+                cpo.parameter_id = prm.id
+                AND oa.depth < a.depth
+            ORDER BY
+                oa.depth
+            LIMIT 1) o ON TRUE;
+$$
+LANGUAGE sql
+STABLE;
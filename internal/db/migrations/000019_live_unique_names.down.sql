@@ -0,0 +1,26 @@
+-- Fails while a trashed row shares its name or phone number with another
+-- row; purge one of them first.
+DROP INDEX IF EXISTS persons_phone_number_live;
+
+ALTER TABLE persons
+    ADD CONSTRAINT persons_phone_number_key UNIQUE (phone_number);
+
+DROP INDEX IF EXISTS entities_name_live;
+
+ALTER TABLE entities
+    ADD CONSTRAINT entities_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS brands_name_live;
+
+ALTER TABLE brands
+    ADD CONSTRAINT brands_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS categories_name_live;
+
+ALTER TABLE categories
+    ADD CONSTRAINT categories_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS products_name_live;
+
+ALTER TABLE products
+    ADD CONSTRAINT products_name_key UNIQUE (name);
@@ -0,0 +1,36 @@
+-- Names and phone numbers only have to be unique among the rows out of the
+-- trash, so a trashed row does not block creating its replacement.
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_name_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_name_live ON products (name)
+WHERE
+    deleted_at IS NULL;
+
+ALTER TABLE categories
+    DROP CONSTRAINT IF EXISTS categories_name_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_name_live ON categories (name)
+WHERE
+    deleted_at IS NULL;
+
+ALTER TABLE brands
+    DROP CONSTRAINT IF EXISTS brands_name_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS brands_name_live ON brands (name)
+WHERE
+    deleted_at IS NULL;
+
+ALTER TABLE entities
+    DROP CONSTRAINT IF EXISTS entities_name_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS entities_name_live ON entities (name)
+WHERE
+    deleted_at IS NULL;
+
+ALTER TABLE persons
+    DROP CONSTRAINT IF EXISTS persons_phone_number_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS persons_phone_number_live ON persons (phone_number)
+WHERE
+    deleted_at IS NULL;
@@ -0,0 +1,32 @@
+DROP TRIGGER IF EXISTS product_bundle_price ON products;
+
+DROP FUNCTION IF EXISTS derive_bundle_price ();
+
+CREATE OR REPLACE FUNCTION refresh_bundle_price (target uuid)
+    RETURNS void
+    AS $$
+    UPDATE
+        products b
+    SET
+        price = trim_scale(greatest(components.total - b.bundle_discount, 0))::text
+    FROM (
+        SELECT
+            sum(
+                CASE WHEN c.price ~ '^[0-9]+(\.[0-9]+)?$' THEN
+                    c.price::numeric * bi.quantity
+                END) AS total
+        FROM
+            product_bundle_items bi
+            JOIN products c ON bi.product_id = c.id
+        WHERE
+            bi.bundle_id = target
+        HAVING
+            count(*) > 0
+            AND bool_and(c.price ~ '^[0-9]+(\.[0-9]+)?$')) components
+WHERE
+    b.id = target
+    AND b.bundle_pricing = 'sum';
+$$
+LANGUAGE sql;
+
+DROP FUNCTION IF EXISTS bundle_sum_price (uuid, numeric);
@@ -0,0 +1,54 @@
+-- The price of a 'sum' bundle, or NULL while it has no items or one of them
+-- has no numeric price.
+CREATE OR REPLACE FUNCTION bundle_sum_price (target uuid, discount numeric)
+    RETURNS text
+    AS $$
+    SELECT
+        trim_scale(greatest(sum(
+                    CASE WHEN c.price ~ '^[0-9]+(\.[0-9]+)?$' THEN
+                        c.price::numeric * bi.quantity
+                    END) - discount, 0))::text
+    FROM
+        product_bundle_items bi
+        JOIN products c ON bi.product_id = c.id
+    WHERE
+        bi.bundle_id = target
+    HAVING
+        count(*) > 0
+        AND bool_and(c.price ~ '^[0-9]+(\.[0-9]+)?$');
+$$
+LANGUAGE sql
+STABLE;
+
+CREATE OR REPLACE FUNCTION refresh_bundle_price (target uuid)
+    RETURNS void
+    AS $$
+    UPDATE
+        products
+    SET
+        price = COALESCE(bundle_sum_price (id, bundle_discount), price)
+    WHERE
+        id = target
+        AND bundle_pricing = 'sum';
+$$
+LANGUAGE sql;
+
+-- Whatever writes the price of a 'sum' bundle, like an edit, an import or a
+-- scheduled price, gets it derived from the items instead.
+CREATE OR REPLACE FUNCTION derive_bundle_price ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    NEW.price := COALESCE(bundle_sum_price (NEW.id, NEW.bundle_discount), OLD.price);
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_bundle_price ON products;
+
+CREATE TRIGGER product_bundle_price
+    BEFORE UPDATE ON products
+    FOR EACH ROW
+    WHEN (NEW.bundle_pricing = 'sum')
+    EXECUTE FUNCTION derive_bundle_price ();
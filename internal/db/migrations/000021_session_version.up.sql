@@ -0,0 +1,4 @@
+-- Tokens carry the session_version they were issued at; bumping it signs the
+-- user out everywhere.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS session_version integer NOT NULL DEFAULT 0;
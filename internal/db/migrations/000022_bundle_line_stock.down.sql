@@ -0,0 +1,26 @@
+-- Lines that are not bundles move stock again from their next change on.
+CREATE OR REPLACE FUNCTION sync_invoice_item_stock (item uuid)
+    RETURNS void
+    AS $$
+    DELETE FROM stock_movements
+    WHERE invoice_item_id = item;
+    INSERT INTO stock_movements (product_id, invoice_item_id, quantity)
+    SELECT
+        COALESCE(bi.product_id, ii.product_id),
+        ii.id,
+        CASE WHEN inv.type = 'sell' THEN
+            -1
+        ELSE
+            1
+        END * ii.count * COALESCE(bi.quantity, 1)
+    FROM
+        invoice_items ii
+        JOIN invoices inv ON ii.invoice_id = inv.id
+        LEFT JOIN product_bundle_items bi ON bi.bundle_id = ii.product_id
+    WHERE
+        ii.id = item
+        AND ii.product_id IS NOT NULL
+        AND ii.deleted_at IS NULL
+        AND inv.deleted_at IS NULL;
+$$
+LANGUAGE sql;
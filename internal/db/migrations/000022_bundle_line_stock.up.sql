@@ -0,0 +1,42 @@
+-- Only bundle lines move stock: a bundle sold or bought moves its items.
+-- Counts of products invoiced on their own stay as they are kept by hand.
+CREATE OR REPLACE FUNCTION sync_invoice_item_stock (item uuid)
+    RETURNS void
+    AS $$
+    DELETE FROM stock_movements
+    WHERE invoice_item_id = item;
+    INSERT INTO stock_movements (product_id, invoice_item_id, quantity)
+    SELECT
+        bi.product_id,
+        ii.id,
+        CASE WHEN inv.type = 'sell' THEN
+            -1
+        ELSE
+            1
+        END * ii.count * bi.quantity
+    FROM
+        invoice_items ii
+        JOIN invoices inv ON ii.invoice_id = inv.id
+        JOIN product_bundle_items bi ON bi.bundle_id = ii.product_id
+    WHERE
+        ii.id = item
+        AND ii.deleted_at IS NULL
+        AND inv.deleted_at IS NULL;
+$$
+LANGUAGE sql;
+
+-- Movements of lines that are not bundles go without changing the counts
+-- they were applied to.
+ALTER TABLE stock_movements DISABLE TRIGGER stock_movements_count;
+
+DELETE FROM stock_movements sm USING invoice_items ii
+WHERE sm.invoice_item_id = ii.id
+    AND NOT EXISTS (
+        SELECT
+            1
+        FROM
+            product_bundle_items bi
+        WHERE
+            bi.bundle_id = ii.product_id);
+
+ALTER TABLE stock_movements ENABLE TRIGGER stock_movements_count;
@@ -0,0 +1,9 @@
+CREATE OR REPLACE FUNCTION derive_bundle_price ()
+    RETURNS TRIGGER
+    AS $$
+BEGIN
+    NEW.price := COALESCE(bundle_sum_price (NEW.id, NEW.bundle_discount), OLD.price);
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
@@ -0,0 +1,18 @@
+-- Writing a price other than the derived one to a 'sum' bundle fails
+-- instead of being replaced silently; the derived price is still kept up to
+-- date when the discount or the pricing change.
+CREATE OR REPLACE FUNCTION derive_bundle_price ()
+    RETURNS TRIGGER
+    AS $$
+DECLARE
+    derived text := bundle_sum_price (NEW.id, NEW.bundle_discount);
+BEGIN
+    IF NEW.price IS DISTINCT FROM OLD.price AND NEW.price IS DISTINCT FROM derived THEN
+        RAISE EXCEPTION 'the price of a sum bundle is derived from its items'
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'bundle_price_derived';
+    END IF;
+    NEW.price := COALESCE(derived, OLD.price);
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
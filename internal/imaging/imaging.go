@@ -0,0 +1,261 @@
+// Package imaging validates uploaded images, strips their metadata and
+// renders the resized variants served to the storefront.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+)
+
+const (
+	// MaxPixels guards against decompression bombs; a 40 MP photo is far
+	// larger than anything the shop displays.
+	MaxPixels = 40_000_000
+
+	originalQuality = 92
+	variantQuality  = 82
+)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG, PNG and GIF images are accepted")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	ErrUnknownFormat   = errors.New("format must be jpeg, png or webp")
+)
+
+// Variant is a resized copy that fits in a MaxSize x MaxSize box.
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+var Variants = []Variant{
+	{Name: "thumbnail", MaxSize: 200},
+	{Name: "medium", MaxSize: 600},
+	{Name: "large", MaxSize: 1200},
+}
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type File struct {
+	Ext    string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+type VariantFile struct {
+	Name string
+	File
+	// WebP is empty when no WebP encoder is available.
+	WebP []byte
+}
+
+type Processed struct {
+	ContentType string
+	Original    File
+	Variants    []VariantFile
+}
+
+// Sniff returns the content type of data and the extension it is stored
+// with, ignoring whatever name or type the client claimed.
+func Sniff(data []byte) (string, string, error) {
+	contentType := http.DetectContentType(data)
+
+	ext, ok := extensions[contentType]
+	if !ok {
+		return "", "", ErrUnsupportedType
+	}
+
+	return contentType, ext, nil
+}
+
+// Decode reads an image after checking that its dimensions are within
+// MaxPixels.
+func Decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+
+	return img, err
+}
+
+// Process validates an upload and returns the original without metadata
+// together with its resized variants.
+func Process(data []byte) (Processed, error) {
+	var processed Processed
+
+	contentType, ext, err := Sniff(data)
+	if err != nil {
+		return processed, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return processed, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return processed, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return processed, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	processed.ContentType = contentType
+
+	switch contentType {
+	case "image/jpeg":
+		orientation := jpegOrientation(data)
+		if orientation > 1 {
+			// The orientation lives in the EXIF block being removed, so
+			// bake it into the pixels instead.
+			img = orient(img, orientation)
+
+			var buf bytes.Buffer
+
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: originalQuality})
+			if err != nil {
+				return processed, err
+			}
+
+			processed.Original.Data = buf.Bytes()
+		} else {
+			processed.Original.Data, err = stripJPEG(data)
+		}
+	case "image/png":
+		processed.Original.Data, err = stripPNG(data)
+	case "image/gif":
+		processed.Original.Data, err = reencodeGIF(data)
+	}
+
+	if err != nil {
+		return processed, err
+	}
+
+	bounds := img.Bounds()
+	processed.Original.Ext = ext
+	processed.Original.Width = bounds.Dx()
+	processed.Original.Height = bounds.Dy()
+
+	webpAvailable := true
+
+	// Each variant is scaled down from the previous, larger one.
+	source := img
+
+	for index := len(Variants) - 1; index >= 0; index-- {
+		variant := Variants[index]
+
+		resized := Resize(source, variant.MaxSize)
+		source = resized
+
+		file, err := encodeVariant(resized)
+		if err != nil {
+			return processed, err
+		}
+
+		variantFile := VariantFile{Name: variant.Name, File: file}
+
+		if webpAvailable {
+			variantFile.WebP, err = EncodeWebP(resized, variantQuality)
+			if errors.Is(err, ErrWebPUnavailable) {
+				webpAvailable = false
+			} else if err != nil {
+				log.Printf("webp %s: %v", variant.Name, err)
+			}
+		}
+
+		processed.Variants = append([]VariantFile{variantFile}, processed.Variants...)
+	}
+
+	return processed, nil
+}
+
+// encodeVariant writes opaque images as JPEG and keeps transparency in PNG.
+func encodeVariant(img image.Image) (File, error) {
+	var (
+		buf  bytes.Buffer
+		file File
+	)
+
+	bounds := img.Bounds()
+	file.Width = bounds.Dx()
+	file.Height = bounds.Dy()
+
+	ext, err := Encode(&buf, img, "")
+	if err != nil {
+		return file, err
+	}
+
+	file.Ext = ext
+	file.Data = buf.Bytes()
+
+	return file, nil
+}
+
+// Encode writes img as "jpeg", "png" or "webp" and returns the extension
+// used. An empty format picks JPEG for opaque images and PNG otherwise.
+func Encode(w io.Writer, img image.Image, format string) (string, error) {
+	if format == "" {
+		format = "png"
+		if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+			format = "jpeg"
+		}
+	}
+
+	switch format {
+	case "jpeg":
+		return ".jpg", jpeg.Encode(w, img, &jpeg.Options{Quality: variantQuality})
+	case "png":
+		return ".png", png.Encode(w, img)
+	case "webp":
+		data, err := EncodeWebP(img, variantQuality)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = w.Write(data)
+
+		return ".webp", err
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// reencodeGIF keeps every frame but drops comment and application
+// extensions other than the loop count.
+func reencodeGIF(data []byte) ([]byte, error) {
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	err = gif.EncodeAll(&buf, animation)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
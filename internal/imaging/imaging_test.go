@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func testImage(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	return img
+}
+
+// exifJPEG returns a JPEG with an APP1 segment holding only an orientation.
+func exifJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = append(tiff, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := buf.Bytes()
+
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+func TestSniffRejectsNonImages(t *testing.T) {
+	_, _, err := Sniff([]byte("<?php echo 'hi'; ?>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("got %v, want ErrUnsupportedType", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	resized := Resize(testImage(400, 100), 200)
+	if got := resized.Bounds().Size(); got != image.Pt(200, 50) {
+		t.Fatalf("got %v, want 200x50", got)
+	}
+
+	small := testImage(50, 50)
+	if Resize(small, 200) != image.Image(small) {
+		t.Fatal("small images must not be upscaled")
+	}
+}
+
+func TestProcessStripsExifAndAppliesOrientation(t *testing.T) {
+	data := exifJPEG(t, testImage(40, 20), 6)
+
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("orientation = %d, want 6", got)
+	}
+
+	processed, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(processed.Original.Data, []byte("Exif")) {
+		t.Fatal("EXIF was not removed")
+	}
+
+	if processed.Original.Width != 20 || processed.Original.Height != 40 {
+		t.Fatalf("got %dx%d, want the rotated 20x40", processed.Original.Width, processed.Original.Height)
+	}
+
+	if len(processed.Variants) != len(Variants) {
+		t.Fatalf("got %d variants, want %d", len(processed.Variants), len(Variants))
+	}
+}
+
+func TestStripJPEGKeepsImageData(t *testing.T) {
+	data := exifJPEG(t, testImage(16, 16), 1)
+
+	stripped, err := stripJPEG(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(stripped, []byte("Exif")) {
+		t.Fatal("EXIF was not removed")
+	}
+
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Fatal(err)
+	}
+}
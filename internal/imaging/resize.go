@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// contributions maps every destination pixel to the source pixels it covers
+// and the share of each, for an area-averaging downscale.
+func contributions(srcSize int, dstSize int) [][]contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	result := make([][]contribution, dstSize)
+
+	for d := range dstSize {
+		start := float64(d) * scale
+		end := start + scale
+
+		for s := int(start); s < srcSize && float64(s) < end; s++ {
+			covered := math.Min(end, float64(s+1)) - math.Max(start, float64(s))
+			if covered > 0 {
+				result[d] = append(result[d], contribution{index: s, weight: covered / scale})
+			}
+		}
+	}
+
+	return result
+}
+
+// Resize scales img down to fit in a maxSize x maxSize box, keeping its
+// aspect ratio. Smaller images are returned as they are.
+func Resize(img image.Image, maxSize int) image.Image {
+	return Fit(img, maxSize, maxSize)
+}
+
+// Fit scales img down to fit in a maxWidth x maxHeight box, keeping its
+// aspect ratio. A zero bound leaves that side unconstrained.
+func Fit(img image.Image, maxWidth int, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if maxWidth <= 0 {
+		maxWidth = width
+	}
+
+	if maxHeight <= 0 {
+		maxHeight = height
+	}
+
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+
+	scale := math.Min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	dstWidth := max(1, int(math.Round(float64(width)*scale)))
+	dstHeight := max(1, int(math.Round(float64(height)*scale)))
+
+	src := toNRGBA(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	columns := contributions(width, dstWidth)
+	rows := contributions(height, dstHeight)
+
+	// Colors are averaged premultiplied by alpha so transparent pixels do
+	// not bleed into their neighbours.
+	sums := make([]float64, dstWidth*4)
+
+	for dy, rowContributions := range rows {
+		clear(sums)
+
+		for _, row := range rowContributions {
+			pixels := src.Pix[row.index*src.Stride:]
+
+			for dx, columnContributions := range columns {
+				var r, g, b, a float64
+
+				for _, column := range columnContributions {
+					pixel := pixels[column.index*4 : column.index*4+4]
+					alpha := float64(pixel[3]) * column.weight
+
+					r += float64(pixel[0]) * alpha
+					g += float64(pixel[1]) * alpha
+					b += float64(pixel[2]) * alpha
+					a += alpha
+				}
+
+				sums[dx*4] += r * row.weight
+				sums[dx*4+1] += g * row.weight
+				sums[dx*4+2] += b * row.weight
+				sums[dx*4+3] += a * row.weight
+			}
+		}
+
+		pixels := dst.Pix[dy*dst.Stride:]
+
+		for dx := range dstWidth {
+			alpha := sums[dx*4+3]
+			if alpha == 0 {
+				continue
+			}
+
+			pixels[dx*4] = clampByte(sums[dx*4] / alpha)
+			pixels[dx*4+1] = clampByte(sums[dx*4+1] / alpha)
+			pixels[dx*4+2] = clampByte(sums[dx*4+2] / alpha)
+			pixels[dx*4+3] = clampByte(alpha)
+		}
+	}
+
+	return dst
+}
+
+func clampByte(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(value))))
+}
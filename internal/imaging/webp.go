@@ -0,0 +1,63 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+var ErrWebPUnavailable = errors.New("cwebp is not installed")
+
+// EncodeWebP converts img with the cwebp tool (CWEBP_PATH, or cwebp on the
+// PATH), since neither the standard library nor x/image can write WebP.
+func EncodeWebP(img image.Image, quality int) ([]byte, error) {
+	cwebp := os.Getenv("CWEBP_PATH")
+	if cwebp == "" {
+		cwebp = "cwebp"
+	}
+
+	cwebp, err := exec.LookPath(cwebp)
+	if err != nil {
+		return nil, ErrWebPUnavailable
+	}
+
+	dir, err := os.MkdirTemp("", "webp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.png")
+	output := filepath.Join(dir, "output.webp")
+
+	file, err := os.Create(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = png.Encode(file, img)
+	file.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(
+		cwebp,
+		"-quiet",
+		"-metadata", "none",
+		"-q", strconv.Itoa(quality),
+		input,
+		"-o", output,
+	).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cwebp: %w: %s", err, out)
+	}
+
+	return os.ReadFile(output)
+}
@@ -0,0 +1,205 @@
+// Package mails renders the transactional emails (verification, password
+// reset, order confirmation, invoice and answered question) as Persian RTL
+// HTML with a plain-text alternative.
+package mails
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+type VerificationData struct {
+	Email          string
+	Link           string
+	ExpiresInHours int
+}
+
+type PasswordResetData struct {
+	Link           string
+	ExpiresInHours int
+}
+
+type InvoiceItemData struct {
+	Name     string
+	Count    int
+	Price    float64
+	Discount float64
+	Total    float64
+}
+
+type InvoiceData struct {
+	Number     string
+	Date       time.Time
+	PersonName string
+	Notes      string
+	Items      []InvoiceItemData
+	Discount   float64
+	Total      float64
+}
+
+type QuestionAnsweredData struct {
+	ProductName string
+	Question    string
+	Answer      string
+	Link        string
+}
+
+func Verification(to string, data VerificationData) (utils.Mail, error) {
+	return render("verification", "تایید ایمیل حساب کار آپشن", to, data)
+}
+
+func PasswordReset(to string, data PasswordResetData) (utils.Mail, error) {
+	return render("password_reset", "بازیابی رمز عبور", to, data)
+}
+
+func OrderConfirmation(to string, data InvoiceData) (utils.Mail, error) {
+	return render(
+		"order_confirmation",
+		"تایید سفارش شماره "+persianDigits(data.Number),
+		to,
+		data,
+	)
+}
+
+func Invoice(to string, data InvoiceData) (utils.Mail, error) {
+	return render("invoice", "فاکتور شماره "+persianDigits(data.Number), to, data)
+}
+
+func QuestionAnswered(to string, data QuestionAnsweredData) (utils.Mail, error) {
+	return render("question_answered", "پاسخ به پرسش شما درباره "+data.ProductName, to, data)
+}
+
+var funcs = map[string]any{
+	"persian": func(v any) string {
+		if t, ok := v.(time.Time); ok {
+			return persianDigits(jalali(t))
+		}
+
+		return persianDigits(fmt.Sprint(v))
+	},
+	"money": money,
+}
+
+func render(name string, subject string, to string, data any) (utils.Mail, error) {
+	htmlTemplate, err := htmltemplate.New(name).
+		Funcs(funcs).
+		ParseFS(templatesFS, "templates/layout.html", "templates/items.html", "templates/"+name+".html")
+	if err != nil {
+		return utils.Mail{}, err
+	}
+
+	var htmlBody bytes.Buffer
+
+	err = htmlTemplate.ExecuteTemplate(&htmlBody, "layout", struct {
+		Subject string
+		Data    any
+	}{subject, data})
+	if err != nil {
+		return utils.Mail{}, err
+	}
+
+	textTemplate, err := texttemplate.New(name+".txt").
+		Funcs(funcs).
+		ParseFS(templatesFS, "templates/"+name+".txt")
+	if err != nil {
+		return utils.Mail{}, err
+	}
+
+	var textBody bytes.Buffer
+
+	err = textTemplate.Execute(&textBody, data)
+	if err != nil {
+		return utils.Mail{}, err
+	}
+
+	return utils.Mail{
+		To:      []string{to},
+		Subject: subject,
+		HTML:    htmlBody.String(),
+		Text:    textBody.String(),
+	}, nil
+}
+
+func persianDigits(s string) string {
+	return strings.NewReplacer(
+		"0", "۰", "1", "۱", "2", "۲", "3", "۳", "4", "۴",
+		"5", "۵", "6", "۶", "7", "۷", "8", "۸", "9", "۹",
+	).Replace(s)
+}
+
+// money formats an amount with thousands separators and Persian digits.
+func money(amount float64) string {
+	digits := strconv.FormatInt(int64(amount), 10)
+
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	var b strings.Builder
+
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteRune('٬')
+		}
+
+		b.WriteRune(d)
+	}
+
+	return sign + persianDigits(b.String())
+}
+
+// jalali converts a date to the Solar Hijri calendar as YYYY/MM/DD.
+func jalali(t time.Time) string {
+	t = t.In(tehran)
+	gy, gm, gd := t.Year(), int(t.Month()), t.Day()
+
+	gDaysInMonth := []int{0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334}
+
+	gy2 := gy
+	if gm > 2 {
+		gy2 = gy + 1
+	}
+
+	days := 355666 + (365 * gy) + ((gy2 + 3) / 4) - ((gy2 + 99) / 100) + ((gy2 + 399) / 400) + gd + gDaysInMonth[gm-1]
+	jy := -1595 + (33 * (days / 12053))
+	days %= 12053
+	jy += 4 * (days / 1461)
+	days %= 1461
+
+	if days > 365 {
+		jy += (days - 1) / 365
+		days = (days - 1) % 365
+	}
+
+	var jm, jd int
+	if days < 186 {
+		jm = 1 + days/31
+		jd = 1 + days%31
+	} else {
+		jm = 7 + (days-186)/30
+		jd = 1 + (days-186)%30
+	}
+
+	return fmt.Sprintf("%04d/%02d/%02d", jy, jm, jd)
+}
+
+var tehran = func() *time.Location {
+	location, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		return time.FixedZone("IRST", 3*60*60+30*60)
+	}
+
+	return location
+}()
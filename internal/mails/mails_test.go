@@ -0,0 +1,75 @@
+package mails
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJalali(t *testing.T) {
+	cases := map[string]string{
+		"2025-03-21": "1404/01/01",
+		"2024-03-19": "1402/12/29",
+		"2025-10-19": "1404/07/27",
+	}
+
+	for gregorian, want := range cases {
+		date, _ := time.ParseInLocation("2006-01-02", gregorian, tehran)
+		if got := jalali(date); got != want {
+			t.Errorf("jalali(%s) = %s; want %s", gregorian, got, want)
+		}
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	invoice := InvoiceData{
+		Number:     "42",
+		Date:       time.Now(),
+		PersonName: "علی",
+		Items:      []InvoiceItemData{{Name: "پخش", Count: 2, Price: 1500000, Total: 3000000}},
+		Total:      3000000,
+	}
+
+	renders := map[string]func() (string, string, error){
+		"verification": func() (string, string, error) {
+			m, err := Verification("a@b.c", VerificationData{Email: "a@b.c", Link: "https://x/verify", ExpiresInHours: 24})
+
+			return m.HTML, m.Text, err
+		},
+		"password_reset": func() (string, string, error) {
+			m, err := PasswordReset("a@b.c", PasswordResetData{Link: "https://x/reset", ExpiresInHours: 24})
+
+			return m.HTML, m.Text, err
+		},
+		"order_confirmation": func() (string, string, error) {
+			m, err := OrderConfirmation("a@b.c", invoice)
+
+			return m.HTML, m.Text, err
+		},
+		"invoice": func() (string, string, error) {
+			m, err := Invoice("a@b.c", invoice)
+
+			return m.HTML, m.Text, err
+		},
+		"question_answered": func() (string, string, error) {
+			m, err := QuestionAnswered("a@b.c", QuestionAnsweredData{ProductName: "پخش", Question: "?", Answer: "!", Link: "https://x/products/a"})
+
+			return m.HTML, m.Text, err
+		},
+	}
+
+	for name, render := range renders {
+		html, text, err := render()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !strings.Contains(html, `dir="rtl"`) || strings.TrimSpace(text) == "" {
+			t.Errorf("%s: missing RTL HTML or text part", name)
+		}
+	}
+
+	if got := money(3000000); got != "۳٬۰۰۰٬۰۰۰" {
+		t.Errorf("money(3000000) = %s", got)
+	}
+}
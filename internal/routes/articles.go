@@ -12,10 +12,10 @@ import (
 )
 
 func GenerateArticleRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.Get("/recently_added_articles", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/recently_added_articles", func(w http.ResponseWriter, r *http.Request) {
 		utils.ListFromQueryToResponse(service.RecentlyArticles, r, w)
 	})
-	mainRouter.Get("/article_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/article_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("q")
 
 		slug, err := url.QueryUnescape(query)
@@ -25,17 +25,21 @@ func GenerateArticleRoutes(mainRouter *chi.Mux, service services.Service) {
 
 		utils.ObjectFromQueryToResponse(service.GetArticleBySlug, r, w, slug)
 	})
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/articles", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/articles", func(router chi.Router) {
+		audited := auditMutation(service, "articles")
+
+		generateGalleryRoutes(router, service, "articles", audited)
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			utils.ListFromQueryToResponse(service.ListArticles, r, w)
 		})
 
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "articles")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetArticle, r, w, id)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			article, err := utils.DecodeBody[services.Article](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -43,31 +47,17 @@ func GenerateArticleRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreateArticle(article)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
-		})
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
-
-			article, err := utils.DecodeBody[services.Article](r, w)
+			createdID, err := service.CreateArticle(article)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 
-			err = service.EditArticle(id, article)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "articles"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteArticle(id)
@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+// setCreated points the Location header at the row a POST created, which is
+// also how auditMutation learns its id.
+func setCreated(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id.String())
+}
+
+// auditMutation records who changed which resourceType row, with snapshots
+// before and after the wrapped handler ran. It must be attached with
+// router.With on the endpoint so the {id} URL param is already resolved.
+// Deleting something the row owns, like a scheduled price, is an update.
+func auditMutation(service services.Service, resourceType string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := chi.URLParam(r, "id")
+
+			var err error
+
+			entry := services.AuditLog{ResourceType: resourceType}
+
+			if id != "" {
+				entry.Before, err = service.AuditSnapshot(resourceType, id)
+				if err != nil {
+					log.Printf("audit %s %s: %v", resourceType, id, err)
+				}
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			if ww.Status() >= http.StatusBadRequest {
+				return
+			}
+
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/restore"):
+				entry.Action = "restore"
+			case strings.HasSuffix(r.URL.Path, "/purge"):
+				entry.Action = "purge"
+			case r.Method == http.MethodDelete && path.Base(r.URL.Path) == id:
+				entry.Action = "delete"
+			case r.Method == http.MethodPost && id == "":
+				entry.Action = "create"
+				id = path.Base(ww.Header().Get("Location"))
+			default:
+				entry.Action = "update"
+			}
+
+			if user, err := utils.ParseUserFromRequest(r); err == nil {
+				_ = entry.UserID.Scan(user.ID)
+			}
+
+			if parsedUUID, err := uuid.Parse(id); err == nil {
+				entry.ResourceID = pgtype.UUID{Bytes: parsedUUID, Valid: true}
+				entry.After, err = service.AuditSnapshot(resourceType, id)
+				if err != nil {
+					log.Printf("audit %s %s: %v", resourceType, id, err)
+				}
+			}
+
+			err = service.RecordAudit(entry)
+			if err != nil {
+				log.Printf("audit %s %s: %v", resourceType, id, err)
+			}
+		})
+	}
+}
+
+// recordAudits records the entries a handler that changes many rows got
+// back, each with who made the change and the row after it. Like
+// auditMutation, it only logs failures.
+func recordAudits(service services.Service, r *http.Request, entries []services.AuditLog) {
+	var userID pgtype.UUID
+
+	if user, err := utils.ParseUserFromRequest(r); err == nil {
+		_ = userID.Scan(user.ID)
+	}
+
+	for _, entry := range entries {
+		id := uuid.UUID(entry.ResourceID.Bytes).String()
+		entry.UserID = userID
+
+		var err error
+
+		entry.After, err = service.AuditSnapshot(entry.ResourceType, id)
+		if err != nil {
+			log.Printf("audit %s %s: %v", entry.ResourceType, id, err)
+		}
+
+		err = service.RecordAudit(entry)
+		if err != nil {
+			log.Printf("audit %s %s: %v", entry.ResourceType, id, err)
+		}
+	}
+}
+
+func GenerateAuditRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.With(middlewares.AdminOnly).Route("/audit", func(router chi.Router) {
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			service.ListAuditLogs(
+				r.URL.Query().Get("user_id"),
+				r.URL.Query().Get("resource_type"),
+				r.URL.Query().Get("resource_id"),
+				r.URL.Query().Get("from"),
+				r.URL.Query().Get("to"),
+				r.URL.Query().Get("count_in_page"),
+				r.URL.Query().Get("offset"),
+				w,
+			)
+		})
+	})
+}
@@ -1,14 +1,13 @@
 package routes
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
@@ -19,9 +18,40 @@ type Token struct {
 }
 
 type UserData struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	IsAdmin bool   `json:"isAdmin"`
+	ID          string `json:"id"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+	IsAdmin     bool   `json:"isAdmin"`
+}
+
+type OTPRequest struct {
+	PhoneNumber string `json:"phoneNumber"`
+	Code        string `json:"code"`
+}
+
+func setTokenCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(
+		w,
+		&http.Cookie{
+			Name:     "token",
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   false,
+			// SameSite: http.SameSiteNoneMode,
+			MaxAge: 60 * 60 * 24 * 365,
+		},
+	)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+
+	type data struct {
+		Status string `json:"status"`
+	}
+
+	responseData := data{Status: "OK"}
+
+	_ = encoder.Encode(responseData)
 }
 
 func GenerateAuthRoutes(mainRouter *chi.Mux, service services.Service) {
@@ -35,34 +65,74 @@ func GenerateAuthRoutes(mainRouter *chi.Mux, service services.Service) {
 			}
 
 			token, err := service.SignIn(user)
+			if errors.Is(err, services.ErrEmailNotVerified) ||
+				errors.Is(err, services.ErrUserDisabled) ||
+				errors.Is(err, services.ErrPasswordResetRequired) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+
+				return
+			}
+
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusUnauthorized)
 
 				return
 			}
 
-			http.SetCookie(
-				w,
-				&http.Cookie{
-					Name:     "token",
-					Value:    token,
-					Path:     "/",
-					HttpOnly: true,
-					Secure:   false,
-					// SameSite: http.SameSiteNoneMode,
-					MaxAge: 60 * 60 * 24 * 365,
-				},
-			)
+			setTokenCookie(w, token)
+		})
+		router.Post("/otp/request", func(w http.ResponseWriter, r *http.Request) {
+			body, err := utils.DecodeBody[OTPRequest](r, w)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			err = service.RequestOTP(body.PhoneNumber)
+			if err != nil {
+				switch {
+				case errors.Is(err, services.ErrInvalidPhoneNumber):
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				case errors.Is(err, services.ErrOTPTooSoon):
+					http.Error(w, err.Error(), http.StatusTooManyRequests)
+				default:
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+
+				return
+			}
+
 			w.WriteHeader(http.StatusOK)
-			encoder := json.NewEncoder(w)
+			w.Write([]byte("OK"))
+		})
+		router.Post("/otp/verify", func(w http.ResponseWriter, r *http.Request) {
+			body, err := utils.DecodeBody[OTPRequest](r, w)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 
-			type data struct {
-				Status string `json:"status"`
+				return
 			}
 
-			responseData := data{Status: "OK"}
+			token, err := service.VerifyOTP(body.PhoneNumber, body.Code)
+			if err != nil {
+				switch {
+				case errors.Is(err, services.ErrInvalidPhoneNumber):
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				case errors.Is(err, services.ErrOTPInvalid):
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+				case errors.Is(err, services.ErrOTPTooManyAttempts):
+					http.Error(w, err.Error(), http.StatusTooManyRequests)
+				case errors.Is(err, services.ErrUserDisabled):
+					http.Error(w, err.Error(), http.StatusForbidden)
+				default:
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+
+				return
+			}
 
-			_ = encoder.Encode(responseData)
+			setTokenCookie(w, token)
 		})
 		router.Post("/signup", func(w http.ResponseWriter, r *http.Request) {
 			user, err := utils.DecodeBody[services.User](r, w)
@@ -79,52 +149,44 @@ func GenerateAuthRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 		})
-		router.Get("/reset_password/{email}", func(w http.ResponseWriter, r *http.Request) {
-			email := chi.URLParam(r, "email")
+		router.Get("/verify_email/{token}", func(w http.ResponseWriter, r *http.Request) {
+			token := chi.URLParam(r, "token")
 
-			user, err := service.GetUser(email)
+			err := service.VerifyEmail(token)
 			if err != nil {
-				http.Error(w, "No User", http.StatusNotFound)
+				http.Error(w, "Token Not Valid", http.StatusUnauthorized)
 
 				return
 			}
 
-			bytes := make([]byte, 16) // 16 bytes = 32 hex characters
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+		})
+		router.Get("/resend_verification/{email}", func(w http.ResponseWriter, r *http.Request) {
+			email := chi.URLParam(r, "email")
 
-			_, err = rand.Read(bytes)
-			if err != nil {
+			// Unknown and verified emails get the same answer, so it tells
+			// no one which emails are registered.
+			err := service.SendVerificationEmail(email)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 				http.Error(w, "", http.StatusInternalServerError)
 
 				return
 			}
 
-			hexStr := hex.EncodeToString(bytes)
-			user.Token.String = hexStr
-			user.Token.Valid = true
-			expires := time.Now().Add(24 * time.Hour)
-			user.TokenExpires = &expires
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+		})
+		router.Get("/reset_password/{email}", func(w http.ResponseWriter, r *http.Request) {
+			email := chi.URLParam(r, "email")
 
-			err = service.EditUser(user)
-			if err != nil {
-				http.Error(w, "", http.StatusInternalServerError)
+			err := service.SendPasswordResetEmail(email)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "No User", http.StatusNotFound)
 
 				return
 			}
 
-			var emailAddrs []string
-
-			emailAddrs = append(emailAddrs, email)
-
-			err = utils.SendMail(
-				"info@caroptionshop.ir",
-				emailAddrs,
-				"Password Recovery",
-				"peymanecu@gmail.com",
-				"Peyman",
-				"<div>Click this <a href='"+os.Getenv(
-					"BASE_URL",
-				)+"/reset-password-callback/"+hexStr+"'>Link</a> for Password Recovery,Expire Time:24 Hour</div>",
-			)
 			if err != nil {
 				http.Error(w, "", http.StatusInternalServerError)
 
@@ -184,22 +246,14 @@ func GenerateAuthRoutes(mainRouter *chi.Mux, service services.Service) {
 			user := utils.GetUserFromRequest(w, r)
 
 			userData := &UserData{
-				ID:      user.ID,
-				Email:   user.Email,
-				IsAdmin: user.IsAdmin,
+				ID:          user.ID,
+				Email:       user.Email,
+				PhoneNumber: user.PhoneNumber,
+				IsAdmin:     user.IsAdmin,
 			}
 
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(userData)
 		})
-
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
-
-			err := service.DeleteBrand(id)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-			}
-		})
 	})
 }
@@ -11,7 +11,11 @@ import (
 )
 
 func GenerateBrandRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/brands", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/brands", func(router chi.Router) {
+		audited := auditMutation(service, "brands")
+
+		generateTrashRoutes(router, service, "brands", audited)
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			service.ListBrandsWithSortFilterPagination(
 				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
@@ -24,12 +28,12 @@ func GenerateBrandRoutes(mainRouter *chi.Mux, service services.Service) {
 				w,
 			)
 		})
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "brands")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			stringId := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetBrand, r, w, stringId)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			brand, err := utils.DecodeBody[services.Brand](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -37,36 +41,22 @@ func GenerateBrandRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreateBrand(brand)
+			createdID, err := service.CreateBrand(brand)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
-		})
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
 
-			brand, err := utils.DecodeBody[services.Brand](r, w)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
-
-			err = service.EditBrand(id, brand)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "brands"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteBrand(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				trashError(w, err)
 			}
 		})
 	})
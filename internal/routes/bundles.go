@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// generateBundleRoutes adds what a product is a bundle of, and the stock
+// invoiced bundles moved for it, to the products router.
+func generateBundleRoutes(router chi.Router, service services.Service, audited func(http.Handler) http.Handler) {
+	router.Get("/{id}/bundle", func(w http.ResponseWriter, r *http.Request) {
+		bundle, err := service.GetBundle(chi.URLParam(r, "id"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		utils.HttpJsonFromObject(bundle, w)
+	})
+	router.With(audited).Put("/{id}/bundle", func(w http.ResponseWriter, r *http.Request) {
+		bundle, err := utils.DecodeBody[services.Bundle](r, w)
+		if err != nil {
+			return
+		}
+
+		err = service.SetBundle(chi.URLParam(r, "id"), bundle)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+	})
+	router.Get("/{id}/stock-movements", func(w http.ResponseWriter, r *http.Request) {
+		movements, err := service.ListStockMovements(chi.URLParam(r, "id"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		utils.HttpJsonFromObject(movements, w)
+	})
+}
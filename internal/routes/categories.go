@@ -1,10 +1,12 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
@@ -12,10 +14,10 @@ import (
 )
 
 func GenerateCategoryRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.Get("/parent_categories", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/parent_categories", func(w http.ResponseWriter, r *http.Request) {
 		utils.ListFromQueryToResponse(service.ListParentCategories, r, w)
 	})
-	mainRouter.Get("/category_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/category_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("q")
 
 		slug, err := url.QueryUnescape(query)
@@ -25,7 +27,7 @@ func GenerateCategoryRoutes(mainRouter *chi.Mux, service services.Service) {
 
 		utils.ObjectFromQueryToResponse(service.GetCategoryBySlug, r, w, slug)
 	})
-	mainRouter.Get("/products_in_category/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/products_in_category/{id}", func(w http.ResponseWriter, r *http.Request) {
 		stringId := chi.URLParam(r, "id")
 		utils.ListFromQueryToResponseById(
 			service.ProductsInCategory,
@@ -35,7 +37,7 @@ func GenerateCategoryRoutes(mainRouter *chi.Mux, service services.Service) {
 		)
 	})
 
-	mainRouter.Get("/articles_in_category/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/articles_in_category/{id}", func(w http.ResponseWriter, r *http.Request) {
 		stringId := chi.URLParam(r, "id")
 		utils.ListFromQueryToResponseById(
 			service.ArticlesInCategory,
@@ -44,34 +46,67 @@ func GenerateCategoryRoutes(mainRouter *chi.Mux, service services.Service) {
 			stringId,
 		)
 	})
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/categories", func(router chi.Router) {
-		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
-			service.ListCategoriesWithSortFilterPagination(
-				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
-				utils.DefaultInput(r.URL.Query().Get("sort_direction"), ""),
-				r.URL.Query()["filter"],
-				r.URL.Query()["filter_operand"],
-				r.URL.Query()["filter_condition"],
-				r.URL.Query().Get("count_in_page"),
-				r.URL.Query().Get("offset"),
-				w,
-			)
+	mainRouter.With(middlewares.AdminOnly).Route("/categories", func(router chi.Router) {
+		audited := auditMutation(service, "categories")
+
+		generateTrashRoutes(router, service, "categories", audited)
+		generateGalleryRoutes(router, service, "categories", audited)
+		generateParameterOverrideRoutes(router, service, audited)
+
+		router.Get("/tree", func(w http.ResponseWriter, r *http.Request) {
+			utils.ListFromQueryToResponse(service.CategoryTree, r, w)
 		})
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			stringId := chi.URLParam(r, "id")
+		router.Get("/{id}/ancestors", func(w http.ResponseWriter, r *http.Request) {
+			ancestors, err := service.CategoryAncestors(chi.URLParam(r, "id"))
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, err.Error(), http.StatusNotFound)
 
-			utils.ObjectFromQueryToResponse(service.GetCategory, r, w, stringId)
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			utils.HttpJsonFromArray(ancestors, w)
 		})
+		router.Get("/{id}/descendants", func(w http.ResponseWriter, r *http.Request) {
+			descendants, err := service.CategoryDescendants(chi.URLParam(r, "id"))
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+
+				return
+			}
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
-			category, err := utils.DecodeBody[services.Category](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 
-			err = service.CreateCategory(category)
+			utils.HttpJsonFromArray(descendants, w)
+		})
+		router.With(audited).Post("/{id}/move", func(w http.ResponseWriter, r *http.Request) {
+			move, err := utils.DecodeBody[services.CategoryMove](r, w)
+			if err != nil {
+				return
+			}
+
+			err = service.MoveCategory(chi.URLParam(r, "id"), move)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+
+				return
+			}
+
+			if errors.Is(err, services.ErrCategoryCycle) {
+				http.Error(w, err.Error(), http.StatusConflict)
+
+				return
+			}
+
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
@@ -79,9 +114,25 @@ func GenerateCategoryRoutes(mainRouter *chi.Mux, service services.Service) {
 			}
 		})
 
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			service.ListCategoriesWithSortFilterPagination(
+				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
+				utils.DefaultInput(r.URL.Query().Get("sort_direction"), ""),
+				r.URL.Query()["filter"],
+				r.URL.Query()["filter_operand"],
+				r.URL.Query()["filter_condition"],
+				r.URL.Query().Get("count_in_page"),
+				r.URL.Query().Get("offset"),
+				w,
+			)
+		})
+		router.With(withETag(service, "categories")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			stringId := chi.URLParam(r, "id")
+
+			utils.ObjectFromQueryToResponse(service.GetCategory, r, w, stringId)
+		})
 
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			category, err := utils.DecodeBody[services.Category](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -89,19 +140,23 @@ func GenerateCategoryRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.EditCategory(id, category)
+			createdID, err := service.CreateCategory(category)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
+
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+
+		router.With(audited).Patch("/{id}", patchHandler(service, "categories"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteCategory(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				trashError(w, err)
 			}
 		})
 	})
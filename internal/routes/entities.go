@@ -12,10 +12,10 @@ import (
 )
 
 func GenerateEntityRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.Get("/parent_entities", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/parent_entities", func(w http.ResponseWriter, r *http.Request) {
 		utils.ListFromQueryToResponse(service.ListParentEntities, r, w)
 	})
-	mainRouter.Get("/entity_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/entity_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("q")
 
 		slug, err := url.QueryUnescape(query)
@@ -25,7 +25,7 @@ func GenerateEntityRoutes(mainRouter *chi.Mux, service services.Service) {
 
 		utils.ObjectFromQueryToResponse(service.GetEntityBySlug, r, w, slug)
 	})
-	mainRouter.Get("/products_in_entity/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/products_in_entity/{id}", func(w http.ResponseWriter, r *http.Request) {
 		stringId := chi.URLParam(r, "id")
 		utils.ListFromQueryToResponseById(
 			service.ProductsInEntity,
@@ -35,17 +35,21 @@ func GenerateEntityRoutes(mainRouter *chi.Mux, service services.Service) {
 		)
 	})
 
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/entities", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/entities", func(router chi.Router) {
+		audited := auditMutation(service, "entities")
+
+		generateTrashRoutes(router, service, "entities", audited)
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			utils.ListFromQueryToResponse(service.ListEntities, r, w)
 		})
 
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "entities")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			stringId := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetEntity, r, w, stringId)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			Entity, err := utils.DecodeBody[services.Entity](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -53,37 +57,23 @@ func GenerateEntityRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreateEntity(Entity)
+			createdID, err := service.CreateEntity(Entity)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
-		})
-
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
 
-			Entity, err := utils.DecodeBody[services.Entity](r, w)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
-
-			err = service.EditEntity(id, Entity)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+
+		router.With(audited).Patch("/{id}", patchHandler(service, "entities"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteEntity(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				trashError(w, err)
 			}
 		})
 	})
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/spreadsheet"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// exportFunc is one of the Service.Export methods.
+type exportFunc func(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	sheet spreadsheet.Writer,
+) error
+
+// exportHandler streams an export as an attachment named after name. It
+// takes the sort and filter parameters of the listing endpoints and a
+// format of csv (the default), xlsx or json.
+func exportHandler(name string, export exportFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := utils.DefaultInput(r.URL.Query().Get("format"), "csv")
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		sheet, err := spreadsheet.NewWriter(format, ww)
+		if errors.Is(err, spreadsheet.ErrUnknownFormat) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		fileName := name + "-" + time.Now().Format("20060102") + "." + format
+
+		w.Header().Set("Content-Type", spreadsheet.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+
+		err = export(
+			utils.DefaultInput(r.URL.Query().Get("sort"), ""),
+			utils.DefaultInput(r.URL.Query().Get("sort_direction"), ""),
+			r.URL.Query()["filter"],
+			r.URL.Query()["filter_operand"],
+			r.URL.Query()["filter_condition"],
+			sheet,
+		)
+		if err == nil {
+			err = sheet.Close()
+		}
+
+		if err == nil {
+			return
+		}
+
+		// Once rows went out the status is sent; the file is cut short.
+		if ww.BytesWritten() > 0 {
+			log.Printf("export %s: %v", name, err)
+
+			return
+		}
+
+		w.Header().Del("Content-Disposition")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
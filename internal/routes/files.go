@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/imaging"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// Uploaded files get random names and are never overwritten, so browsers
+// and CDNs may keep them for a year.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+func fileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrObjectNotFound), errors.Is(err, utils.ErrInvalidFileName):
+		http.Error(w, "file not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidSize), errors.Is(err, imaging.ErrUnknownFormat):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func serveOriginal(w http.ResponseWriter, r *http.Request, service services.Service, name string, cacheControl string) {
+	body, info, err := service.Storage().Get(name)
+	if err != nil {
+		fileError(w, err)
+
+		return
+	}
+	defer body.Close()
+
+	content, ok := body.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			fileError(w, err)
+
+			return
+		}
+
+		content = bytes.NewReader(data)
+	}
+
+	if info.ETag != "" {
+		w.Header().Set("ETag", info.ETag)
+	}
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+
+	http.ServeContent(w, r, name, info.LastModified, content)
+}
+
+func serveResized(w http.ResponseWriter, r *http.Request, service services.Service, name string, cacheControl string) {
+	var width, height int
+
+	var err error
+
+	if value := r.URL.Query().Get("w"); value != "" {
+		width, err = strconv.Atoi(value)
+		if err != nil {
+			fileError(w, services.ErrInvalidSize)
+
+			return
+		}
+	}
+
+	if value := r.URL.Query().Get("h"); value != "" {
+		height, err = strconv.Atoi(value)
+		if err != nil {
+			fileError(w, services.ErrInvalidSize)
+
+			return
+		}
+	}
+
+	path, err := service.ResizedFile(name, width, height, r.URL.Query().Get("format"))
+	if err != nil {
+		fileError(w, err)
+
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fileError(w, err)
+
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		fileError(w, err)
+
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()))
+	w.Header().Set("Cache-Control", cacheControl)
+	// The content type is sniffed, as the cached file has no extension.
+	http.ServeContent(w, r, "", stat.ModTime(), file)
+}
+
+func GenerateFileRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.Get("/files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		query := r.URL.Query()
+
+		cacheControl := immutableCacheControl
+
+		if query.Has("signature") {
+			if !utils.VerifyFileSignature(name, query.Get("expires"), query.Get("signature")) {
+				http.Error(w, "invalid or expired signature", http.StatusForbidden)
+
+				return
+			}
+
+			cacheControl = "private, no-cache"
+		}
+
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if query.Has("w") || query.Has("h") || query.Has("format") {
+			serveResized(w, r, service, name, cacheControl)
+
+			return
+		}
+
+		serveOriginal(w, r, service, name, cacheControl)
+	})
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+func galleryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// generateGalleryRoutes adds reading and reordering the image gallery of
+// resourceType to its router.
+func generateGalleryRoutes(
+	router chi.Router,
+	service services.Service,
+	resourceType string,
+	audited func(http.Handler) http.Handler,
+) {
+	router.Get("/{id}/gallery", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		gallery, err := service.GetGallery(resourceType, id)
+		if err != nil {
+			galleryError(w, err)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(gallery)
+	})
+	router.With(audited).Put("/{id}/gallery", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		gallery, err := utils.DecodeBody[services.Gallery](r, w)
+		if err != nil {
+			return
+		}
+
+		err = service.SetGallery(resourceType, id, gallery)
+		if err != nil {
+			galleryError(w, err)
+
+			return
+		}
+	})
+}
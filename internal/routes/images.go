@@ -11,17 +11,19 @@ import (
 )
 
 func GenerateImageRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/images", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/images", func(router chi.Router) {
+		audited := auditMutation(service, "images")
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			utils.ListFromQueryToResponse(service.ListImages, r, w)
 		})
 
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "images")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			stringId := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetImage, r, w, stringId)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			image, err := utils.DecodeBody[services.Image](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -29,31 +31,17 @@ func GenerateImageRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreateImage(image)
+			createdID, err := service.CreateImage(image)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
-		})
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
-
-			image, err := utils.DecodeBody[services.Image](r, w)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
 
-				return
-			}
-
-			err = service.EditImage(id, image)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "images"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteImage(id)
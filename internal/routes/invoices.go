@@ -12,7 +12,13 @@ import (
 )
 
 func GenerateInvoiceRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/invoices", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/invoices", func(router chi.Router) {
+		audited := auditMutation(service, "invoices")
+
+		generateTrashRoutes(router, service, "invoices", audited)
+
+		router.Get("/export", exportHandler("invoices", service.ExportInvoices))
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			service.ListInvoicesWithSortFilterPagination(
 				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
@@ -25,12 +31,12 @@ func GenerateInvoiceRoutes(mainRouter *chi.Mux, service services.Service) {
 				w,
 			)
 		})
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "invoices")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			stringId := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetInvoice, r, w, stringId)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			invoice, err := utils.DecodeBody[services.Invoice](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -40,39 +46,44 @@ func GenerateInvoiceRoutes(mainRouter *chi.Mux, service services.Service) {
 
 			fmt.Println(invoice.Date)
 
-			err = service.CreateInvoice(invoice)
+			createdID, err := service.CreateInvoice(invoice)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
+
+			setCreated(w, r, createdID)
 		})
 
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "invoices"))
+		router.Post("/{id}/email", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
-			invoice, err := utils.DecodeBody[services.Invoice](r, w)
+			type Body struct {
+				Email string `json:"email"`
+			}
+
+			body, err := utils.DecodeBody[Body](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 
-			fmt.Println(invoice.Date)
-
-			err = service.EditInvoice(id, invoice)
+			err = service.SendInvoiceEmail(id, body.Email)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteInvoice(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				trashError(w, err)
 			}
 		})
 	})
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/imaging"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+func uploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, imaging.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// uploadImage stores the uploaded file as a new images row.
+func uploadImage(w http.ResponseWriter, r *http.Request, service services.Service) (services.Image, error) {
+	data, name, err := utils.ReadUpload(w, r)
+	if err != nil {
+		return services.Image{}, err
+	}
+
+	image, err := service.UploadImage(data, name)
+	if err != nil {
+		uploadError(w, err)
+
+		return image, err
+	}
+
+	w.Header().Set("Location", "/images/"+image.ID.String())
+
+	return image, nil
+}
+
+func GenerateMediaRoutes(mainRouter *chi.Mux, service services.Service) {
+	audited := auditMutation(service, "images")
+
+	mainRouter.With(middlewares.AdminOnly, audited).Post("/images/upload", func(w http.ResponseWriter, r *http.Request) {
+		image, err := uploadImage(w, r, service)
+		if err != nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(image)
+	})
+
+	// The older endpoint answers with the bare file name only.
+	mainRouter.With(middlewares.AdminOnly, audited).Post("/upload-file", func(w http.ResponseWriter, r *http.Request) {
+		image, err := uploadImage(w, r, service)
+		if err != nil {
+			return
+		}
+
+		fmt.Fprint(w, image.ImageUrl)
+	})
+
+	mainRouter.With(middlewares.AdminOnly).Route("/media/gc", func(router chi.Router) {
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			report, err := service.CollectMediaGarbage(true)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+		})
+
+		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			report, err := service.CollectMediaGarbage(false)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+		})
+	})
+}
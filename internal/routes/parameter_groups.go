@@ -11,8 +11,10 @@ import (
 )
 
 func GenerateParameterGroupsRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.With(middlewares.AdminOrReadOnly).
+	mainRouter.With(middlewares.AdminOnly).
 		Route("/parameter-groups", func(router chi.Router) {
+			audited := auditMutation(service, "parameter_groups")
+
 			router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 				service.ListParameterGroupsWithSortFilterPagination(
 					utils.DefaultInput(r.URL.Query().Get("sort"), ""),
@@ -26,12 +28,12 @@ func GenerateParameterGroupsRoutes(mainRouter *chi.Mux, service services.Service
 				)
 			})
 
-			router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			router.With(withETag(service, "parameter_groups")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 				stringId := chi.URLParam(r, "id")
 				utils.ObjectFromQueryToResponse(service.GetParameterGroup, r, w, stringId)
 			})
 
-			router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 				parameterGroup, err := utils.DecodeBody[services.ParameterGroup](r, w)
 				if err != nil {
 					http.Error(w, err.Error(), http.StatusBadRequest)
@@ -39,31 +41,17 @@ func GenerateParameterGroupsRoutes(mainRouter *chi.Mux, service services.Service
 					return
 				}
 
-				err = service.CreateParameterGroup(parameterGroup)
+				createdID, err := service.CreateParameterGroup(parameterGroup)
 				if err != nil {
 					http.Error(w, err.Error(), http.StatusBadRequest)
 
 					return
 				}
-			})
-			router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-				id := chi.URLParam(r, "id")
-
-				parameterGroup, err := utils.DecodeBody[services.ParameterGroup](r, w)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
 
-					return
-				}
-
-				err = service.EditParameterGroup(id, parameterGroup)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
-
-					return
-				}
+				setCreated(w, r, createdID)
 			})
-			router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			router.With(audited).Patch("/{id}", patchHandler(service, "parameter_groups"))
+			router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 				id := chi.URLParam(r, "id")
 
 				err := service.DeleteParameterGroup(id)
@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
@@ -11,7 +13,9 @@ import (
 )
 
 func GenerateParametersRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/parameters", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/parameters", func(router chi.Router) {
+		audited := auditMutation(service, "parameters")
+
 		router.Get("/by-category/{id}", func(w http.ResponseWriter, r *http.Request) {
 			categoryId := chi.URLParam(r, "id")
 			utils.ListFromQueryToResponseById(service.ListParametersByCategory, r, w, categoryId)
@@ -28,12 +32,12 @@ func GenerateParametersRoutes(mainRouter *chi.Mux, service services.Service) {
 				w,
 			)
 		})
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "parameters")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			stringId := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetParameter, r, w, stringId)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			parameter, err := utils.DecodeBody[services.Parameter](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -41,31 +45,17 @@ func GenerateParametersRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreateParameter(parameter)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
-		})
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
-
-			parameter, err := utils.DecodeBody[services.Parameter](r, w)
+			createdID, err := service.CreateParameter(parameter)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 
-			err = service.EditParameter(id, parameter)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "parameters"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteParameter(id)
@@ -75,3 +65,44 @@ func GenerateParametersRoutes(mainRouter *chi.Mux, service services.Service) {
 		})
 	})
 }
+
+// generateParameterOverrideRoutes adds the parameter schema of a category,
+// and how it overrides the parameters it inherits, to the categories router.
+func generateParameterOverrideRoutes(router chi.Router, service services.Service, audited func(http.Handler) http.Handler) {
+	router.Get("/{id}/parameters", func(w http.ResponseWriter, r *http.Request) {
+		utils.ListFromQueryToResponseById(service.ListCategoryParameterSchema, r, w, chi.URLParam(r, "id"))
+	})
+	router.With(audited).Put("/{id}/parameters/{parameterId}", func(w http.ResponseWriter, r *http.Request) {
+		override, err := utils.DecodeBody[services.ParameterOverride](r, w)
+		if err != nil {
+			return
+		}
+
+		err = service.SetParameterOverride(chi.URLParam(r, "id"), chi.URLParam(r, "parameterId"), override)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+	})
+	router.With(audited).Delete("/{id}/parameters/{parameterId}", func(w http.ResponseWriter, r *http.Request) {
+		err := service.DeleteParameterOverride(chi.URLParam(r, "id"), chi.URLParam(r, "parameterId"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// etag formats the updated_at of a row as its entity tag.
+func etag(version time.Time) string {
+	return `"` + strconv.FormatInt(version.UnixMicro(), 36) + `"`
+}
+
+// ifMatchVersions returns the versions named by the If-Match header, or
+// none when there is no header or it is "*". Tags not made by etag can
+// never match, so a header with only those is not ok.
+func ifMatchVersions(r *http.Request) ([]time.Time, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	var versions []time.Time
+
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+		micros, err := strconv.ParseInt(strings.Trim(tag, `"`), 36, 64)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, time.UnixMicro(micros))
+	}
+
+	return versions, len(versions) > 0
+}
+
+// withETag sets the ETag of the resourceType row {id} on the response, for
+// clients to send back in If-Match when they patch it.
+func withETag(service services.Service, resourceType string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version, err := service.ResourceVersion(resourceType, chi.URLParam(r, "id"))
+			if err == nil {
+				w.Header().Set("ETag", etag(version))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// patchHandler applies the request body to the resourceType row {id} as a
+// JSON merge patch. With an If-Match header the row is only changed if it
+// still has one of its ETags; the response carries the new one.
+func patchHandler(service services.Service, resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		versions, ok := ifMatchVersions(r)
+		if !ok {
+			http.Error(w, services.ErrPreconditionFailed.Error(), http.StatusPreconditionFailed)
+
+			return
+		}
+
+		patch, err := utils.DecodeBody[map[string]json.RawMessage](r, w)
+		if err != nil {
+			return
+		}
+
+		updatedAt, err := service.PatchResource(resourceType, id, patch, versions)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if errors.Is(err, services.ErrPreconditionFailed) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("ETag", etag(updatedAt))
+	}
+}
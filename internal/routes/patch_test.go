@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIfMatchVersionsSplitsTagLists(t *testing.T) {
+	first := time.UnixMicro(1700000000000000)
+	second := time.UnixMicro(1700000000000001)
+
+	tests := []struct {
+		header   string
+		versions []time.Time
+		ok       bool
+	}{
+		{header: "", ok: true},
+		{header: "*", ok: true},
+		{header: etag(first), versions: []time.Time{first}, ok: true},
+		{header: etag(first) + ", W/" + etag(second), versions: []time.Time{first, second}, ok: true},
+		{header: `"not-a-tag!", ` + etag(second), versions: []time.Time{second}, ok: true},
+		{header: `"not-a-tag!"`, ok: false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("PATCH", "/", nil)
+		if test.header != "" {
+			r.Header.Set("If-Match", test.header)
+		}
+
+		versions, ok := ifMatchVersions(r)
+		if ok != test.ok || !slices.EqualFunc(versions, test.versions, time.Time.Equal) {
+			t.Errorf("If-Match %q: got %v, %v; want %v, %v", test.header, versions, ok, test.versions, test.ok)
+		}
+	}
+}
@@ -11,7 +11,13 @@ import (
 )
 
 func GeneratePersonRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/persons", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/persons", func(router chi.Router) {
+		audited := auditMutation(service, "persons")
+
+		generateTrashRoutes(router, service, "persons", audited)
+
+		router.Get("/export", exportHandler("persons", service.ExportPersons))
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			service.ListPersonsWithSortFilterPagination(
 				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
@@ -24,12 +30,12 @@ func GeneratePersonRoutes(mainRouter *chi.Mux, service services.Service) {
 				w,
 			)
 		})
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "persons")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			stringId := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetPerson, r, w, stringId)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			brand, err := utils.DecodeBody[services.Person](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -37,36 +43,22 @@ func GeneratePersonRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreatePerson(brand)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
-		})
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
-
-			brand, err := utils.DecodeBody[services.Person](r, w)
+			createdID, err := service.CreatePerson(brand)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 
-			err = service.EditPerson(id, brand)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-
-				return
-			}
+			setCreated(w, r, createdID)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "persons"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeletePerson(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				trashError(w, err)
 			}
 		})
 	})
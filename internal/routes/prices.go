@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+func scheduledPriceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrScheduleOverlap):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// generatePriceRoutes adds the price history and scheduled prices of a
+// product to the products router.
+func generatePriceRoutes(router chi.Router, service services.Service, audited func(http.Handler) http.Handler) {
+	router.Get("/{id}/price-history", func(w http.ResponseWriter, r *http.Request) {
+		utils.ListFromQueryToResponseById(service.ListPriceHistory, r, w, chi.URLParam(r, "id"))
+	})
+	router.Get("/{id}/scheduled-prices", func(w http.ResponseWriter, r *http.Request) {
+		utils.ListFromQueryToResponseById(service.ListScheduledPrices, r, w, chi.URLParam(r, "id"))
+	})
+	router.With(audited).Post("/{id}/scheduled-prices", func(w http.ResponseWriter, r *http.Request) {
+		schedule, err := utils.DecodeBody[services.ScheduledPrice](r, w)
+		if err != nil {
+			return
+		}
+
+		createdID, err := service.CreateScheduledPrice(chi.URLParam(r, "id"), schedule)
+		if err != nil {
+			scheduledPriceError(w, err)
+
+			return
+		}
+
+		setCreated(w, r, createdID)
+	})
+	router.With(audited).Delete("/{id}/scheduled-prices/{scheduleID}", func(w http.ResponseWriter, r *http.Request) {
+		err := service.DeleteScheduledPrice(chi.URLParam(r, "id"), chi.URLParam(r, "scheduleID"))
+		if err != nil {
+			scheduledPriceError(w, err)
+
+			return
+		}
+	})
+}
@@ -1,21 +1,24 @@
 package routes
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/spreadsheet"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
 )
 
 func GenerateProductRoutes(mainRouter *chi.Mux, service services.Service) {
-	mainRouter.Get("/recently_added_products", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/recently_added_products", func(w http.ResponseWriter, r *http.Request) {
 		utils.ListFromQueryToResponse(service.RecentlyAddedProducts, r, w)
 	})
-	mainRouter.Get("/product_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/product_by_slug/search", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("q")
 
 		slug, err := url.QueryUnescape(query)
@@ -25,7 +28,7 @@ func GenerateProductRoutes(mainRouter *chi.Mux, service services.Service) {
 
 		utils.ObjectFromQueryToResponse(service.GetProductBySlug, r, w, slug)
 	})
-	mainRouter.Get("/products/search", func(w http.ResponseWriter, r *http.Request) {
+	mainRouter.With(middlewares.AdminOnly).Get("/products/search", func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("q")
 
 		keyword, err := url.QueryUnescape(query)
@@ -35,16 +38,12 @@ func GenerateProductRoutes(mainRouter *chi.Mux, service services.Service) {
 
 		utils.ObjectFromQueryToResponse(service.ProductsSearch, r, w, keyword)
 	})
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/generate", func(router chi.Router) {
-		router.Get("/products", func(w http.ResponseWriter, r *http.Request) {
-			utils.ListFromQueryToResponse(service.GenerateProducts, r, w)
-		})
-		router.Get("/delete", func(w http.ResponseWriter, r *http.Request) {
-			utils.ListFromQueryToResponse(service.DeleteGeneratedProducts, r, w)
-		})
+	mainRouter.With(middlewares.AdminOnly).Route("/generate", func(router chi.Router) {
+		router.Get("/products", generateHandler(service, service.GenerateProducts))
+		router.Get("/delete", generateHandler(service, service.DeleteGeneratedProducts))
 	})
 
-	mainRouter.With(middlewares.AdminOrReadOnly).
+	mainRouter.With(middlewares.AdminOnly).
 		Route("/products_for_accounts", func(router chi.Router) {
 			router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 				service.ListProductForAccountsWithSortFilterPagination(
@@ -59,7 +58,19 @@ func GenerateProductRoutes(mainRouter *chi.Mux, service services.Service) {
 				)
 			})
 		})
-	mainRouter.With(middlewares.AdminOrReadOnly).Route("/products", func(router chi.Router) {
+	mainRouter.With(middlewares.AdminOnly).Route("/products", func(router chi.Router) {
+		audited := auditMutation(service, "products")
+
+		generateTrashRoutes(router, service, "products", audited)
+
+		router.Get("/export", exportHandler("products", service.ExportProducts))
+		generateGalleryRoutes(router, service, "products", audited)
+		generatePriceRoutes(router, service, audited)
+		generateFitmentRoutes(router, service, audited)
+		generateVariantRoutes(router, service, audited)
+		generateRelationRoutes(router, service, audited)
+		generateBundleRoutes(router, service, audited)
+
 		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			service.ListProductsWithSortFilterPagination(
 				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
@@ -73,12 +84,12 @@ func GenerateProductRoutes(mainRouter *chi.Mux, service services.Service) {
 			)
 		})
 
-		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(withETag(service, "products")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 			utils.ObjectFromQueryToResponse(service.GetProduct, r, w, id)
 		})
 
-		router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
 			product, err := utils.DecodeBody[services.Product](r, w)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -86,37 +97,110 @@ func GenerateProductRoutes(mainRouter *chi.Mux, service services.Service) {
 				return
 			}
 
-			err = service.CreateProduct(product)
+			createdID, err := service.CreateProduct(product)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
-		})
-		router.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			id := chi.URLParam(r, "id")
 
-			product, err := utils.DecodeBody[services.Product](r, w)
+			setCreated(w, r, createdID)
+		})
+		router.Post("/bulk", func(w http.ResponseWriter, r *http.Request) {
+			edit, err := utils.DecodeBody[services.BulkEdit](r, w)
 			if err != nil {
+				return
+			}
+
+			result, err := service.BulkEditProducts(edit)
+			if errors.Is(err, services.ErrBulkEditInvalid) || errors.Is(err, services.ErrBulkEditNoTarget) {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
 
-			err = service.EditProduct(id, product)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			if !result.Preview {
+				recordAudits(service, r, result.Audit)
+			}
+
+			utils.HttpJsonFromObject(result, w)
+		})
+		router.Post("/import", func(w http.ResponseWriter, r *http.Request) {
+			data, name, err := utils.ReadUpload(w, r)
+			if err != nil {
+				return
+			}
+
+			rows, err := spreadsheet.Read(data, name)
+			if errors.Is(err, spreadsheet.ErrUnsupportedFormat) {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+
+				return
+			}
+
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 
 				return
 			}
+
+			report, err := service.ImportProducts(rows, r.URL.Query().Get("dry_run") == "true")
+			if errors.Is(err, services.ErrEmptyImport) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			if report.Committed {
+				recordAudits(service, r, report.Audit)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+
+			if len(report.Errors) > 0 {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			}
+
+			json.NewEncoder(w).Encode(report)
 		})
-		router.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		router.With(audited).Patch("/{id}", patchHandler(service, "products"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
 			id := chi.URLParam(r, "id")
 
 			err := service.DeleteProduct(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				trashError(w, err)
 			}
 		})
 	})
 }
+
+// generateHandler runs a product generator action and records the audit
+// entries it returns, one per product it changed. It answers with an
+// empty list as the generator routes always have.
+func generateHandler(service services.Service, action func() ([]services.AuditLog, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := action()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		recordAudits(service, r, entries)
+
+		utils.HttpJsonFromArray([]services.Product{}, w)
+	}
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+// GenerateQuestionRoutes adds answering and moderating the questions
+// customers ask about products.
+func GenerateQuestionRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.With(middlewares.AdminOnly).Route("/questions", func(router chi.Router) {
+		audited := auditMutation(service, "questions")
+
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			limit, offset, err := pageParams(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			page, err := service.ListQuestions(
+				r.URL.Query().Get("status"),
+				r.URL.Query().Get("product_id"),
+				r.URL.Query().Get("unanswered") == "true",
+				limit,
+				offset,
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			utils.HttpJsonFromObject(page, w)
+		})
+		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			utils.ObjectFromQueryToResponse(service.GetQuestion, r, w, chi.URLParam(r, "id"))
+		})
+		router.With(audited).Put("/{id}/answer", func(w http.ResponseWriter, r *http.Request) {
+			user, err := utils.ParseUserFromRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+
+				return
+			}
+
+			type Body struct {
+				Answer string `json:"answer"`
+			}
+
+			body, err := utils.DecodeBody[Body](r, w)
+			if err != nil {
+				return
+			}
+
+			err = service.AnswerQuestion(chi.URLParam(r, "id"), user.ID, body.Answer)
+			if err != nil {
+				moderationError(w, err)
+
+				return
+			}
+		})
+		router.With(audited).Post("/{id}/approve", moderateHandler(service.ModerateQuestion, "approved"))
+		router.With(audited).Post("/{id}/reject", moderateHandler(service.ModerateQuestion, "rejected"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			err := service.DeleteQuestion(chi.URLParam(r, "id"))
+			if err != nil {
+				moderationError(w, err)
+
+				return
+			}
+		})
+	})
+}
@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// generateRelationRoutes adds the products a product is curated with, by
+// relation kind, to the products router.
+func generateRelationRoutes(router chi.Router, service services.Service, audited func(http.Handler) http.Handler) {
+	router.Get("/{id}/relations", func(w http.ResponseWriter, r *http.Request) {
+		relations, err := service.ListProductRelations(chi.URLParam(r, "id"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		utils.HttpJsonFromObject(relations, w)
+	})
+	router.With(audited).Put("/{id}/relations/{kind}", func(w http.ResponseWriter, r *http.Request) {
+		ids, err := utils.DecodeBody[services.RelatedProductIDs](r, w)
+		if err != nil {
+			return
+		}
+
+		err = service.SetProductRelations(chi.URLParam(r, "id"), chi.URLParam(r, "kind"), ids)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+	})
+}
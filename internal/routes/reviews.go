@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+func moderationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// moderateHandler sets the {id} review or question to status with moderate,
+// on behalf of the admin making the request.
+func moderateHandler(moderate func(id string, moderatorID string, status string) error, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := utils.ParseUserFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+
+		err = moderate(chi.URLParam(r, "id"), user.ID, status)
+		if err != nil {
+			moderationError(w, err)
+
+			return
+		}
+	}
+}
+
+// GenerateReviewRoutes adds the moderation queue of customer reviews.
+func GenerateReviewRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.With(middlewares.AdminOnly).Route("/reviews", func(router chi.Router) {
+		audited := auditMutation(service, "reviews")
+
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			limit, offset, err := pageParams(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			page, err := service.ListReviews(
+				r.URL.Query().Get("status"),
+				r.URL.Query().Get("product_id"),
+				limit,
+				offset,
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			utils.HttpJsonFromObject(page, w)
+		})
+		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			utils.ObjectFromQueryToResponse(service.GetReview, r, w, chi.URLParam(r, "id"))
+		})
+		router.With(audited).Post("/{id}/approve", moderateHandler(service.ModerateReview, "approved"))
+		router.With(audited).Post("/{id}/reject", moderateHandler(service.ModerateReview, "rejected"))
+		router.With(audited).Put("/{id}/reply", func(w http.ResponseWriter, r *http.Request) {
+			type Body struct {
+				Reply string `json:"reply"`
+			}
+
+			body, err := utils.DecodeBody[Body](r, w)
+			if err != nil {
+				return
+			}
+
+			err = service.ReplyToReview(chi.URLParam(r, "id"), body.Reply)
+			if err != nil {
+				moderationError(w, err)
+
+				return
+			}
+		})
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			err := service.DeleteReview(chi.URLParam(r, "id"))
+			if err != nil {
+				moderationError(w, err)
+
+				return
+			}
+		})
+	})
+}
@@ -0,0 +1,228 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+// slugParam returns the {slug} URL param, decoding it when the client sent
+// it escaped.
+func slugParam(r *http.Request) string {
+	slug := chi.URLParam(r, "slug")
+
+	if unescaped, err := url.PathUnescape(slug); err == nil {
+		return unescaped
+	}
+
+	return slug
+}
+
+// pageParams returns the count_in_page and offset query params, zero when
+// they are not given.
+func pageParams(r *http.Request) (limit int, offset int, err error) {
+	if countInPage := r.URL.Query().Get("count_in_page"); countInPage != "" {
+		limit, err = strconv.Atoi(countInPage)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		offset, err = strconv.Atoi(offsetParam)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// storeObject answers with the object found by slug, or 404 when there is
+// no visible one.
+func storeObject[T any](find func(string) (T, error), w http.ResponseWriter, r *http.Request) {
+	object, err := find(slugParam(r))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	utils.HttpJsonFromObject(object, w)
+}
+
+// GenerateStorefrontRoutes adds the public storefront API. It only serves
+// visible products, categories and articles, without admin fields, the
+// vehicle catalog, product comparison, approved reviews and answered
+// questions; signed in customers can review products and ask about them.
+// Every other route needs an admin.
+func GenerateStorefrontRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.Route("/store", func(router chi.Router) {
+		router.Get("/products", func(w http.ResponseWriter, r *http.Request) {
+			limit, offset, err := pageParams(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			page, err := service.StoreProducts(
+				r.URL.Query().Get("category"),
+				r.URL.Query().Get("vehicle"),
+				r.URL.Query().Get("q"),
+				r.URL.Query().Get("sort"),
+				limit,
+				offset,
+			)
+			if errors.Is(err, services.ErrInvalidVehicle) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			utils.HttpJsonFromObject(page, w)
+		})
+		router.Get("/products/recent", func(w http.ResponseWriter, r *http.Request) {
+			utils.ListFromQueryToResponse(service.StoreRecentProducts, r, w)
+		})
+		router.Get("/products/{slug}", func(w http.ResponseWriter, r *http.Request) {
+			storeObject(service.StoreProduct, w, r)
+		})
+		router.Get("/products/{slug}/reviews", func(w http.ResponseWriter, r *http.Request) {
+			limit, offset, err := pageParams(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			storeObject(func(slug string) (services.PublicReviewPage, error) {
+				return service.StoreReviews(slug, limit, offset)
+			}, w, r)
+		})
+		router.With(middlewares.Authenticated).Post("/products/{slug}/reviews", func(w http.ResponseWriter, r *http.Request) {
+			user, err := utils.ParseUserFromRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+
+				return
+			}
+
+			review, err := utils.DecodeBody[services.Review](r, w)
+			if err != nil {
+				return
+			}
+
+			createdID, err := service.SubmitReview(user.ID, slugParam(r), review)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "not found", http.StatusNotFound)
+
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			setCreated(w, r, createdID)
+		})
+
+		router.With(middlewares.Authenticated).Post("/products/{slug}/questions", func(w http.ResponseWriter, r *http.Request) {
+			user, err := utils.ParseUserFromRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+
+				return
+			}
+
+			question, err := utils.DecodeBody[services.Question](r, w)
+			if err != nil {
+				return
+			}
+
+			createdID, err := service.AskQuestion(user.ID, slugParam(r), question)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "not found", http.StatusNotFound)
+
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			setCreated(w, r, createdID)
+		})
+
+		router.Get("/compare", func(w http.ResponseWriter, r *http.Request) {
+			// ids can be repeated or comma separated.
+			var ids []string
+
+			for _, param := range r.URL.Query()["ids"] {
+				for id := range strings.SplitSeq(param, ",") {
+					if id = strings.TrimSpace(id); id != "" {
+						ids = append(ids, id)
+					}
+				}
+			}
+
+			comparison, err := service.CompareProducts(ids)
+			if errors.Is(err, services.ErrInvalidComparison) || errors.Is(err, services.ErrUnrelatedProducts) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			utils.HttpJsonFromObject(comparison, w)
+		})
+
+		router.Get("/categories", func(w http.ResponseWriter, r *http.Request) {
+			utils.ListFromQueryToResponse(service.StoreCategories, r, w)
+		})
+		router.Get("/categories/{slug}", func(w http.ResponseWriter, r *http.Request) {
+			storeObject(service.StoreCategory, w, r)
+		})
+
+		router.Get("/vehicles", func(w http.ResponseWriter, r *http.Request) {
+			utils.ListFromQueryToResponse(service.ListVehicleTree, r, w)
+		})
+
+		router.Get("/articles", func(w http.ResponseWriter, r *http.Request) {
+			utils.ListFromQueryToResponseById(service.StoreArticles, r, w, r.URL.Query().Get("category"))
+		})
+		router.Get("/articles/{slug}", func(w http.ResponseWriter, r *http.Request) {
+			storeObject(service.StoreArticle, w, r)
+		})
+	})
+}
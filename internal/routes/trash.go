@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+)
+
+func trashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotInTrash), errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrTrashedReference), errors.Is(err, services.ErrRestoreConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// generateTrashRoutes adds the trash view, restore and purge endpoints of
+// resourceType to its router.
+func generateTrashRoutes(
+	router chi.Router,
+	service services.Service,
+	resourceType string,
+	audited func(http.Handler) http.Handler,
+) {
+	router.Get("/trash", func(w http.ResponseWriter, r *http.Request) {
+		service.ListTrash(
+			resourceType,
+			r.URL.Query().Get("count_in_page"),
+			r.URL.Query().Get("offset"),
+			w,
+		)
+	})
+	router.With(audited).Post("/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		err := service.RestoreFromTrash(resourceType, id)
+		if err != nil {
+			trashError(w, err)
+
+			return
+		}
+	})
+	router.With(audited).Delete("/{id}/purge", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		err := service.PurgeFromTrash(resourceType, id)
+		if err != nil {
+			trashError(w, err)
+
+			return
+		}
+	})
+}
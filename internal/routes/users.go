@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+func userUpdateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrLastAdmin) {
+		http.Error(w, err.Error(), http.StatusConflict)
+
+		return
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+func GenerateUserRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.With(middlewares.AdminOnly).Route("/users", func(router chi.Router) {
+		audited := auditMutation(service, "users")
+
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			service.ListUsersWithSortFilterPagination(
+				utils.DefaultInput(r.URL.Query().Get("sort"), ""),
+				utils.DefaultInput(r.URL.Query().Get("sort_direction"), ""),
+				r.URL.Query()["filter"],
+				r.URL.Query()["filter_operand"],
+				r.URL.Query()["filter_condition"],
+				r.URL.Query().Get("count_in_page"),
+				r.URL.Query().Get("offset"),
+				w,
+			)
+		})
+		router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			stringId := chi.URLParam(r, "id")
+			utils.ObjectFromQueryToResponse(service.GetUserByID, r, w, stringId)
+		})
+		router.With(audited).Patch("/{id}/role", func(w http.ResponseWriter, r *http.Request) {
+			id := chi.URLParam(r, "id")
+
+			type Body struct {
+				IsAdmin bool `json:"isAdmin"`
+			}
+
+			body, err := utils.DecodeBody[Body](r, w)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			err = service.SetUserRole(id, body.IsAdmin)
+			if err != nil {
+				userUpdateError(w, err)
+
+				return
+			}
+		})
+		router.With(audited).Patch("/{id}/status", func(w http.ResponseWriter, r *http.Request) {
+			id := chi.URLParam(r, "id")
+
+			type Body struct {
+				Disabled bool `json:"disabled"`
+			}
+
+			body, err := utils.DecodeBody[Body](r, w)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			err = service.SetUserDisabled(id, body.Disabled)
+			if err != nil {
+				userUpdateError(w, err)
+
+				return
+			}
+		})
+		router.With(audited).Post("/{id}/force_password_reset", func(w http.ResponseWriter, r *http.Request) {
+			id := chi.URLParam(r, "id")
+
+			err := service.ForcePasswordReset(id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+		})
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id := chi.URLParam(r, "id")
+
+			err := service.DeleteUser(id)
+			if err != nil {
+				userUpdateError(w, err)
+			}
+		})
+	})
+}
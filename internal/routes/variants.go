@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+func variantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrDuplicateVariant):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func GenerateVariantRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.With(middlewares.AdminOnly).Route("/variants", func(router chi.Router) {
+		audited := auditMutation(service, "product_variants")
+
+		router.With(withETag(service, "product_variants")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			utils.ObjectFromQueryToResponse(service.GetVariant, r, w, chi.URLParam(r, "id"))
+		})
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
+			variant, err := utils.DecodeBody[services.Variant](r, w)
+			if err != nil {
+				return
+			}
+
+			createdID, err := service.CreateVariant(variant)
+			if err != nil {
+				variantError(w, err)
+
+				return
+			}
+
+			setCreated(w, r, createdID)
+		})
+		router.With(audited).Patch("/{id}", patchHandler(service, "product_variants"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			err := service.DeleteVariant(chi.URLParam(r, "id"))
+			if err != nil {
+				variantError(w, err)
+
+				return
+			}
+		})
+
+		generateGalleryRoutes(router, service, "product_variants", audited)
+	})
+}
+
+// generateVariantRoutes adds the variant axes and variants of a product to
+// the products router.
+func generateVariantRoutes(router chi.Router, service services.Service, audited func(http.Handler) http.Handler) {
+	router.Get("/{id}/variants", func(w http.ResponseWriter, r *http.Request) {
+		variants, err := service.ListVariants(chi.URLParam(r, "id"))
+		if err != nil {
+			variantError(w, err)
+
+			return
+		}
+
+		utils.HttpJsonFromObject(variants, w)
+	})
+	router.With(audited).Put("/{id}/variant-axes", func(w http.ResponseWriter, r *http.Request) {
+		axes, err := utils.DecodeBody[services.VariantAxes](r, w)
+		if err != nil {
+			return
+		}
+
+		err = service.SetVariantAxes(chi.URLParam(r, "id"), axes)
+		if err != nil {
+			variantError(w, err)
+
+			return
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/services"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/middlewares"
+)
+
+func vehicleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrVehicleHasChildren):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func GenerateVehicleRoutes(mainRouter *chi.Mux, service services.Service) {
+	mainRouter.With(middlewares.AdminOnly).Route("/vehicles", func(router chi.Router) {
+		audited := auditMutation(service, "vehicles")
+
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			utils.ListFromQueryToResponse(service.ListVehicleTree, r, w)
+		})
+		router.With(withETag(service, "vehicles")).Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			utils.ObjectFromQueryToResponse(service.GetVehicle, r, w, chi.URLParam(r, "id"))
+		})
+		router.With(audited).Post("/", func(w http.ResponseWriter, r *http.Request) {
+			vehicle, err := utils.DecodeBody[services.Vehicle](r, w)
+			if err != nil {
+				return
+			}
+
+			createdID, err := service.CreateVehicle(vehicle)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			setCreated(w, r, createdID)
+		})
+		router.With(audited).Patch("/{id}", patchHandler(service, "vehicles"))
+		router.With(audited).Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			err := service.DeleteVehicle(chi.URLParam(r, "id"))
+			if err != nil {
+				vehicleError(w, err)
+
+				return
+			}
+		})
+	})
+}
+
+// generateFitmentRoutes adds the vehicles a product fits and their bulk
+// assignment by category to the products router.
+func generateFitmentRoutes(router chi.Router, service services.Service, audited func(http.Handler) http.Handler) {
+	router.Get("/{id}/fitment", func(w http.ResponseWriter, r *http.Request) {
+		fitment, err := service.GetFitment(chi.URLParam(r, "id"))
+		if err != nil {
+			vehicleError(w, err)
+
+			return
+		}
+
+		utils.HttpJsonFromObject(fitment, w)
+	})
+	router.With(audited).Put("/{id}/fitment", func(w http.ResponseWriter, r *http.Request) {
+		fitment, err := utils.DecodeBody[services.Fitment](r, w)
+		if err != nil {
+			return
+		}
+
+		err = service.SetFitment(chi.URLParam(r, "id"), fitment)
+		if err != nil {
+			vehicleError(w, err)
+
+			return
+		}
+	})
+	router.With(audited).Post("/fitment/bulk", func(w http.ResponseWriter, r *http.Request) {
+		bulk, err := utils.DecodeBody[services.BulkFitment](r, w)
+		if err != nil {
+			return
+		}
+
+		affected, err := service.BulkFitCategory(bulk)
+		if err != nil {
+			vehicleError(w, err)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Affected int64 `json:"affected"`
+		}{affected})
+	})
+}
@@ -146,7 +146,7 @@ func (s *Service) GetArticleBySlug(slug string) (Article, error) {
 	return article, nil
 }
 
-func (s *Service) CreateArticle(article Article) error {
+func (s *Service) CreateArticle(article Article) (uuid.UUID, error) {
 	query := `
 		INSERT INTO articles (id, name, description, slug, keywords,category_id,image_id, show_in_products)
 		    VALUES ($1, $2, $3, $4, $5, $6, $7, $8,$9)`
@@ -154,12 +154,12 @@ func (s *Service) CreateArticle(article Article) error {
 
 	err := validate.Struct(article)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	tx, err := s.db.Begin(context.Background())
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 	defer tx.Rollback(context.Background())
 
@@ -178,44 +178,15 @@ func (s *Service) CreateArticle(article Article) error {
 		article.ShowInProducts,
 	)
 	if err != nil {
-		return err
-	}
-
-	return tx.Commit(context.Background())
-}
-
-func (s *Service) EditArticle(id string, article Article) error {
-	query := "UPDATE articles SET name=$1,description=$2,image_id=$3,slug=$4,keywords=$5,category_id=$6,show_in_products=$7 WHERE id=$8;"
-	validate := utils.NewValidate()
-
-	err := validate.Struct(article)
-	if err != nil {
-		return err
-	}
-
-	tx, err := s.db.Begin(context.Background())
-	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
-	defer tx.Rollback(context.Background())
 
-	_, err = s.db.Exec(
-		context.Background(),
-		query,
-		article.Name,
-		article.Description,
-		article.ImageID,
-		article.Slug,
-		article.Keywords,
-		article.CategoryID,
-		article.ShowInProducts,
-		id,
-	)
+	err = tx.Commit(context.Background())
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return tx.Commit(context.Background())
+	return id, nil
 }
 
 func (s *Service) DeleteArticle(id string) error {
@@ -246,12 +217,8 @@ func (s *Service) ArticlesInCategory(category_id string) ([]Article, error) {
 		FROM
 		    articles a
 		    LEFT JOIN images i ON a.image_id = i.id
-		    LEFT JOIN categories c ON c.id = a.category_id
 		WHERE
-		    c.parent_id = $1
-		    OR c.id = $1;
-		
-		`
+		    ` + inCategoryTree("a.category_id", "$1")
 
 	rows, err := s.db.Query(context.Background(), query, category_id)
 	if err != nil {
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// auditSnapshotQueries return a resource as a single JSON document, including
+// child rows that are edited together with it. Secrets are left out.
+var auditSnapshotQueries = map[string]string{
+	"products": `
+		SELECT
+		    to_jsonb(t) - 'fts' - 'rank' || jsonb_build_object('imageIds', COALESCE((
+		            SELECT
+		                jsonb_agg(i.id ORDER BY i.id)
+		            FROM images i
+		            WHERE
+		                i.product_id = t.id), '[]'::jsonb), 'parameterValues', COALESCE((
+		            SELECT
+		                jsonb_object_agg(ppv.parameter_id, jsonb_build_object('textValue', ppv.text_value, 'boolValue', ppv.bool_value, 'selectableValue', ppv.selectable_value))
+		            FROM product_parameter_values ppv
+		            WHERE
+		                ppv.product_id = t.id), '{}'::jsonb), 'vehicleIds', COALESCE((
+		            SELECT
+		                jsonb_agg(pf.vehicle_id ORDER BY pf.vehicle_id)
+		            FROM product_fitments pf
+		            WHERE
+		                pf.product_id = t.id), '[]'::jsonb), 'variantAxes', COALESCE((
+		            SELECT
+		                jsonb_agg(pva.parameter_id ORDER BY pva.position)
+		            FROM product_variant_axes pva
+		            WHERE
+		                pva.product_id = t.id), '[]'::jsonb), 'relations', COALESCE((
+		            SELECT
+		                jsonb_object_agg(kinds.kind, kinds.related_ids)
+		            FROM (
+		                SELECT
+		                    pr.kind,
+		                    jsonb_agg(pr.related_id ORDER BY pr.position) AS related_ids
+		                FROM product_relations pr
+		                WHERE
+		                    pr.product_id = t.id
+		                GROUP BY
+		                    pr.kind) kinds), '{}'::jsonb), 'bundleItems', COALESCE((
+		            SELECT
+		                jsonb_agg(jsonb_build_object('productId', pbi.product_id, 'quantity', pbi.quantity) ORDER BY pbi.position)
+		            FROM product_bundle_items pbi
+		            WHERE
+		                pbi.bundle_id = t.id), '[]'::jsonb))
+		FROM
+		    products t
+		WHERE
+		    id = $1`,
+	"invoices": `
+		SELECT
+		    to_jsonb(t) || jsonb_build_object('items', COALESCE((
+		            SELECT
+		                jsonb_agg(to_jsonb(ii) ORDER BY ii.created_at, ii.id)
+		            FROM invoice_items ii
+		            WHERE
+		                ii.invoice_id = t.id), '[]'::jsonb))
+		FROM
+		    invoices t
+		WHERE
+		    id = $1`,
+	"users": `
+		SELECT
+		    to_jsonb(t) - 'password' - 'token' - 'verification_token'
+		FROM
+		    users t
+		WHERE
+		    id = $1`,
+	"product_variants": `
+		SELECT
+		    to_jsonb(t) || jsonb_build_object('imageIds', COALESCE((
+		            SELECT
+		                jsonb_agg(i.id ORDER BY i.id)
+		            FROM images i
+		            WHERE
+		                i.variant_id = t.id), '[]'::jsonb), 'values', COALESCE((
+		            SELECT
+		                jsonb_object_agg(pvv.parameter_id, pvv.value)
+		            FROM product_variant_values pvv
+		            WHERE
+		                pvv.variant_id = t.id), '{}'::jsonb))
+		FROM
+		    product_variants t
+		WHERE
+		    id = $1`,
+	"categories": `
+		SELECT
+		    to_jsonb(t) || jsonb_build_object('parameterOverrides', COALESCE((
+		            SELECT
+		                jsonb_object_agg(cpo.parameter_id, to_jsonb(cpo) - 'category_id' - 'parameter_id' - 'created_at' - 'updated_at')
+		            FROM category_parameter_overrides cpo
+		            WHERE
+		                cpo.category_id = t.id), '{}'::jsonb))
+		FROM
+		    categories t
+		WHERE
+		    id = $1`,
+	"entities":         `SELECT to_jsonb(t) FROM entities t WHERE id = $1`,
+	"brands":           `SELECT to_jsonb(t) FROM brands t WHERE id = $1`,
+	"images":           `SELECT to_jsonb(t) FROM images t WHERE id = $1`,
+	"parameter_groups": `SELECT to_jsonb(t) FROM parameter_groups t WHERE id = $1`,
+	"parameters":       `SELECT to_jsonb(t) FROM parameters t WHERE id = $1`,
+	"articles":         `SELECT to_jsonb(t) FROM articles t WHERE id = $1`,
+	"persons":          `SELECT to_jsonb(t) FROM persons t WHERE id = $1`,
+	"vehicles":         `SELECT to_jsonb(t) FROM vehicles t WHERE id = $1`,
+	"reviews":          `SELECT to_jsonb(t) FROM product_reviews t WHERE id = $1`,
+	"questions":        `SELECT to_jsonb(t) FROM product_questions t WHERE id = $1`,
+}
+
+// AuditSnapshot returns the current state of a resource, or nil when it does
+// not exist (before a create, after a delete).
+func (s *Service) AuditSnapshot(resourceType string, id string) (json.RawMessage, error) {
+	query, ok := auditSnapshotQueries[resourceType]
+	if !ok {
+		return nil, fmt.Errorf("no audit snapshot for %s", resourceType)
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	return auditSnapshot(context.Background(), s.db, query, parsedUUID)
+}
+
+// rowQuerier is a pool or a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// auditSnapshot runs an auditSnapshotQueries query with db, which can be a
+// transaction that is about to change the row.
+func auditSnapshot(ctx context.Context, db rowQuerier, query string, id any) (json.RawMessage, error) {
+	var snapshot json.RawMessage
+
+	err := db.QueryRow(ctx, query, id).Scan(&snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (s *Service) RecordAudit(log AuditLog) error {
+	diff, err := auditDiff(log.Before, log.After)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_logs (id, user_id, action, resource_type, resource_id, before, after, diff)
+		    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = s.db.Exec(
+		context.Background(),
+		query,
+		uuid.New(),
+		log.UserID,
+		log.Action,
+		log.ResourceType,
+		log.ResourceID,
+		nullJSON(log.Before),
+		nullJSON(log.After),
+		diff,
+	)
+
+	return err
+}
+
+func nullJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	return raw
+}
+
+// auditDiff lists the top-level fields that changed as
+// {"field": {"before": ..., "after": ...}}. Timestamps maintained by the
+// database are ignored.
+func auditDiff(before json.RawMessage, after json.RawMessage) (json.RawMessage, error) {
+	beforeFields := map[string]any{}
+	afterFields := map[string]any{}
+
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &beforeFields); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &afterFields); err != nil {
+			return nil, err
+		}
+	}
+
+	type change struct {
+		Before any `json:"before"`
+		After  any `json:"after"`
+	}
+
+	diff := map[string]change{}
+
+	for key, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[key], value) {
+			diff[key] = change{Before: beforeFields[key], After: value}
+		}
+	}
+
+	for key, value := range beforeFields {
+		if _, ok := afterFields[key]; !ok {
+			diff[key] = change{Before: value, After: nil}
+		}
+	}
+
+	delete(diff, "updated_at")
+	delete(diff, "created_at")
+
+	return json.Marshal(diff)
+}
+
+func (s *Service) ListAuditLogs(
+	userID string,
+	resourceType string,
+	resourceID string,
+	from string,
+	to string,
+	countInPage string,
+	offset string,
+	w http.ResponseWriter,
+) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if userID != "" {
+		addCondition("audit_logs.user_id = $%d", userID)
+	}
+
+	if resourceType != "" {
+		addCondition("audit_logs.resource_type = $%d", resourceType)
+	}
+
+	if resourceID != "" {
+		addCondition("audit_logs.resource_id = $%d", resourceID)
+	}
+
+	if from != "" {
+		addCondition("audit_logs.created_at >= $%d::timestamptz", from)
+	}
+
+	if to != "" {
+		addCondition("audit_logs.created_at <= $%d::timestamptz", to)
+	}
+
+	filterBy := ""
+	if len(conditions) > 0 {
+		filterBy = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	pagedBy := ""
+	offsetNum := 0
+
+	if countInPage != "" {
+		limit, err := strconv.Atoi(countInPage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if offset != "" {
+			offsetNum, err = strconv.Atoi(offset)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		pagedBy = fmt.Sprintf(`LIMIT %d OFFSET %d`, limit, offsetNum)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+		    audit_logs.id,
+		    audit_logs.user_id,
+		    users.email,
+		    audit_logs.action,
+		    audit_logs.resource_type,
+		    audit_logs.resource_id,
+		    audit_logs.before,
+		    audit_logs.after,
+		    audit_logs.diff,
+		    audit_logs.created_at
+		FROM
+		    audit_logs
+		    LEFT JOIN users ON users.id = audit_logs.user_id
+		%s
+		ORDER BY
+		    audit_logs.created_at DESC %s
+		`, filterBy, pagedBy)
+
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+	defer rows.Close()
+
+	var logs []AuditLog
+
+	for rows.Next() {
+		var log AuditLog
+		if err := rows.Scan(&log.ID, &log.UserID, &log.UserEmail, &log.Action, &log.ResourceType, &log.ResourceID, &log.Before, &log.After, &log.Diff, &log.CreatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		logs = append(logs, log)
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	row := s.db.QueryRow(
+		context.Background(),
+		"SELECT COUNT(*) FROM audit_logs "+filterBy,
+		args...,
+	)
+
+	var Count int32
+
+	err = row.Scan(&Count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	var logsWithTotalCount struct {
+		Rows       []AuditLog `json:"rows"`
+		TotalCount int32      `json:"totalCount"`
+	}
+
+	logsWithTotalCount.Rows = logs
+	logsWithTotalCount.TotalCount = Count
+
+	err = json.NewEncoder(w).Encode(logsWithTotalCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+}
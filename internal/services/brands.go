@@ -42,7 +42,9 @@ func (s *Service) ListBrandsWithSortFilterPagination(
 
 	var filterBy strings.Builder
 	if len(filters) > 0 {
-		filterBy.WriteString("WHERE ")
+		filterBy.WriteString("WHERE brands.deleted_at IS NULL AND ")
+	} else {
+		filterBy.WriteString("WHERE brands.deleted_at IS NULL ")
 	}
 
 	for index, filter := range filters {
@@ -167,7 +169,7 @@ func (s *Service) ListBrandsWithSortFilterPagination(
 }
 
 func (s *Service) ListBrands() ([]Brand, error) {
-	query := `SELECT * FROM brands`
+	query := `SELECT id, name, description, created_at FROM brands WHERE deleted_at IS NULL`
 
 	rows, err := s.db.Query(context.Background(), query)
 	if err != nil {
@@ -197,7 +199,7 @@ func (s *Service) GetBrand(id string) (Brand, error) {
 		return brand, err
 	}
 
-	query := "SELECT * FROM brands WHERE id=$1"
+	query := "SELECT id,name,description,created_at FROM brands WHERE id=$1 AND deleted_at IS NULL"
 	row := s.db.QueryRow(context.Background(), query, parsedUUID)
 
 	err = row.Scan(&brand.ID, &brand.Name, &brand.Description, &brand.CreatedAt)
@@ -208,49 +210,25 @@ func (s *Service) GetBrand(id string) (Brand, error) {
 	return brand, nil
 }
 
-func (s *Service) CreateBrand(brand Brand) error {
+func (s *Service) CreateBrand(brand Brand) (uuid.UUID, error) {
 	query := "INSERT INTO brands (id,name,description) VALUES ($1,$2,$3);"
 	validate := utils.NewValidate()
 
 	err := validate.Struct(brand)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	id := uuid.New()
 
 	_, err = s.db.Exec(context.Background(), query, id, brand.Name, brand.Description)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
-}
-
-func (s *Service) EditBrand(id string, brand Brand) error {
-	query := "UPDATE brands SET name=$1,description=$2 WHERE id=$3;"
-	validate := utils.NewValidate()
-
-	err := validate.Struct(brand)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.db.Exec(context.Background(), query, brand.Name, brand.Description, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return id, nil
 }
 
 func (s *Service) DeleteBrand(id string) error {
-	query := "DELETE FROM brands WHERE id=$1"
-
-	_, err := s.db.Exec(context.Background(), query, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.softDelete("brands", id)
 }
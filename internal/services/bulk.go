@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// bulkEditListLimit caps the changed products listed in a bulk edit
+// result; Affected counts all of them.
+const bulkEditListLimit = 100
+
+var (
+	ErrBulkEditNoTarget = errors.New("ids or filter are required")
+	ErrBulkEditInvalid  = errors.New("invalid bulk edit")
+)
+
+// bulkEditFields are the product columns the set_field operation may set.
+var bulkEditFields = []string{"price", "count", "position", "info", "description"}
+
+// numericPrice matches prices the price operations can compute with.
+const numericPrice = `products.price ~ '^[0-9]+(\.[0-9]+)?$'`
+
+// notSumBundle leaves out bundles priced by their items, whose price cannot
+// be written.
+const notSumBundle = `products.bundle_pricing IS DISTINCT FROM 'sum'`
+
+// bulkEditFilterColumns are the listing filters a bulk edit can target,
+// with the expression each compares. Unlike the listing, which pastes its
+// filters into SQL, a write takes names and operands only from these lists
+// and binds the conditions.
+var bulkEditFilterColumns = map[string]string{
+	"name":          "products.name",
+	"code":          "products.code",
+	"slug":          "products.slug",
+	"description":   "products.description",
+	"info":          "products.info",
+	"category_name": "categories.name",
+	"price":         "products.price::bigint",
+	"count":         "products.count::bigint",
+}
+
+// bulkEditNumericFilters compare numbers, so they take no text operands.
+var bulkEditNumericFilters = []string{"price", "count"}
+
+var bulkEditFilterOperands = map[string]string{
+	"=":           "=",
+	"!=":          "<>",
+	">":           ">",
+	">=":          ">=",
+	"<":           "<",
+	"<=":          "<=",
+	"contains":    "ILIKE",
+	"notcontains": "NOT ILIKE",
+}
+
+func bulkEditError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrBulkEditInvalid, fmt.Sprintf(format, args...))
+}
+
+// bulkEditSet returns the SET expression of an operation and a condition
+// leaving out products it would not change. $1 is the value.
+func bulkEditSet(edit BulkEdit) (string, string, any, error) {
+	switch edit.Operation {
+	case "set_field":
+		if !slices.Contains(bulkEditFields, edit.Field) {
+			return "", "", nil, bulkEditError("field must be one of %s", strings.Join(bulkEditFields, ", "))
+		}
+
+		value := edit.Value
+
+		if edit.Field == "price" || edit.Field == "count" {
+			value = normalizeNumber(value)
+			if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+				return "", "", nil, bulkEditError("%s must be a non-negative whole number", edit.Field)
+			}
+		}
+
+		unchanged := fmt.Sprintf("products.%s IS DISTINCT FROM $1", edit.Field)
+		if edit.Field == "price" {
+			unchanged += " AND " + notSumBundle
+		}
+
+		return fmt.Sprintf("%s = $1", edit.Field), unchanged, value, nil
+	case "increase_price", "decrease_price":
+		amount, err := strconv.ParseFloat(normalizeNumber(edit.Value), 64)
+		if err != nil || amount <= 0 {
+			return "", "", nil, bulkEditError("value must be a positive number")
+		}
+
+		sign := "+"
+		if edit.Operation == "decrease_price" {
+			sign = "-"
+		}
+
+		newPrice := fmt.Sprintf("products.price::numeric %s $1", sign)
+		if edit.Percent {
+			newPrice = fmt.Sprintf("products.price::numeric * (100 %s $1) / 100", sign)
+		}
+
+		// Prices stay whole numbers and never drop below zero.
+		return fmt.Sprintf("price = GREATEST(round(%s), 0)::bigint::text", newPrice), numericPrice + " AND " + notSumBundle, amount, nil
+	case "add_keyword":
+		keyword := strings.TrimSpace(edit.Value)
+		if keyword == "" {
+			return "", "", nil, bulkEditError("value must be a keyword")
+		}
+
+		return "keywords = array_append(COALESCE(products.keywords, ARRAY[]::varchar[]), $1)",
+			"NOT $1 = ANY (COALESCE(products.keywords, ARRAY[]::varchar[]))", keyword, nil
+	case "remove_keyword":
+		keyword := strings.TrimSpace(edit.Value)
+
+		return "keywords = array_remove(products.keywords, $1)", "$1 = ANY (products.keywords)", keyword, nil
+	case "set_show":
+		show, err := parseImportBool(edit.Value)
+		if err != nil {
+			return "", "", nil, bulkEditError("value must be true or false")
+		}
+
+		return "show = $1", "products.show IS DISTINCT FROM $1", show, nil
+	case "move_category":
+		categoryID, err := uuid.Parse(edit.Value)
+		if err != nil {
+			return "", "", nil, bulkEditError("value must be a category id")
+		}
+
+		return "category_id = $1", "products.category_id IS DISTINCT FROM $1", categoryID, nil
+	default:
+		return "", "", nil, bulkEditError("unknown operation %q", edit.Operation)
+	}
+}
+
+// bulkEditFilters returns the conditions of the filters of edit, joined by
+// AND, with their values appended to args.
+func bulkEditFilters(edit BulkEdit, args []any) (string, []any, error) {
+	conditions := make([]string, 0, len(edit.Filters))
+
+	for index, filter := range edit.Filters {
+		condition := edit.FilterConditions[index]
+
+		if filter == "vehicle" {
+			vehicleID, err := uuid.Parse(condition)
+			if err != nil {
+				return "", nil, bulkEditError("the vehicle filter needs a vehicle id")
+			}
+
+			args = append(args, vehicleID)
+			conditions = append(conditions, fitsVehicle("products", fmt.Sprintf("$%d", len(args))))
+
+			continue
+		}
+
+		column, ok := bulkEditFilterColumns[filter]
+		if !ok {
+			return "", nil, bulkEditError("unknown filter %q", filter)
+		}
+
+		operand, ok := bulkEditFilterOperands[edit.FilterOperands[index]]
+		if !ok {
+			return "", nil, bulkEditError("unknown filter operand %q", edit.FilterOperands[index])
+		}
+
+		switch {
+		case slices.Contains(bulkEditNumericFilters, filter):
+			if strings.HasSuffix(operand, "LIKE") {
+				return "", nil, bulkEditError("%s filters take comparisons", filter)
+			}
+
+			number, err := strconv.ParseInt(normalizeNumber(condition), 10, 64)
+			if err != nil {
+				return "", nil, bulkEditError("%s filters take whole numbers", filter)
+			}
+
+			args = append(args, number)
+		case strings.HasSuffix(operand, "LIKE"):
+			args = append(args, "%"+condition+"%")
+		default:
+			args = append(args, condition)
+		}
+
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", column, operand, len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// BulkEditProducts applies edit to every product it targets in one
+// transaction. A preview reports the same result and rolls back.
+func (s *Service) BulkEditProducts(edit BulkEdit) (BulkEditResult, error) {
+	result := BulkEditResult{
+		Preview:  edit.Preview,
+		Products: []Product{},
+	}
+
+	if len(edit.IDs) == 0 && len(edit.Filters) == 0 {
+		return result, ErrBulkEditNoTarget
+	}
+
+	if len(edit.FilterOperands) != len(edit.Filters) || len(edit.FilterConditions) != len(edit.Filters) {
+		return result, bulkEditError("each filter needs an operand and a condition")
+	}
+
+	set, unchanged, value, err := bulkEditSet(edit)
+	if err != nil {
+		return result, err
+	}
+
+	filterBy := "WHERE products.deleted_at IS NULL"
+
+	filters, args, err := bulkEditFilters(edit, []any{value})
+	if err != nil {
+		return result, err
+	}
+
+	if filters != "" {
+		filterBy += " AND " + filters
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = setPriceSource(ctx, tx, "bulk")
+	if err != nil {
+		return result, err
+	}
+
+	if edit.Operation == "move_category" {
+		var exists bool
+
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)", value).Scan(&exists)
+		if err != nil {
+			return result, err
+		}
+
+		if !exists {
+			return result, bulkEditError("category not found")
+		}
+	}
+
+	if len(edit.IDs) > 0 {
+		args = append(args, edit.IDs)
+		filterBy += fmt.Sprintf(" AND products.id = ANY ($%d)", len(args))
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE
+		    products
+		SET
+		    %s,
+		    updated_at = now()
+		WHERE
+		    products.id IN (
+		        SELECT
+		            products.id
+		        FROM
+		            products
+		            LEFT JOIN brands ON products.brand_id = brands.id
+		            LEFT JOIN categories ON products.category_id = categories.id
+		        %s)
+		    AND %s
+		RETURNING
+		    products.id,
+		    products.name,
+		    products.price,
+		    products.count,
+		    products.show,
+		    products.category_id,
+		    products.keywords`, set, filterBy, unchanged)
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product Product
+		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Count, &product.Show, &product.CategoryID, &product.Keywords); err != nil {
+			return result, err
+		}
+
+		result.Affected++
+		result.Audit = append(result.Audit, AuditLog{Action: "bulk_update", ResourceType: "products", ResourceID: product.ID})
+
+		if len(result.Products) < bulkEditListLimit {
+			result.Products = append(result.Products, product)
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	if edit.Preview {
+		return result, nil
+	}
+
+	// Until the commit, other connections still see the products as they
+	// were, and the locks of the update keep them that way.
+	for index, entry := range result.Audit {
+		result.Audit[index].Before, err = auditSnapshot(ctx, s.db, auditSnapshotQueries["products"], entry.ResourceID)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, tx.Commit(ctx)
+}
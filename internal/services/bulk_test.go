@@ -0,0 +1,37 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBulkEditFiltersBindConditions(t *testing.T) {
+	edit := BulkEdit{
+		Filters:          []string{"name", "price"},
+		FilterOperands:   []string{"contains", ">="},
+		FilterConditions: []string{"x' OR '1'='1", "۱۰۰"},
+	}
+
+	filters, args, err := bulkEditFilters(edit, []any{"value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filters != "products.name ILIKE $2 AND products.price::bigint >= $3" {
+		t.Fatalf("got filters %q", filters)
+	}
+
+	if len(args) != 3 || args[1] != "%x' OR '1'='1%" || args[2] != int64(100) {
+		t.Fatalf("got args %#v", args)
+	}
+
+	for _, edit := range []BulkEdit{
+		{Filters: []string{"id = id OR TRUE --"}, FilterOperands: []string{"="}, FilterConditions: []string{""}},
+		{Filters: []string{"name"}, FilterOperands: []string{"= '' OR TRUE --"}, FilterConditions: []string{""}},
+		{Filters: []string{"count"}, FilterOperands: []string{"contains"}, FilterConditions: []string{"1"}},
+	} {
+		if _, _, err := bulkEditFilters(edit, nil); err == nil || !strings.Contains(err.Error(), "invalid bulk edit") {
+			t.Fatalf("filter %q %q was accepted: %v", edit.Filters[0], edit.FilterOperands[0], err)
+		}
+	}
+}
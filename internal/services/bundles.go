@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// bundlePricings are how the price of a bundle is set. The database derives
+// the price of a 'sum' bundle and rejects writing any other.
+var bundlePricings = []string{"fixed", "sum"}
+
+// GetBundle returns what a product is a bundle of and how many of it the
+// stock of its items makes. Trashed items are left out, and while one is in
+// the trash no bundle can be made.
+func (s *Service) GetBundle(productID string) (Bundle, error) {
+	bundle := Bundle{Items: []BundleItem{}}
+
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return bundle, err
+	}
+
+	ctx := context.Background()
+
+	err = s.db.QueryRow(ctx, `
+		SELECT
+		    COALESCE(bundle_pricing, ''),
+		    bundle_discount::text
+		FROM
+		    products
+		WHERE
+		    id = $1
+		    AND deleted_at IS NULL`, parsedUUID).Scan(&bundle.Pricing, &bundle.Discount)
+	if err != nil {
+		return bundle, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    p.id,
+		    p.name,
+		    p.price,
+		    p.count,
+		    bi.quantity,
+		    p.deleted_at IS NOT NULL
+		FROM
+		    product_bundle_items bi
+		    JOIN products p ON bi.product_id = p.id
+		WHERE
+		    bi.bundle_id = $1
+		ORDER BY
+		    bi.position`, parsedUUID)
+	if err != nil {
+		return bundle, err
+	}
+	defer rows.Close()
+
+	var (
+		available = pgtype.Int8{Valid: true}
+		trashed   bool
+	)
+
+	for rows.Next() {
+		var (
+			item        BundleItem
+			itemTrashed bool
+		)
+		if err := rows.Scan(&item.ProductID, &item.Name, &item.Price, &item.Count, &item.Quantity, &itemTrashed); err != nil {
+			return bundle, err
+		}
+
+		if itemTrashed {
+			trashed = true
+
+			continue
+		}
+
+		count, err := strconv.ParseInt(strings.TrimSpace(item.Count.String), 10, 64)
+		if err != nil {
+			available.Valid = false
+		} else if made := max(count, 0) / int64(item.Quantity); len(bundle.Items) == 0 || made < available.Int64 {
+			available.Int64 = made
+		}
+
+		bundle.Items = append(bundle.Items, item)
+	}
+
+	switch {
+	case trashed:
+		bundle.Available = pgtype.Int8{Valid: true}
+	case len(bundle.Items) > 0:
+		bundle.Available = available
+	}
+
+	return bundle, rows.Err()
+}
+
+// SetBundle makes a product a bundle of the items of bundle, in this order,
+// priced by bundle.Pricing; without a pricing and items it stops being one.
+// Items cannot be bundles themselves, nor can a bundle be an item.
+func (s *Service) SetBundle(productID string, bundle Bundle) error {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return err
+	}
+
+	var pricing pgtype.Text
+
+	switch {
+	case bundle.Pricing == "" && len(bundle.Items) == 0:
+	case slices.Contains(bundlePricings, bundle.Pricing):
+		pricing = pgtype.Text{String: bundle.Pricing, Valid: true}
+	default:
+		return fmt.Errorf("%w: pricing must be one of %s", ErrInvalidBundle, strings.Join(bundlePricings, ", "))
+	}
+
+	if !bundle.Discount.Valid || bundle.Discount.String == "" {
+		bundle.Discount = pgtype.Text{String: "0", Valid: true}
+	}
+
+	var (
+		ids        = make([]pgtype.UUID, 0, len(bundle.Items))
+		quantities = make([]int32, 0, len(bundle.Items))
+	)
+
+	for _, item := range bundle.Items {
+		if item.Quantity < 1 {
+			return fmt.Errorf("%w: quantity must be at least 1", ErrInvalidBundle)
+		}
+
+		for _, id := range ids {
+			if id == item.ProductID {
+				return fmt.Errorf("%w: a product is given twice", ErrInvalidBundle)
+			}
+		}
+
+		ids = append(ids, item.ProductID)
+		quantities = append(quantities, item.Quantity)
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int
+
+	err = tx.QueryRow(ctx, "SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", parsedUUID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		var isItem bool
+
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM product_bundle_items WHERE product_id = $1)", parsedUUID).Scan(&isItem)
+		if err != nil {
+			return err
+		}
+
+		if isItem {
+			return fmt.Errorf("%w: the product is an item of another bundle", ErrInvalidBundle)
+		}
+	}
+
+	var found int
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    count(*)
+		FROM
+		    products
+		WHERE
+		    id = ANY ($2)
+		    AND id <> $1
+		    AND bundle_pricing IS NULL
+		    AND deleted_at IS NULL`, parsedUUID, ids).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if found != len(ids) {
+		return fmt.Errorf("%w: items must be other products that exist and are not bundles", ErrInvalidBundle)
+	}
+
+	err = setPriceSource(ctx, tx, "bundle")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE
+		    products
+		SET
+		    bundle_pricing = $2,
+		    bundle_discount = $3::numeric,
+		    updated_at = now()
+		WHERE
+		    id = $1`, parsedUUID, pricing, bundle.Discount)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBundle, err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM product_bundle_items WHERE bundle_id = $1", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_bundle_items (bundle_id, product_id, quantity, position)
+		SELECT
+		    $1,
+		    items.product_id,
+		    items.quantity,
+		    items.ordinality - 1
+		FROM
+		    unnest($2::uuid[], $3::int[])
+		    WITH ORDINALITY AS items (product_id, quantity, ordinality)`, parsedUUID, ids, quantities)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "SELECT refresh_bundle_price($1)", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ListStockMovements returns what bundles on invoices moved in or out of the
+// stock of a product, latest first. Invoicing a product on its own leaves
+// its count to be kept by hand.
+func (s *Service) ListStockMovements(productID string) ([]StockMovement, error) {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+
+	err = s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)", parsedUUID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    sm.id,
+		    sm.product_id,
+		    sm.invoice_item_id,
+		    ii.invoice_id,
+		    inv.type::text,
+		    sm.quantity,
+		    sm.created_at
+		FROM
+		    stock_movements sm
+		    JOIN invoice_items ii ON sm.invoice_item_id = ii.id
+		    JOIN invoices inv ON ii.invoice_id = inv.id
+		WHERE
+		    sm.product_id = $1
+		ORDER BY
+		    sm.created_at DESC`, parsedUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []StockMovement{}
+
+	for rows.Next() {
+		var movement StockMovement
+		if err := rows.Scan(
+			&movement.ID,
+			&movement.ProductID,
+			&movement.InvoiceItemID,
+			&movement.InvoiceID,
+			&movement.InvoiceType,
+			&movement.Quantity,
+			&movement.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		movements = append(movements, movement)
+	}
+
+	return movements, rows.Err()
+}
+
+// storeBundleItems returns what the bundle with productID is made of. Items
+// hidden from the storefront are still part of it, so only trashed ones are
+// left out.
+func (s *Service) storeBundleItems(ctx context.Context, productID pgtype.UUID) ([]PublicBundleItem, error) {
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT"+storeProductColumns+`,
+		    bi.quantity,
+		    CASE WHEN p.count ~ '^[0-9]+$' THEN
+		        p.count::numeric >= bi.quantity
+		    ELSE
+		        FALSE
+		    END`+storeProductFrom+`
+		    JOIN product_bundle_items bi ON bi.product_id = p.id
+		WHERE
+		    bi.bundle_id = $1
+		    AND p.deleted_at IS NULL
+		ORDER BY
+		    bi.position`,
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []PublicBundleItem
+
+	for rows.Next() {
+		var item PublicBundleItem
+		if err := scanPublicProduct(rows, &item.Product, &item.Quantity, &item.Available); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -3,12 +3,15 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
 )
@@ -31,9 +34,10 @@ func (s *Service) ListParentCategories() ([]Category, error) {
 		FROM
 		    categories p
 		    LEFT JOIN categories c ON c.parent_id = p.id
+		        AND c.deleted_at IS NULL
 		    LEFT JOIN images i ON p.image_id = i.id
 		WHERE
-		    p.parent_id IS NULL AND p.show IS TRUE
+		    p.parent_id IS NULL AND p.show IS TRUE AND p.deleted_at IS NULL
 		GROUP BY
 		    p.id,
 		    p.name,
@@ -106,6 +110,8 @@ func (s *Service) ListCategories() ([]Category, error) {
 		    categories AS c
 		    LEFT JOIN categories p ON c.parent_id = p.id
 		    LEFT JOIN images i ON c.image_id = i.id
+		WHERE
+		    c.deleted_at IS NULL
 		GROUP BY
 		    c.id,
 		    i.image_url,
@@ -163,7 +169,9 @@ func (s *Service) ListCategoriesWithSortFilterPagination(
 
 	var filterBy strings.Builder
 	if len(filters) > 0 {
-		filterBy.WriteString("WHERE ")
+		filterBy.WriteString("WHERE categories.deleted_at IS NULL AND ")
+	} else {
+		filterBy.WriteString("WHERE categories.deleted_at IS NULL ")
 	}
 	// create map for filters
 	// filterMap := make(map[string]string)
@@ -331,7 +339,7 @@ func (s *Service) GetCategory(id string) (Category, error) {
 FROM categories AS c
 LEFT JOIN categories p ON c.parent_id = p.id
 LEFT JOIN images i ON c.image_id = i.id
-WHERE c.id = $1;
+WHERE c.id = $1 AND c.deleted_at IS NULL;
 `
 	row := s.db.QueryRow(context.Background(), query, parsedUUID)
 
@@ -353,7 +361,9 @@ WHERE c.id = $1;
 		return category, err
 	}
 
-	return category, nil
+	category.Breadcrumbs, err = s.breadcrumbs(context.Background(), category.ID)
+
+	return category, err
 }
 
 func (s *Service) GetCategoryBySlug(slug string) (Category, error) {
@@ -374,7 +384,8 @@ func (s *Service) GetCategoryBySlug(slug string) (Category, error) {
 		FROM
 		    categories
 		WHERE
-		    slug = $1`
+		    slug = $1
+		    AND deleted_at IS NULL`
 	row := s.db.QueryRow(context.Background(), query, slug)
 
 	err := row.Scan(
@@ -396,13 +407,13 @@ func (s *Service) GetCategoryBySlug(slug string) (Category, error) {
 	return category, nil
 }
 
-func (s *Service) CreateCategory(category Category) error {
+func (s *Service) CreateCategory(category Category) (uuid.UUID, error) {
 	query := "INSERT INTO categories (id,name,parent_id,description,priority,image_id,slug,show) VALUES ($1,$2,$3,$4,$5,$6,$7,$8);"
 	validate := utils.NewValidate()
 
 	err := validate.Struct(category)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	id := uuid.New()
@@ -420,47 +431,312 @@ func (s *Service) CreateCategory(category Category) error {
 		category.Show,
 	)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
+	return id, nil
 }
 
-func (s *Service) EditCategory(id string, category Category) error {
-	query := "UPDATE categories SET name=$1,description=$2,parent_id=$3,image_id=$4,priority=$5,slug=$6,show=$7 WHERE id=$8;"
-	validate := utils.NewValidate()
+func (s *Service) DeleteCategory(id string) error {
+	return s.softDelete("categories", id)
+}
 
-	err := validate.Struct(category)
+var (
+	ErrCategoryCycle = errors.New("a category cannot be moved under itself")
+	ErrInvalidParent = errors.New("parent category not found")
+)
+
+// inCategoryTree matches when column is the category in param or one below
+// it at any depth.
+func inCategoryTree(column string, param string) string {
+	return fmt.Sprintf("%s IN (SELECT id FROM category_descendants (%s))", column, param)
+}
+
+// breadcrumbs returns the categories from the top level down to categoryID,
+// or none without one.
+func (s *Service) breadcrumbs(ctx context.Context, categoryID pgtype.UUID) ([]Breadcrumb, error) {
+	if !categoryID.Valid {
+		return nil, nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    c.id,
+		    c.name,
+		    c.slug
+		FROM
+		    category_ancestors ($1) a
+		    JOIN categories c ON c.id = a.id
+		ORDER BY
+		    a.depth DESC`, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var breadcrumbs []Breadcrumb
+
+	for rows.Next() {
+		var breadcrumb Breadcrumb
+		if err := rows.Scan(&breadcrumb.ID, &breadcrumb.Name, &breadcrumb.Slug); err != nil {
+			return nil, err
+		}
+
+		breadcrumbs = append(breadcrumbs, breadcrumb)
+	}
+
+	return breadcrumbs, rows.Err()
+}
+
+// buildCategoryTree nests nodes under their parents, keeping their order.
+// Nodes whose parent is not among them are the roots.
+func buildCategoryTree(nodes []CategoryNode) []CategoryNode {
+	present := map[pgtype.UUID]bool{}
+	children := map[pgtype.UUID][]CategoryNode{}
+
+	for _, node := range nodes {
+		present[node.ID] = true
+	}
+
+	var roots []CategoryNode
+
+	for _, node := range nodes {
+		if node.ParentID.Valid && present[node.ParentID] {
+			children[node.ParentID] = append(children[node.ParentID], node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	var attach func(nodes []CategoryNode) []CategoryNode
+
+	attach = func(nodes []CategoryNode) []CategoryNode {
+		for index := range nodes {
+			nodes[index].Children = attach(children[nodes[index].ID])
+		}
+
+		return nodes
+	}
+
+	return attach(roots)
+}
+
+func (s *Service) categoryNodes(ctx context.Context, query string, args ...any) ([]CategoryNode, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := []CategoryNode{}
+
+	for rows.Next() {
+		var node CategoryNode
+		if err := rows.Scan(&node.ID, &node.Name, &node.Slug, &node.ParentID, &node.Priority, &node.Show, &node.Depth); err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, rows.Err()
+}
+
+// CategoryTree returns every category out of the trash nested under its
+// parent, siblings by priority.
+func (s *Service) CategoryTree() ([]CategoryNode, error) {
+	nodes, err := s.categoryNodes(context.Background(), `
+		SELECT
+		    c.id,
+		    c.name,
+		    c.slug,
+		    c.parent_id,
+		    c.priority,
+		    COALESCE(c.show, FALSE),
+		    0
+		FROM
+		    categories c
+		WHERE
+		    c.deleted_at IS NULL
+		ORDER BY
+		    c.priority,
+		    c.name`)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := buildCategoryTree(nodes)
+	if tree == nil {
+		tree = []CategoryNode{}
+	}
+
+	return tree, nil
+}
+
+// CategoryDescendants returns the categories below the category with id at
+// any depth, each followed by those below it.
+func (s *Service) CategoryDescendants(id string) ([]CategoryNode, error) {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := s.categoryNodes(context.Background(), `
+		SELECT
+		    c.id,
+		    c.name,
+		    c.slug,
+		    c.parent_id,
+		    c.priority,
+		    COALESCE(c.show, FALSE),
+		    d.depth
+		FROM
+		    category_descendants ($1) d
+		    JOIN categories c ON c.id = d.id
+		WHERE
+		    c.deleted_at IS NULL
+		ORDER BY
+		    c.priority,
+		    c.name`, parsedUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := buildCategoryTree(nodes)
+
+	if len(tree) == 0 || tree[0].ID.Bytes != parsedUUID {
+		return nil, pgx.ErrNoRows
+	}
+
+	descendants := []CategoryNode{}
+
+	var flatten func(nodes []CategoryNode)
+
+	flatten = func(nodes []CategoryNode) {
+		for _, node := range nodes {
+			below := node.Children
+			node.Children = nil
+			descendants = append(descendants, node)
+			flatten(below)
+		}
+	}
+
+	flatten(tree[0].Children)
+
+	return descendants, nil
+}
+
+// CategoryAncestors returns the categories above the category with id, from
+// the top level down.
+func (s *Service) CategoryAncestors(id string) ([]Breadcrumb, error) {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	breadcrumbs, err := s.breadcrumbs(context.Background(), pgtype.UUID{Bytes: parsedUUID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(breadcrumbs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return breadcrumbs[:len(breadcrumbs)-1], nil
+}
+
+// MoveCategory moves the category with id, and the categories below it,
+// as move says. Siblings from the new priority on make room for it.
+func (s *Service) MoveCategory(id string, move CategoryMove) error {
+	parsedUUID, err := uuid.Parse(id)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.Exec(
-		context.Background(),
-		query,
-		category.Name,
-		category.Description,
-		category.ParentID,
-		category.ImageID,
-		category.Priority,
-		category.Slug,
-		category.Show,
-		id,
-	)
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	return nil
-}
+	var locked int
 
-func (s *Service) DeleteCategory(id string) error {
-	query := "DELETE FROM categories WHERE id=$1"
+	err = tx.QueryRow(ctx, "SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", parsedUUID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	if move.ParentID.Valid {
+		var cycle bool
+
+		err = tx.QueryRow(ctx, `
+			SELECT
+			    EXISTS (
+			        SELECT
+			            1
+			        FROM
+			            category_ancestors ($2) a
+			        WHERE
+			            a.id = $1)
+			FROM
+			    categories
+			WHERE
+			    id = $2
+			    AND deleted_at IS NULL`, parsedUUID, move.ParentID).Scan(&cycle)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidParent
+		}
 
-	_, err := s.db.Exec(context.Background(), query, id)
+		if err != nil {
+			return err
+		}
+
+		if cycle {
+			return ErrCategoryCycle
+		}
+	}
+
+	if move.Priority.Valid {
+		_, err = tx.Exec(ctx, `
+			UPDATE
+			    categories
+			SET
+			    priority = priority + 1
+			WHERE
+			    parent_id IS NOT DISTINCT FROM $2
+			    AND priority >= $3
+			    AND id <> $1`, parsedUUID, move.ParentID, move.Priority)
+	} else {
+		err = tx.QueryRow(ctx, `
+			SELECT
+			    COALESCE(max(priority) + 1, 0)
+			FROM
+			    categories
+			WHERE
+			    parent_id IS NOT DISTINCT FROM $2
+			    AND id <> $1
+			    AND deleted_at IS NULL`, parsedUUID, move.ParentID).Scan(&move.Priority)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE
+		    categories
+		SET
+		    parent_id = $2,
+		    priority = $3,
+		    updated_at = now()
+		WHERE
+		    id = $1`, parsedUUID, move.ParentID, move.Priority)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit(ctx)
 }
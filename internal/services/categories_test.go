@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestBuildCategoryTree(t *testing.T) {
+	id := func(b byte) pgtype.UUID { return pgtype.UUID{Bytes: [16]byte{b}, Valid: true} }
+
+	tree := buildCategoryTree([]CategoryNode{
+		{ID: id(1)},
+		{ID: id(2), ParentID: id(1)},
+		{ID: id(3), ParentID: id(2)},
+		{ID: id(4), ParentID: id(1)},
+		// The parent of 5 is in the trash.
+		{ID: id(5), ParentID: id(9)},
+	})
+
+	if len(tree) != 2 || tree[0].ID != id(1) || tree[1].ID != id(5) {
+		t.Fatalf("got roots %+v", tree)
+	}
+
+	children := tree[0].Children
+	if len(children) != 2 || children[0].ID != id(2) || children[1].ID != id(4) {
+		t.Fatalf("got children %+v", children)
+	}
+
+	if len(children[0].Children) != 1 || children[0].Children[0].ID != id(3) {
+		t.Fatalf("got grandchildren %+v", children[0].Children)
+	}
+}
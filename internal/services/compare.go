@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalidComparison = errors.New("invalid comparison")
+	ErrUnrelatedProducts = errors.New("only products of the same category can be compared")
+)
+
+// maxCompareProducts is how many products can be compared at once.
+const maxCompareProducts = 4
+
+// CompareProducts lines up the specs of the visible products with ids, in
+// this order, by the parameter groups their categories have and inherit.
+// The products must share their top level category.
+func (s *Service) CompareProducts(ids []string) (Comparison, error) {
+	comparison := Comparison{
+		Products: []PublicProduct{},
+		Groups:   []ComparisonGroup{},
+	}
+
+	if len(ids) < 2 || len(ids) > maxCompareProducts {
+		return comparison, fmt.Errorf("%w: compare 2 to %d products", ErrInvalidComparison, maxCompareProducts)
+	}
+
+	productIDs := make([]uuid.UUID, 0, len(ids))
+
+	for _, id := range ids {
+		parsedUUID, err := uuid.Parse(id)
+		if err != nil {
+			return comparison, fmt.Errorf("%w: %s", ErrInvalidComparison, err)
+		}
+
+		for _, seen := range productIDs {
+			if seen == parsedUUID {
+				return comparison, fmt.Errorf("%w: %s is given twice", ErrInvalidComparison, id)
+			}
+		}
+
+		productIDs = append(productIDs, parsedUUID)
+	}
+
+	ctx := context.Background()
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT"+storeProductColumns+", (SELECT a.id FROM category_ancestors (c.id) a ORDER BY a.depth DESC LIMIT 1)"+storeProductFrom+
+			" WHERE p.id = ANY ($1) AND "+visibleProduct+" ORDER BY array_position($1, p.id)",
+		productIDs,
+	)
+	if err != nil {
+		return comparison, err
+	}
+
+	var root pgtype.UUID
+
+	for rows.Next() {
+		var (
+			product PublicProduct
+			rootID  pgtype.UUID
+		)
+
+		if err := scanPublicProduct(rows, &product, &rootID); err != nil {
+			rows.Close()
+
+			return comparison, err
+		}
+
+		if !rootID.Valid || (len(comparison.Products) > 0 && rootID != root) {
+			rows.Close()
+
+			return comparison, ErrUnrelatedProducts
+		}
+
+		root = rootID
+		comparison.Products = append(comparison.Products, product)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return comparison, err
+	}
+
+	if len(comparison.Products) != len(productIDs) {
+		return comparison, fmt.Errorf("%w: product not found", ErrInvalidComparison)
+	}
+
+	rows, err = s.db.Query(ctx, `
+		SELECT
+		    COALESCE(cp.parameter_group, ''),
+		    cp.id,
+		    cp.name,
+		    ppv.product_id,
+		    COALESCE(ppv.selectable_value, ppv.text_value, ppv.bool_value::text)
+		FROM
+		    products p
+		    CROSS JOIN LATERAL category_parameters (p.category_id) cp
+		    JOIN product_parameter_values ppv ON ppv.parameter_id = cp.id
+		        AND ppv.product_id = p.id
+		WHERE
+		    p.id = ANY ($1)
+		    AND NOT cp.hidden
+		    AND COALESCE(ppv.selectable_value, ppv.text_value, ppv.bool_value::text) <> ''
+		ORDER BY
+		    cp.priority::int,
+		    cp.name`, productIDs)
+	if err != nil {
+		return comparison, err
+	}
+	defer rows.Close()
+
+	// Groups come in the order of their first parameter.
+	groupIndex := map[string]int{}
+	rowIndex := map[pgtype.UUID][2]int{}
+
+	for rows.Next() {
+		var (
+			groupName   string
+			parameterID pgtype.UUID
+			name        string
+			productID   pgtype.UUID
+			value       pgtype.Text
+		)
+
+		if err := rows.Scan(&groupName, &parameterID, &name, &productID, &value); err != nil {
+			return comparison, err
+		}
+
+		at, ok := rowIndex[parameterID]
+		if !ok {
+			group, ok := groupIndex[groupName]
+			if !ok {
+				group = len(comparison.Groups)
+				groupIndex[groupName] = group
+				comparison.Groups = append(comparison.Groups, ComparisonGroup{Name: groupName})
+			}
+
+			at = [2]int{group, len(comparison.Groups[group].Rows)}
+			rowIndex[parameterID] = at
+			comparison.Groups[group].Rows = append(comparison.Groups[group].Rows, ComparisonRow{
+				ParameterID: parameterID,
+				Name:        name,
+				Values:      make([]pgtype.Text, len(comparison.Products)),
+			})
+		}
+
+		for index, product := range comparison.Products {
+			if product.ID == productID {
+				comparison.Groups[at[0]].Rows[at[1]].Values[index] = value
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return comparison, err
+	}
+
+	for _, group := range comparison.Groups {
+		for index, row := range group.Rows {
+			for _, value := range row.Values[1:] {
+				if value != row.Values[0] {
+					group.Rows[index].Differs = true
+
+					break
+				}
+			}
+		}
+	}
+
+	return comparison, nil
+}
@@ -2,12 +2,27 @@ package services
 
 import (
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
 )
 
 type Service struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	sms     utils.SMSSender
+	mailer  utils.Mailer
+	storage utils.Storage
 }
 
 func New(db *pgxpool.Pool) Service {
-	return Service{db: db}
+	return Service{
+		db:      db,
+		sms:     utils.NewSMSSender(),
+		mailer:  utils.NewMailer(),
+		storage: utils.NewStorage(),
+	}
+}
+
+// Storage is where uploaded files are kept.
+func (s *Service) Storage() utils.Storage {
+	return s.storage
 }
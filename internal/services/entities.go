@@ -27,9 +27,11 @@ func (s *Service) ListParentEntities() ([]Entity, error) {
 		FROM
 		    entities p
 		    LEFT JOIN entities c ON c.parent_id = p.id
+		        AND c.deleted_at IS NULL
 		    LEFT JOIN images i ON p.image_id = i.id
 		WHERE
 		    p.parent_id IS NULL
+		    AND p.deleted_at IS NULL
 		GROUP BY
 		    p.id,
 		    p.name,
@@ -83,6 +85,8 @@ func (s *Service) ListEntities() ([]Entity, error) {
 		    entities AS c
 		    LEFT JOIN entities p ON c.parent_id = p.id
 		    LEFT JOIN images i ON c.image_id = i.id
+		WHERE
+		    c.deleted_at IS NULL
 		GROUP BY
 		    c.id,
 		    i.image_url,
@@ -136,7 +140,8 @@ func (s *Service) GetEntity(id string) (Entity, error) {
 		FROM
 		    entities
 		WHERE
-		    id = $1`
+		    id = $1
+		    AND deleted_at IS NULL`
 	row := s.db.QueryRow(context.Background(), query, parsedUUID)
 
 	err = row.Scan(
@@ -178,7 +183,8 @@ func (s *Service) GetEntityBySlug(slug string) (Entity, error) {
 		FROM
 		    entities
 		WHERE
-		    entity_slug = $1`
+		    entity_slug = $1
+		    AND deleted_at IS NULL`
 	row := s.db.QueryRow(context.Background(), query, slug)
 
 	err := row.Scan(
@@ -201,7 +207,7 @@ func (s *Service) GetEntityBySlug(slug string) (Entity, error) {
 	return Entity, nil
 }
 
-func (s *Service) CreateEntity(Entity Entity) error {
+func (s *Service) CreateEntity(Entity Entity) (uuid.UUID, error) {
 	query := `
 		INSERT INTO entities (id, name, parent_id, description, priority, image_id, entity_slug, show, keywords)
 		    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
@@ -209,7 +215,7 @@ func (s *Service) CreateEntity(Entity Entity) error {
 
 	err := validate.Struct(Entity)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	id := uuid.New()
@@ -228,61 +234,12 @@ func (s *Service) CreateEntity(Entity Entity) error {
 		Entity.Keywords,
 	)
 	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (s *Service) EditEntity(id string, Entity Entity) error {
-	query := `
-		UPDATE
-		    entities
-		SET
-		    name = $1,
-		    parent_id = $2,
-		    image_id = $3,
-		    priority = $4,
-		    entity_slug = $5,
-		    show = $6,
-		    keywords = $7
-		WHERE
-		    id = $8`
-	validate := utils.NewValidate()
-
-	err := validate.Struct(Entity)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.db.Exec(
-		context.Background(),
-		query,
-		Entity.Name,
-		Entity.ParentID,
-		Entity.ImageID,
-		Entity.Priority,
-		Entity.EntitySlug,
-		Entity.Show,
-		Entity.Keywords,
-		id,
-	)
-	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
+	return id, nil
 }
 
 func (s *Service) DeleteEntity(id string) error {
-	query := `
-		DELETE FROM entities
-		WHERE id = $1`
-
-	_, err := s.db.Exec(context.Background(), query, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.softDelete("entities", id)
 }
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/spreadsheet"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// exportRows streams the result of query to sheet under header. The query
+// must select one text column per header column; rows are written as they
+// are read, so exports of any size need no more memory than one row.
+func (s *Service) exportRows(
+	sheet spreadsheet.Writer,
+	header []string,
+	format func(values []string),
+	query string,
+	args ...any,
+) error {
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	err = sheet.WriteRow(header)
+	if err != nil {
+		return err
+	}
+
+	values := make([]string, len(header))
+
+	dest := make([]any, len(header))
+	for index := range values {
+		dest[index] = &values[index]
+	}
+
+	for rows.Next() {
+		err = rows.Scan(dest...)
+		if err != nil {
+			return err
+		}
+
+		if format != nil {
+			format(values)
+		}
+
+		err = sheet.WriteRow(values)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ExportProducts writes the products the listing would show for the same
+// parameters, without paging. Parameter values get a column per parameter
+// in use and images are given as public URLs.
+func (s *Service) ExportProducts(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	sheet spreadsheet.Writer,
+) error {
+	filterBy, orderBy := productListClauses(sort, sortDirection, filters, filterOperands, filterConditions)
+
+	rows, err := s.db.Query(context.Background(), `
+		SELECT
+		    id::text,
+		    name
+		FROM
+		    parameters
+		WHERE
+		    EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            product_parameter_values ppv
+		        WHERE
+		            ppv.parameter_id = parameters.id)
+		ORDER BY
+		    name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		header           = []string{"code", "name", "slug", "category", "brand", "price", "count", "keywords", "show", "image", "images"}
+		parameterColumns strings.Builder
+		args             []any
+	)
+
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+
+		args = append(args, id)
+		header = append(header, name)
+		fmt.Fprintf(&parameterColumns, ",\n    COALESCE(ppv_agg.parameter_values ->> $%d, '')", len(args))
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+	SELECT
+    COALESCE(products.code, ''),
+    COALESCE(products.name, ''),
+    COALESCE(products.slug, ''),
+    COALESCE(categories.name, ''),
+    COALESCE(brands.name, ''),
+    COALESCE(products.price, ''),
+    COALESCE(products.count, ''),
+    COALESCE(array_to_string(products.keywords, '، '), ''),
+    COALESCE(products.show::text, ''),
+    COALESCE(i.image_url, ''),
+    COALESCE(img_agg.image_urls, '')%s
+FROM products
+LEFT JOIN brands ON products.brand_id = brands.id
+LEFT JOIN categories ON products.category_id = categories.id
+LEFT JOIN images i ON products.image_id = i.id
+
+LEFT JOIN (
+    SELECT
+        product_id,
+        string_agg(image_url, ' ' ORDER BY position, created_at) AS image_urls
+    FROM images
+    WHERE product_id IS NOT NULL
+    GROUP BY product_id
+) img_agg ON img_agg.product_id = products.id
+
+LEFT JOIN (
+    SELECT
+        product_id,
+        jsonb_object_agg(parameter_id, COALESCE(selectable_value, text_value, bool_value::text)) AS parameter_values
+    FROM product_parameter_values
+    GROUP BY product_id
+) ppv_agg ON ppv_agg.product_id = products.id %s %s;
+		`, parameterColumns.String(), filterBy, orderBy)
+
+	imageColumn := 9
+
+	return s.exportRows(sheet, header, func(values []string) {
+		if values[imageColumn] != "" {
+			values[imageColumn] = utils.FileURL(values[imageColumn])
+		}
+
+		var urls []string
+		for _, name := range strings.Fields(values[imageColumn+1]) {
+			urls = append(urls, utils.FileURL(name))
+		}
+
+		values[imageColumn+1] = strings.Join(urls, " ")
+	}, query, args...)
+}
+
+// ExportInvoices writes a row per invoice item, repeating the invoice
+// columns; invoices without items get a single row.
+func (s *Service) ExportInvoices(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	sheet spreadsheet.Writer,
+) error {
+	filterBy, orderBy := invoiceListClauses(sort, sortDirection, filters, filterOperands, filterConditions)
+
+	// Keep the items of an invoice together, in the order they were added.
+	if orderBy == "" {
+		orderBy = "ORDER BY invoices.number"
+	}
+
+	orderBy += ", invoices.id, invoice_items.created_at"
+
+	header := []string{
+		"number", "date", "type", "person", "phone_number", "discount", "notes",
+		"product_code", "product", "description", "price", "item_discount", "count",
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+		    invoices.number::text,
+		    COALESCE(to_char(invoices.date, 'YYYY-MM-DD HH24:MI'), ''),
+		    invoices.type::text,
+		    CONCAT(persons.name, ' ', persons.first_name),
+		    COALESCE(persons.phone_number, ''),
+		    COALESCE(invoices.discount, ''),
+		    COALESCE(invoices.notes, ''),
+		    COALESCE(products.code, ''),
+		    COALESCE(products.name, ''),
+		    COALESCE(invoice_items.description, ''),
+		    COALESCE(invoice_items.price::text, ''),
+		    COALESCE(invoice_items.discount::text, ''),
+		    COALESCE(invoice_items.count::text, '')
+		FROM
+		    invoices
+		    LEFT JOIN persons ON invoices.person_id = persons.id
+		    LEFT JOIN invoice_items ON invoices.id = invoice_items.invoice_id
+		        AND invoice_items.deleted_at IS NULL
+		    LEFT JOIN products ON invoice_items.product_id = products.id
+		%s %s;
+		`, filterBy, orderBy)
+
+	return s.exportRows(sheet, header, nil, query)
+}
+
+// ExportPersons writes the persons the listing would show for the same
+// parameters, without paging.
+func (s *Service) ExportPersons(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	sheet spreadsheet.Writer,
+) error {
+	filterBy, orderBy := personListClauses(sort, sortDirection, filters, filterOperands, filterConditions)
+
+	query := fmt.Sprintf(`
+		SELECT
+		    COALESCE(persons.first_name, ''),
+		    COALESCE(persons.name, ''),
+		    COALESCE(persons.phone_number, ''),
+		    COALESCE(persons.address, ''),
+		    COALESCE(to_char(persons.created_at, 'YYYY-MM-DD HH24:MI'), '')
+		FROM
+		    persons
+		%s %s
+		`, filterBy, orderBy)
+
+	return s.exportRows(sheet, []string{"first_name", "name", "phone_number", "address", "created_at"}, nil, query)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/imaging"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// resizeSizes are the sides a resized copy can have. Requested sides are
+// rounded up to one of them, so only a few copies of a file are cached.
+var resizeSizes = []int{100, 200, 300, 400, 600, 800, 1200, 1600, 2000}
+
+var ErrInvalidSize = fmt.Errorf("w and h must be between 1 and %d", resizeSizes[len(resizeSizes)-1])
+
+// snapResizeSize rounds size up to the next of resizeSizes, leaving 0 as is.
+func snapResizeSize(size int) int {
+	if size == 0 {
+		return 0
+	}
+
+	index, _ := slices.BinarySearch(resizeSizes, size)
+
+	return resizeSizes[index]
+}
+
+func resizeCacheDir() string {
+	return utils.DefaultInput(os.Getenv("RESIZE_CACHE_DIR"), "./tmp/resized")
+}
+
+// ResizedFile returns the path of a copy of the uploaded file name that fits
+// in width x height (0 leaves a side free), each rounded up to one of
+// resizeSizes, in format as accepted by imaging.Encode. Copies are rendered
+// on first request and kept in RESIZE_CACHE_DIR, which can be emptied at
+// any time.
+func (s *Service) ResizedFile(name string, width int, height int, format string) (string, error) {
+	maxSize := resizeSizes[len(resizeSizes)-1]
+
+	if width < 0 || height < 0 || width > maxSize || height > maxSize || width+height == 0 {
+		return "", ErrInvalidSize
+	}
+
+	width, height = snapResizeSize(width), snapResizeSize(height)
+
+	switch format {
+	case "", "jpeg", "png", "webp":
+	default:
+		return "", imaging.ErrUnknownFormat
+	}
+
+	dir := resizeCacheDir()
+	path := filepath.Join(dir, fmt.Sprintf("%s.w%d.h%d.%s", name, width, height, utils.DefaultInput(format, "auto")))
+
+	_, err := os.Stat(path)
+	if err == nil {
+		return path, nil
+	}
+
+	body, _, err := s.storage.Get(name)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	resized := imaging.Fit(img, width, height)
+
+	var buf bytes.Buffer
+
+	_, err = imaging.Encode(&buf, resized, format)
+	if errors.Is(err, imaging.ErrWebPUnavailable) {
+		// Browsers asking for WebP accept JPEG and PNG as well.
+		_, err = imaging.Encode(&buf, resized, "")
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, "render-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return path, os.Rename(tmp.Name(), path)
+}
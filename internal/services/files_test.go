@@ -0,0 +1,11 @@
+package services
+
+import "testing"
+
+func TestSnapResizeSize(t *testing.T) {
+	for size, want := range map[int]int{0: 0, 1: 100, 100: 100, 101: 200, 750: 800, 2000: 2000} {
+		if got := snapResizeSize(size); got != want {
+			t.Fatalf("snapResizeSize(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
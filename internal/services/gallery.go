@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrUnknownGallery      = errors.New("resource has no gallery")
+	ErrPrimaryNotInGallery = errors.New("primary image must be one of the gallery images")
+	ErrDuplicateGallery    = errors.New("gallery lists an image more than once")
+)
+
+// galleryOwners maps the resources whose images form an ordered gallery to
+// the images column pointing at them.
+var galleryOwners = map[string]string{
+	"products":         "product_id",
+	"categories":       "category_id",
+	"articles":         "article_id",
+	"product_variants": "variant_id",
+}
+
+// galleryOwnerQuery completes query with a condition matching the
+// ownerType row whose id is the param-th argument, skipping trashed rows
+// of the resources that have a trash.
+func galleryOwnerQuery(query string, ownerType string, param int) string {
+	query = fmt.Sprintf(query, ownerType) + fmt.Sprintf(" WHERE id = $%d", param)
+
+	if _, ok := trashResources[ownerType]; ok {
+		query += " AND deleted_at IS NULL"
+	}
+
+	return query
+}
+
+// setGallery makes imageIDs, in this order, the gallery of the ownerType
+// row ownerID. Images dropped from the list are detached, not deleted.
+// Images taken from another gallery leave it, and stop being the primary
+// image of their former owner. An image can only be listed once.
+func setGallery(ctx context.Context, tx pgx.Tx, ownerType string, ownerID any, imageIDs []pgtype.UUID) error {
+	column, ok := galleryOwners[ownerType]
+	if !ok {
+		return ErrUnknownGallery
+	}
+
+	if imageIDs == nil {
+		imageIDs = []pgtype.UUID{}
+	}
+
+	listed := make(map[pgtype.UUID]bool, len(imageIDs))
+
+	for _, imageID := range imageIDs {
+		if listed[imageID] {
+			return ErrDuplicateGallery
+		}
+
+		listed[imageID] = true
+	}
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE
+		    images
+		SET
+		    %[1]s = NULL,
+		    position = 0
+		WHERE
+		    %[1]s = $1
+		    AND NOT id = ANY ($2)`, column),
+		ownerID, imageIDs,
+	)
+	if err != nil {
+		return err
+	}
+
+	set := []string{column + " = $1"}
+
+	for _, otherType := range slices.Sorted(maps.Keys(galleryOwners)) {
+		otherColumn := galleryOwners[otherType]
+
+		_, err = tx.Exec(ctx, fmt.Sprintf(`
+			UPDATE
+			    %[1]s owner
+			SET
+			    image_id = NULL
+			FROM
+			    images
+			WHERE
+			    images.id = ANY ($2)
+			    AND images.%[2]s = owner.id
+			    AND owner.image_id = images.id
+			    AND NOT (owner.id = $1 AND $3)`, otherType, otherColumn),
+			ownerID, imageIDs, otherType == ownerType,
+		)
+		if err != nil {
+			return err
+		}
+
+		if otherColumn != column {
+			set = append(set, otherColumn+" = NULL")
+		}
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE
+		    images
+		SET
+		    %s,
+		    position = ordered.ordinality - 1
+		FROM
+		    unnest($2::uuid[])
+		    WITH ORDINALITY AS ordered (id, ordinality)
+		WHERE
+		    images.id = ordered.id`, strings.Join(set, ", ")),
+		ownerID, imageIDs,
+	)
+
+	return err
+}
+
+// GetGallery returns the images of the ownerType row id in gallery order.
+func (s *Service) GetGallery(ownerType string, id string) (Gallery, error) {
+	gallery := Gallery{
+		ImageIDs: []pgtype.UUID{},
+		Images:   []Image{},
+	}
+
+	column, ok := galleryOwners[ownerType]
+	if !ok {
+		return gallery, ErrUnknownGallery
+	}
+
+	ctx := context.Background()
+
+	err := s.db.QueryRow(
+		ctx,
+		galleryOwnerQuery("SELECT image_id FROM %s", ownerType, 1),
+		id,
+	).Scan(&gallery.PrimaryImageID)
+	if err != nil {
+		return gallery, err
+	}
+
+	rows, err := s.db.Query(ctx, fmt.Sprintf(`
+		SELECT
+		    id,
+		    name,
+		    image_url,
+		    position,
+		    alt,
+		    variants,
+		    width,
+		    height
+		FROM
+		    images
+		WHERE
+		    %s = $1
+		ORDER BY
+		    position,
+		    created_at`, column), id)
+	if err != nil {
+		return gallery, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var image Image
+		if err := rows.Scan(&image.ID, &image.Name, &image.ImageUrl, &image.Position, &image.Alt, &image.Variants, &image.Width, &image.Height); err != nil {
+			return gallery, err
+		}
+
+		gallery.ImageIDs = append(gallery.ImageIDs, image.ID)
+		gallery.Images = append(gallery.Images, image)
+	}
+
+	return gallery, rows.Err()
+}
+
+// SetGallery reorders the gallery of the ownerType row id and sets its
+// primary image in one transaction. Without a primary image the first one
+// of the gallery is used.
+func (s *Service) SetGallery(ownerType string, id string, gallery Gallery) error {
+	if _, ok := galleryOwners[ownerType]; !ok {
+		return ErrUnknownGallery
+	}
+
+	primary := gallery.PrimaryImageID
+
+	if !primary.Valid && len(gallery.ImageIDs) > 0 {
+		primary = gallery.ImageIDs[0]
+	}
+
+	if primary.Valid {
+		found := false
+
+		for _, imageID := range gallery.ImageIDs {
+			if imageID == primary {
+				found = true
+			}
+		}
+
+		if !found {
+			return ErrPrimaryNotInGallery
+		}
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		galleryOwnerQuery("UPDATE %s SET image_id = $1", ownerType, 2),
+		primary,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	err = setGallery(ctx, tx, ownerType, id, gallery.ImageIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
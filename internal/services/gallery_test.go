@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestSetGalleryRejectsDuplicateImages(t *testing.T) {
+	image := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	other := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+
+	// The list is checked before the transaction is used.
+	err := setGallery(context.Background(), nil, "products", uuid.New(), []pgtype.UUID{image, other, image})
+	if !errors.Is(err, ErrDuplicateGallery) {
+		t.Fatalf("got %v, want %v", err, ErrDuplicateGallery)
+	}
+}
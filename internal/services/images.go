@@ -2,14 +2,57 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/imaging"
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
 )
 
+// imageVariants finds the resized copies the uploader stored next to
+// imageUrl. Images uploaded before variants existed have none.
+func (s *Service) imageVariants(imageUrl string) map[string]ImageVariant {
+	if imageUrl == "" {
+		return nil
+	}
+
+	variants := map[string]ImageVariant{}
+
+	exists := func(name string) bool {
+		_, err := s.storage.Stat(name)
+
+		return err == nil
+	}
+
+	for _, variant := range imaging.Variants {
+		for _, ext := range []string{".jpg", ".png"} {
+			name := utils.VariantFileName(imageUrl, variant.Name, ext)
+			if !exists(name) {
+				continue
+			}
+
+			imageVariant := ImageVariant{Url: name}
+
+			webpName := utils.VariantFileName(imageUrl, variant.Name, ".webp")
+			if exists(webpName) {
+				imageVariant.WebpUrl = webpName
+			}
+
+			variants[variant.Name] = imageVariant
+		}
+	}
+
+	if len(variants) == 0 {
+		return nil
+	}
+
+	return variants
+}
+
 func (s *Service) ListImages() ([]Image, error) {
-	query := "SELECT id,name,image_url,category_id,product_id,entity_id,created_at FROM images"
+	query := "SELECT id,name,image_url,category_id,product_id,entity_id,article_id,position,alt,created_at,variants,size,width,height,mime,checksum FROM images"
 
 	rows, err := s.db.Query(context.Background(), query)
 	if err != nil {
@@ -21,7 +64,7 @@ func (s *Service) ListImages() ([]Image, error) {
 
 	for rows.Next() {
 		var image Image
-		if err := rows.Scan(&image.ID, &image.Name, &image.ImageUrl, &image.CategoryID, &image.ProductID, &image.EntityID, &image.CreatedAt); err != nil {
+		if err := rows.Scan(&image.ID, &image.Name, &image.ImageUrl, &image.CategoryID, &image.ProductID, &image.EntityID, &image.ArticleID, &image.Position, &image.Alt, &image.CreatedAt, &image.Variants, &image.Size, &image.Width, &image.Height, &image.Mime, &image.Checksum); err != nil {
 			return []Image{}, err
 		}
 
@@ -39,10 +82,10 @@ func (s *Service) GetImage(id string) (Image, error) {
 		return image, err
 	}
 
-	query := "SELECT id,name,image_url,category_id,product_id,entity_id,created_at FROM images WHERE id=$1"
+	query := "SELECT id,name,image_url,category_id,product_id,entity_id,article_id,position,alt,created_at,variants,size,width,height,mime,checksum FROM images WHERE id=$1"
 	row := s.db.QueryRow(context.Background(), query, parsedUUID)
 
-	err = row.Scan(&image.ID, &image.Name, &image.ImageUrl, &image.CategoryID, &image.ProductID, &image.EntityID, &image.CreatedAt)
+	err = row.Scan(&image.ID, &image.Name, &image.ImageUrl, &image.CategoryID, &image.ProductID, &image.EntityID, &image.ArticleID, &image.Position, &image.Alt, &image.CreatedAt, &image.Variants, &image.Size, &image.Width, &image.Height, &image.Mime, &image.Checksum)
 	if err != nil {
 		return image, err
 	}
@@ -50,49 +93,42 @@ func (s *Service) GetImage(id string) (Image, error) {
 	return image, nil
 }
 
-func (s *Service) CreateImage(image Image) error {
-	query := "INSERT INTO images (id,name,image_url,category_id,product_id,entity_id) VALUES($1,$2,$3,$4,$5,$6)"
+func (s *Service) CreateImage(image Image) (uuid.UUID, error) {
+	query := "INSERT INTO images (id,name,image_url,category_id,product_id,entity_id,article_id,position,alt,variants) VALUES($1,$2,$3,$4,$5,$6,$7,COALESCE($8,0),$9,$10)"
 	validate := utils.NewValidate()
 
 	err := validate.Struct(image)
 	if err != nil {
-		return err
+		return uuid.Nil, err
+	}
+
+	if image.Variants == nil {
+		image.Variants = s.imageVariants(image.ImageUrl)
 	}
 
 	id := uuid.New()
 
-	_, err = s.db.Exec(context.Background(), query, id, image.Name, image.ImageUrl, image.CategoryID, image.ProductID, image.EntityID)
+	_, err = s.db.Exec(context.Background(), query, id, image.Name, image.ImageUrl, image.CategoryID, image.ProductID, image.EntityID, image.ArticleID, image.Position, image.Alt, image.Variants)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
+	return id, nil
 }
 
-func (s *Service) EditImage(id string, image Image) error {
-	query := "UPDATE images SET name=$1,image_url=$2,category_id=$3,product_id=$4,entity_id=$5 WHERE id=$6;"
-	validate := utils.NewValidate()
+func (s *Service) DeleteImage(id string) error {
+	var image Image
 
-	err := validate.Struct(image)
-	if err != nil {
-		return err
-	}
+	query := "DELETE FROM images WHERE id=$1 RETURNING image_url,variants"
 
-	_, err = s.db.Exec(context.Background(), query, image.Name, image.ImageUrl, image.CategoryID, image.ProductID, image.EntityID, id)
-	if err != nil {
-		return err
+	err := s.db.QueryRow(context.Background(), query, id).Scan(&image.ImageUrl, &image.Variants)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
 	}
 
-	return nil
-}
-
-func (s *Service) DeleteImage(id string) error {
-	query := "DELETE FROM images WHERE id=$1"
-
-	_, err := s.db.Exec(context.Background(), query, id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return s.removeUnusedFiles(image)
 }
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+var ErrEmptyImport = errors.New("file has no header row")
+
+// importColumns are the product fields an import file may set. Any other
+// column must be named after a parameter.
+var importColumns = []string{"name", "code", "slug", "price", "count", "category", "brand", "keywords"}
+
+type importParameter struct {
+	id          pgtype.UUID
+	kind        string
+	selectables []string
+}
+
+// importLookups resolves the names used in import files to ids.
+type importLookups struct {
+	categories map[string]pgtype.UUID
+	brands     map[string]pgtype.UUID
+	parameters map[string]importParameter
+}
+
+func loadImportLookups(ctx context.Context, tx pgx.Tx) (importLookups, error) {
+	lookups := importLookups{
+		categories: map[string]pgtype.UUID{},
+		brands:     map[string]pgtype.UUID{},
+		parameters: map[string]importParameter{},
+	}
+
+	for table, ids := range map[string]map[string]pgtype.UUID{
+		"categories": lookups.categories,
+		"brands":     lookups.brands,
+	} {
+		rows, err := tx.Query(ctx, fmt.Sprintf("SELECT id, name FROM %s WHERE deleted_at IS NULL AND name IS NOT NULL", table))
+		if err != nil {
+			return lookups, err
+		}
+
+		for rows.Next() {
+			var (
+				id   pgtype.UUID
+				name string
+			)
+
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+
+				return lookups, err
+			}
+
+			ids[strings.TrimSpace(name)] = id
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return lookups, err
+		}
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id, name, COALESCE(type, ''), COALESCE(selectables, ARRAY[]::varchar[]) FROM parameters")
+	if err != nil {
+		return lookups, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			parameter importParameter
+			name      string
+		)
+
+		if err := rows.Scan(&parameter.id, &name, &parameter.kind, &parameter.selectables); err != nil {
+			return lookups, err
+		}
+
+		lookups.parameters[strings.TrimSpace(name)] = parameter
+	}
+
+	return lookups, rows.Err()
+}
+
+// normalizeNumber accepts amounts as typed in spreadsheets: Persian digits
+// and thousands separators.
+func normalizeNumber(value string) string {
+	value = utils.ReplacePersianDigits(value)
+
+	return strings.NewReplacer(",", "", "٬", "", "،", "", " ", "").Replace(value)
+}
+
+func splitKeywords(value string) []string {
+	keywords := []string{}
+
+	for _, keyword := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '،' || r == '|'
+	}) {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	return keywords
+}
+
+func parseImportBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "بله", "دارد":
+		return true, nil
+	case "0", "false", "no", "خیر", "ندارد":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("%q is not yes or no", value)
+}
+
+// parameterValue stores value in the column matching the parameter: the
+// selectable value when it has choices, the bool value for bool types and
+// the text value otherwise.
+func parameterValue(parameter importParameter, value string) (ProductParameterValue, error) {
+	ppv := ProductParameterValue{ParameterId: parameter.id}
+
+	switch {
+	case len(parameter.selectables) > 0:
+		if !slices.Contains(parameter.selectables, value) {
+			return ppv, fmt.Errorf("%q is not one of %s", value, strings.Join(parameter.selectables, ", "))
+		}
+
+		ppv.SelectableValue = pgtype.Text{String: value, Valid: true}
+	case strings.Contains(strings.ToLower(parameter.kind), "bool"):
+		b, err := parseImportBool(value)
+		if err != nil {
+			return ppv, err
+		}
+
+		ppv.BoolValue = pgtype.Bool{Bool: b, Valid: true}
+	default:
+		ppv.TextValue = pgtype.Text{String: value, Valid: true}
+	}
+
+	return ppv, nil
+}
+
+// importRow is a validated row. Fields left empty in the file are not
+// valid and keep their current value on update.
+type importRow struct {
+	name       pgtype.Text
+	code       pgtype.Text
+	slug       pgtype.Text
+	price      pgtype.Text
+	count      pgtype.Text
+	categoryID pgtype.UUID
+	brandID    pgtype.UUID
+	keywords   []string
+	parameters []ProductParameterValue
+}
+
+func importText(value string) pgtype.Text {
+	return pgtype.Text{String: value, Valid: value != ""}
+}
+
+func parseImportRow(header []string, cells []string, lookups importLookups) (importRow, []ImportRowError) {
+	var (
+		row       importRow
+		rowErrors []ImportRowError
+	)
+
+	fail := func(column string, message string) {
+		rowErrors = append(rowErrors, ImportRowError{Column: column, Message: message})
+	}
+
+	for index, column := range header {
+		if index >= len(cells) {
+			break
+		}
+
+		value := strings.TrimSpace(cells[index])
+		if value == "" || column == "" {
+			continue
+		}
+
+		switch column {
+		case "name":
+			row.name = importText(value)
+		case "code":
+			row.code = importText(utils.ReplacePersianDigits(value))
+		case "slug":
+			row.slug = importText(value)
+		case "price":
+			value = normalizeNumber(value)
+			if price, err := strconv.ParseFloat(value, 64); err != nil || price < 0 {
+				fail(column, "price must be a non-negative number")
+			}
+
+			row.price = importText(value)
+		case "count":
+			value = normalizeNumber(value)
+			if count, err := strconv.Atoi(value); err != nil || count < 0 {
+				fail(column, "count must be a non-negative whole number")
+			}
+
+			row.count = importText(value)
+		case "category":
+			id, ok := lookups.categories[value]
+			if !ok {
+				fail(column, fmt.Sprintf("unknown category %q", value))
+			}
+
+			row.categoryID = id
+		case "brand":
+			id, ok := lookups.brands[value]
+			if !ok {
+				fail(column, fmt.Sprintf("unknown brand %q", value))
+			}
+
+			row.brandID = id
+		case "keywords":
+			row.keywords = splitKeywords(value)
+		default:
+			ppv, err := parameterValue(lookups.parameters[column], value)
+			if err != nil {
+				fail(column, err.Error())
+			}
+
+			row.parameters = append(row.parameters, ppv)
+		}
+	}
+
+	if !row.code.Valid && !row.slug.Valid {
+		fail("", "code or slug is required")
+	}
+
+	return row, rowErrors
+}
+
+// findImportedProduct returns the id of the product with the code of row,
+// or else its slug. The id is not valid when there is none.
+func findImportedProduct(ctx context.Context, tx pgx.Tx, row importRow) (pgtype.UUID, error) {
+	var id pgtype.UUID
+
+	column, value := "code", row.code
+	if !row.code.Valid {
+		column, value = "slug", row.slug
+	}
+
+	err := tx.QueryRow(
+		ctx,
+		fmt.Sprintf("SELECT id FROM products WHERE %s = $1 AND deleted_at IS NULL LIMIT 1", column),
+		value,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return id, nil
+	}
+
+	return id, err
+}
+
+// saveImportRow creates or updates the product of row and returns the
+// audit entry for it, with the product as it was before an update.
+func saveImportRow(ctx context.Context, tx pgx.Tx, row importRow) (AuditLog, error) {
+	entry := AuditLog{ResourceType: "products", Action: "update"}
+
+	id, err := findImportedProduct(ctx, tx, row)
+	if err != nil {
+		return entry, err
+	}
+
+	if !id.Valid {
+		if !row.name.Valid {
+			return entry, errors.New("name is required for new products")
+		}
+
+		entry.Action = "create"
+		newID := uuid.New()
+		id = pgtype.UUID{Bytes: newID, Valid: true}
+
+		// Imported products are shown right away; the file has no column
+		// to hide them.
+		_, err = tx.Exec(ctx, `
+			INSERT INTO products (id, name, code, slug, price, count, category_id, brand_id, keywords, show)
+			    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, ARRAY[]::varchar[]), TRUE)`,
+			id, row.name, row.code, row.slug, row.price, row.count, row.categoryID, row.brandID, row.keywords,
+		)
+	} else {
+		entry.Before, err = auditSnapshot(ctx, tx, auditSnapshotQueries["products"], id)
+		if err != nil {
+			return entry, err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE
+			    products
+			SET
+			    name = COALESCE($2, name),
+			    code = COALESCE($3, code),
+			    slug = COALESCE($4, slug),
+			    price = COALESCE($5, price),
+			    count = COALESCE($6, count),
+			    category_id = COALESCE($7, category_id),
+			    brand_id = COALESCE($8, brand_id),
+			    keywords = COALESCE($9, keywords),
+			    updated_at = now()
+			WHERE
+			    id = $1`,
+			id, row.name, row.code, row.slug, row.price, row.count, row.categoryID, row.brandID, row.keywords,
+		)
+	}
+
+	if err != nil {
+		return entry, err
+	}
+
+	for _, ppv := range row.parameters {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO product_parameter_values (product_id, parameter_id, text_value, bool_value, selectable_value)
+			    VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (parameter_id, product_id)
+			    DO UPDATE SET
+			        text_value = EXCLUDED.text_value,
+			        bool_value = EXCLUDED.bool_value,
+			        selectable_value = EXCLUDED.selectable_value`,
+			id, ppv.ParameterId, ppv.TextValue, ppv.BoolValue, ppv.SelectableValue,
+		)
+		if err != nil {
+			return entry, err
+		}
+	}
+
+	entry.ResourceID = id
+
+	return entry, nil
+}
+
+// ImportProducts creates or updates a product per row, matched by code or
+// else slug. The first row names the columns: importColumns or parameter
+// names. All rows are saved in one transaction, which is rolled back in a
+// dry run or when any row fails.
+func (s *Service) ImportProducts(rows [][]string, dryRun bool) (ImportReport, error) {
+	report := ImportReport{
+		DryRun: dryRun,
+		Errors: []ImportRowError{},
+	}
+
+	if len(rows) == 0 {
+		return report, ErrEmptyImport
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = setPriceSource(ctx, tx, "import")
+	if err != nil {
+		return report, err
+	}
+
+	lookups, err := loadImportLookups(ctx, tx)
+	if err != nil {
+		return report, err
+	}
+
+	header := make([]string, len(rows[0]))
+
+	for index, cell := range rows[0] {
+		column := strings.TrimSpace(cell)
+		if lowered := strings.ToLower(column); slices.Contains(importColumns, lowered) {
+			column = lowered
+		} else if _, ok := lookups.parameters[column]; !ok && column != "" {
+			report.Errors = append(report.Errors, ImportRowError{Row: 1, Column: column, Message: "unknown column"})
+
+			continue
+		}
+
+		if slices.Contains(header, column) && column != "" {
+			report.Errors = append(report.Errors, ImportRowError{Row: 1, Column: column, Message: "duplicate column"})
+
+			continue
+		}
+
+		header[index] = column
+	}
+
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	for index, cells := range rows[1:] {
+		rowNumber := index + 2
+
+		if strings.TrimSpace(strings.Join(cells, "")) == "" {
+			continue
+		}
+
+		row, rowErrors := parseImportRow(header, cells, lookups)
+		if len(rowErrors) > 0 {
+			for _, rowError := range rowErrors {
+				rowError.Row = rowNumber
+				report.Errors = append(report.Errors, rowError)
+			}
+
+			continue
+		}
+
+		// A savepoint per row keeps the transaction usable after a row
+		// fails, so later rows are still checked.
+		rowTx, err := tx.Begin(ctx)
+		if err != nil {
+			return report, err
+		}
+
+		entry, err := saveImportRow(ctx, rowTx, row)
+		if err != nil {
+			rowTx.Rollback(ctx)
+			report.Errors = append(report.Errors, ImportRowError{Row: rowNumber, Message: err.Error()})
+
+			continue
+		}
+
+		err = rowTx.Commit(ctx)
+		if err != nil {
+			return report, err
+		}
+
+		if entry.Action == "create" {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+
+		// A product saved by an earlier row already has its entry, with
+		// the snapshot from before the import.
+		if !slices.ContainsFunc(report.Audit, func(saved AuditLog) bool { return saved.ResourceID == entry.ResourceID }) {
+			report.Audit = append(report.Audit, entry)
+		}
+	}
+
+	if dryRun || len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	report.Committed = true
+
+	return report, nil
+}
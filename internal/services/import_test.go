@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseImportRow(t *testing.T) {
+	lookups := importLookups{
+		categories: map[string]pgtype.UUID{"فیلتر": {Bytes: [16]byte{1}, Valid: true}},
+		brands:     map[string]pgtype.UUID{},
+		parameters: map[string]importParameter{
+			"رنگ": {id: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, selectables: []string{"مشکی", "سفید"}},
+		},
+	}
+	header := []string{"name", "code", "price", "count", "category", "keywords", "رنگ"}
+
+	row, rowErrors := parseImportRow(header, []string{"فیلتر روغن", "A-۱۲", "۱٬۲۵۰٬۰۰۰", "۳", "فیلتر", "روغن، فیلتر", "مشکی"}, lookups)
+	if len(rowErrors) > 0 {
+		t.Fatalf("unexpected errors %+v", rowErrors)
+	}
+
+	if row.code.String != "A-12" || row.price.String != "1250000" || row.count.String != "3" {
+		t.Fatalf("digits not normalized: %+v", row)
+	}
+
+	if len(row.keywords) != 2 || row.keywords[1] != "فیلتر" {
+		t.Fatalf("got keywords %q", row.keywords)
+	}
+
+	if len(row.parameters) != 1 || row.parameters[0].SelectableValue.String != "مشکی" {
+		t.Fatalf("got parameters %+v", row.parameters)
+	}
+
+	_, rowErrors = parseImportRow(header, []string{"", "", "x", "-1", "لنت", "", "قرمز"}, lookups)
+	if len(rowErrors) != 5 {
+		t.Fatalf("got %d errors, want 5: %+v", len(rowErrors), rowErrors)
+	}
+}
@@ -13,10 +13,10 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func (s *Service) CreateInvoice(inv Invoice) error {
+func (s *Service) CreateInvoice(inv Invoice) (uuid.UUID, error) {
 	tx, err := s.db.BeginTx(context.Background(), pgx.TxOptions{})
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	query := `
@@ -37,7 +37,7 @@ func (s *Service) CreateInvoice(inv Invoice) error {
 	if err != nil {
 		tx.Rollback(context.Background())
 
-		return err
+		return uuid.Nil, err
 	}
 
 	for _, item := range inv.Items {
@@ -58,16 +58,20 @@ func (s *Service) CreateInvoice(inv Invoice) error {
 		if err != nil {
 			tx.Rollback(context.Background())
 
-			return err
+			return uuid.Nil, err
 		}
 	}
 
 	err = tx.Commit(context.Background())
 	if err != nil {
-		return err
+		return uuid.Nil, err
+	}
+
+	if inv.Type == "sell" {
+		go s.sendOrderConfirmation(invoiceId.String())
 	}
 
-	return nil
+	return invoiceId, nil
 }
 
 func (s *Service) GetInvoice(id string) (Invoice, error) {
@@ -101,6 +105,7 @@ FROM
     LEFT JOIN products ON invoice_items.product_id = products.id
 WHERE
     invoices.id = $1
+    AND invoices.deleted_at IS NULL
 GROUP BY
     invoices.id,
     persons.name,
@@ -129,16 +134,15 @@ GROUP BY
 	return inv, nil
 }
 
-func (s *Service) ListInvoicesWithSortFilterPagination(
+// invoiceListClauses builds the WHERE and ORDER BY clauses of the invoice
+// listing and export from their query parameters.
+func invoiceListClauses(
 	sort string,
 	sortDirection string,
 	filters []string,
 	filterOperands []string,
 	filterConditions []string,
-	countInPage string,
-	offset string,
-	w http.ResponseWriter,
-) {
+) (string, string) {
 	orderBy := ""
 
 	if sort != "" {
@@ -158,7 +162,9 @@ func (s *Service) ListInvoicesWithSortFilterPagination(
 
 	var filterBy strings.Builder
 	if len(filters) > 0 {
-		filterBy.WriteString("WHERE ")
+		filterBy.WriteString("WHERE invoices.deleted_at IS NULL AND ")
+	} else {
+		filterBy.WriteString("WHERE invoices.deleted_at IS NULL ")
 	}
 
 	for index, filter := range filters {
@@ -193,6 +199,21 @@ func (s *Service) ListInvoicesWithSortFilterPagination(
 		}
 	}
 
+	return filterBy.String(), orderBy
+}
+
+func (s *Service) ListInvoicesWithSortFilterPagination(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	countInPage string,
+	offset string,
+	w http.ResponseWriter,
+) {
+	filterBy, orderBy := invoiceListClauses(sort, sortDirection, filters, filterOperands, filterConditions)
+
 	pagedBy := ""
 	offsetNum := 0
 
@@ -251,7 +272,7 @@ GROUP BY
     persons.first_name
     %s %s;
 		`,
-		filterBy.String(), orderBy, pagedBy)
+		filterBy, orderBy, pagedBy)
 
 	rows, err := s.db.Query(context.Background(), query)
 	if err != nil {
@@ -281,7 +302,7 @@ GROUP BY
 		    invoices
 		    LEFT JOIN persons ON invoices.person_id = persons.id
 		%s
-		`, filterBy.String())
+		`, filterBy)
 	row := s.db.QueryRow(context.Background(), newQuery)
 
 	var Count int32
@@ -309,48 +330,28 @@ GROUP BY
 	}
 }
 
-func (s *Service) EditInvoice(id string, invoice Invoice) error {
-	ctx := context.Background()
-
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		return err
+// patchInvoiceRelated replaces the items of the invoice when items is
+// given: items with an id are updated, those without one added and the
+// ones left out deleted.
+func patchInvoiceRelated(ctx context.Context, tx pgx.Tx, id uuid.UUID, patch map[string]json.RawMessage) error {
+	raw, ok := patch["items"]
+	if !ok {
+		return nil
 	}
-	// ✅ Defer rollback ensures the tx is closed on panic or early return.
-	// (If tx is already committed, rollback safely does nothing in pgx)
-	defer tx.Rollback(ctx)
 
-	updateInvoiceQuery := `
-		UPDATE
-		    invoices
-		SET
-		    person_id = $1,
-		    type = $2,
-		    notes = $3,
-	      date  = $4
-		WHERE
-		    id = $5`
-
-	_, err = tx.Exec(ctx, updateInvoiceQuery,
-		invoice.PersonID,
-		invoice.Type,
-		invoice.Notes,
-		invoice.Date,
-		id,
-	)
-	if err != nil {
-		return err
+	var items []InvoiceItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return fmt.Errorf("items: %w", err)
 	}
 
-	itemIDs := make([]uuid.UUID, 0, len(invoice.Items))
+	itemIDs := make([]uuid.UUID, 0, len(items))
 
-	for _, item := range invoice.Items {
+	for _, item := range items {
 		if item.ID == uuid.Nil {
 			item.ID = uuid.New()
 		}
 
 		itemIDs = append(itemIDs, item.ID)
-		fmt.Println(item.Discount.Value())
 
 		itemQuery := `
 			INSERT INTO invoice_items (id, invoice_id, description, price, product_id, count, discount)
@@ -363,7 +364,7 @@ func (s *Service) EditInvoice(id string, invoice Invoice) error {
 			        count = EXCLUDED.count,
 			        discount = EXCLUDED.discount`
 
-		_, err = tx.Exec(ctx, itemQuery,
+		_, err := tx.Exec(ctx, itemQuery,
 			item.ID,
 			id,
 			item.Description,
@@ -377,29 +378,16 @@ func (s *Service) EditInvoice(id string, invoice Invoice) error {
 		}
 	}
 
-	// ✅ Safer array comparison for empty slices
 	deleteQuery := `
 		DELETE FROM invoice_items
 		WHERE invoice_id = $1
 		    AND id != ALL($2::UUID[])`
 
-	_, err = tx.Exec(ctx, deleteQuery, id, itemIDs) // ✅ Changed from invoice.ID to id
-	if err != nil {
-		return err
-	}
+	_, err := tx.Exec(ctx, deleteQuery, id, itemIDs)
 
-	return tx.Commit(ctx)
+	return err
 }
 
 func (s *Service) DeleteInvoice(id string) error {
-	query := `
-		DELETE FROM invoices
-		WHERE id = $1`
-
-	_, err := s.db.Exec(context.Background(), query, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.softDelete("invoices", id)
 }
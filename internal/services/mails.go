@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/mails"
+)
+
+const (
+	verificationTokenTTL = 48 * time.Hour
+	passwordResetTTL     = 24 * time.Hour
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email not verified")
+	ErrTokenNotValid    = errors.New("token not valid")
+	ErrNoEmail          = errors.New("no email address to send to")
+)
+
+func newToken() (string, error) {
+	bytes := make([]byte, 16) // 16 bytes = 32 hex characters
+
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+func (s *Service) SendVerificationEmail(email string) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(context.Background(), `
+		UPDATE
+		    users
+		SET
+		    verification_token = $1,
+		    verification_token_expires = $2
+		WHERE
+		    email = $3
+		    AND email_verified IS NOT TRUE`,
+		token,
+		time.Now().Add(verificationTokenTTL),
+		email,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	mail, err := mails.Verification(email, mails.VerificationData{
+		Email:          email,
+		Link:           os.Getenv("BASE_URL") + "/verify-email-callback/" + token,
+		ExpiresInHours: int(verificationTokenTTL.Hours()),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail)
+}
+
+func (s *Service) VerifyEmail(token string) error {
+	tag, err := s.db.Exec(context.Background(), `
+		UPDATE
+		    users
+		SET
+		    email_verified = TRUE,
+		    verification_token = NULL,
+		    verification_token_expires = NULL
+		WHERE
+		    verification_token = $1
+		    AND verification_token_expires > now()`,
+		token,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrTokenNotValid
+	}
+
+	return nil
+}
+
+func (s *Service) SendPasswordResetEmail(email string) error {
+	user, err := s.GetUser(email)
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	user.Token.String = token
+	user.Token.Valid = true
+	expires := time.Now().Add(passwordResetTTL)
+	user.TokenExpires = &expires
+
+	err = s.EditUser(user)
+	if err != nil {
+		return err
+	}
+
+	mail, err := mails.PasswordReset(email, mails.PasswordResetData{
+		Link:           os.Getenv("BASE_URL") + "/reset-password-callback/" + token,
+		ExpiresInHours: int(passwordResetTTL.Hours()),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail)
+}
+
+// SendInvoiceEmail mails invoice id to the given address, or to the verified
+// email of the user linked to the invoice's person when to is empty.
+func (s *Service) SendInvoiceEmail(id string, to string) error {
+	data, email, err := s.invoiceMailData(id)
+	if err != nil {
+		return err
+	}
+
+	if to == "" {
+		to = email
+	}
+
+	if to == "" {
+		return ErrNoEmail
+	}
+
+	mail, err := mails.Invoice(to, data)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail)
+}
+
+// sendOrderConfirmation is best effort: a sell invoice is saved even when the
+// customer has no verified email or the mail cannot be delivered. It runs in
+// the background so a slow mail server does not hold up the invoice.
+func (s *Service) sendOrderConfirmation(id string) {
+	data, email, err := s.invoiceMailData(id)
+	if err != nil || email == "" {
+		return
+	}
+
+	mail, err := mails.OrderConfirmation(email, data)
+	if err == nil {
+		err = s.mailer.Send(mail)
+	}
+
+	if err != nil {
+		log.Printf("order confirmation for invoice %s: %v", id, err)
+	}
+}
+
+func (s *Service) invoiceMailData(id string) (mails.InvoiceData, string, error) {
+	invoice, err := s.GetInvoice(id)
+	if err != nil {
+		return mails.InvoiceData{}, "", err
+	}
+
+	var email pgtype.Text
+
+	err = s.db.QueryRow(context.Background(), `
+		SELECT
+		    email
+		FROM
+		    users
+		WHERE
+		    person_id = $1
+		    AND email_verified IS TRUE
+		LIMIT 1`, invoice.PersonID).Scan(&email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return mails.InvoiceData{}, "", err
+	}
+
+	data := mails.InvoiceData{
+		Number:     invoice.Number.String,
+		Date:       invoice.Date,
+		PersonName: invoice.PersonName.String,
+		Notes:      invoice.Notes,
+	}
+
+	for _, item := range invoice.Items {
+		price, _ := strconv.ParseFloat(item.Price.String, 64)
+		discount, _ := strconv.ParseFloat(item.Discount.String, 64)
+		count, _ := strconv.Atoi(item.Count.String)
+		total := price*float64(count) - discount
+
+		data.Items = append(data.Items, mails.InvoiceItemData{
+			Name:     item.ProductName.String,
+			Count:    count,
+			Price:    price,
+			Discount: discount,
+			Total:    total,
+		})
+		data.Total += total
+	}
+
+	data.Discount, _ = strconv.ParseFloat(invoice.Discount.String, 64)
+	data.Total -= data.Discount
+
+	return data, email.String, nil
+}
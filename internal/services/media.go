@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/imaging"
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+// Uploads get this long to be attached to a product, category, entity or
+// article before the garbage collector treats them as orphans.
+const mediaGCGracePeriod = 24 * time.Hour
+
+// richTextColumns are the HTML columns that can embed uploads by URL without
+// pointing at their images row.
+var richTextColumns = []string{
+	"products.description",
+	"products.info",
+	"categories.description",
+	"brands.description",
+	"entities.description",
+	"articles.description",
+}
+
+// richTextQuery returns every richTextColumns value, one per row.
+func richTextQuery() string {
+	selects := make([]string, 0, len(richTextColumns))
+
+	for _, column := range richTextColumns {
+		table, _, _ := strings.Cut(column, ".")
+		selects = append(selects, fmt.Sprintf("SELECT %s FROM %s WHERE %s <> ''", column, table, column))
+	}
+
+	return strings.Join(selects, " UNION ALL ")
+}
+
+// orphanImagesCondition matches images rows nothing points at, directly or
+// from the HTML of richTextColumns.
+var orphanImagesCondition = `
+		    i.product_id IS NULL
+		    AND i.category_id IS NULL
+		    AND i.entity_id IS NULL
+		    AND i.article_id IS NULL
+		    AND i.variant_id IS NULL
+		    AND i.created_at < $1
+		    AND NOT EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            products p
+		        WHERE
+		            p.image_id = i.id)
+		    AND NOT EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            product_variants pv
+		        WHERE
+		            pv.image_id = i.id)
+		    AND NOT EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            categories c
+		        WHERE
+		            c.image_id = i.id)
+		    AND NOT EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            entities e
+		        WHERE
+		            e.image_id = i.id)
+		    AND NOT EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            articles a
+		        WHERE
+		            a.image_id = i.id)
+		    AND NOT EXISTS (
+		        SELECT
+		            1
+		        FROM (` + richTextQuery() + `) AS rich_text (html)
+		        WHERE
+		            rich_text.html LIKE '%' || i.image_url || '%')`
+
+// mediaKey is the random part shared by an upload and its variants:
+// "aB3dE9xZ" for both "aB3dE9xZ.jpg" and "aB3dE9xZ_thumbnail.webp".
+func mediaKey(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	if index := strings.Index(name, "_"); index >= 0 {
+		name = name[:index]
+	}
+
+	return name
+}
+
+// UploadImage stores an uploaded image with its variants and registers it
+// as an images row. A file whose checksum is already known is not stored
+// again; the new row shares the existing file.
+func (s *Service) UploadImage(data []byte, name string) (Image, error) {
+	ctx := context.Background()
+
+	sum := sha256.Sum256(data)
+
+	image := Image{
+		Name: name,
+	}
+	image.Checksum.String = hex.EncodeToString(sum[:])
+	image.Checksum.Valid = true
+
+	err := s.db.QueryRow(ctx, `
+		SELECT
+		    image_url,
+		    variants,
+		    size,
+		    width,
+		    height,
+		    mime
+		FROM
+		    images
+		WHERE
+		    checksum = $1
+		LIMIT 1`, image.Checksum).Scan(&image.ImageUrl, &image.Variants, &image.Size, &image.Width, &image.Height, &image.Mime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = s.storeImageFiles(data, &image)
+	}
+
+	if err != nil {
+		return image, err
+	}
+
+	id := uuid.New()
+
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO images (id, name, image_url, variants, size, width, height, mime, checksum)
+		    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING
+		    created_at`,
+		id,
+		image.Name,
+		image.ImageUrl,
+		image.Variants,
+		image.Size,
+		image.Width,
+		image.Height,
+		image.Mime,
+		image.Checksum,
+	).Scan(&image.CreatedAt)
+	if err != nil {
+		return image, err
+	}
+
+	image.ID.Bytes = id
+	image.ID.Valid = true
+
+	return image, nil
+}
+
+func (s *Service) storeImageFiles(data []byte, image *Image) error {
+	processed, err := imaging.Process(data)
+	if err != nil {
+		return err
+	}
+
+	randomString, err := utils.RandomString(8)
+	if err != nil {
+		return err
+	}
+
+	fileName := randomString + processed.Original.Ext
+
+	err = s.storage.Put(fileName, processed.Original.Data, processed.ContentType)
+	if err != nil {
+		return err
+	}
+
+	image.ImageUrl = fileName
+	image.Variants = map[string]ImageVariant{}
+
+	for _, variant := range processed.Variants {
+		variantName := utils.VariantFileName(fileName, variant.Name, variant.Ext)
+
+		err = s.storage.Put(variantName, variant.Data, "image/"+strings.TrimPrefix(variant.Ext, "."))
+		if err != nil {
+			return err
+		}
+
+		imageVariant := ImageVariant{Url: variantName}
+
+		if len(variant.WebP) > 0 {
+			imageVariant.WebpUrl = utils.VariantFileName(fileName, variant.Name, ".webp")
+
+			err = s.storage.Put(imageVariant.WebpUrl, variant.WebP, "image/webp")
+			if err != nil {
+				return err
+			}
+		}
+
+		image.Variants[variant.Name] = imageVariant
+	}
+
+	_ = image.Size.Scan(int64(len(processed.Original.Data)))
+	_ = image.Width.Scan(int64(processed.Original.Width))
+	_ = image.Height.Scan(int64(processed.Original.Height))
+	_ = image.Mime.Scan(processed.ContentType)
+
+	return nil
+}
+
+// imageFileNames lists the stored files of an image: the original and
+// every variant.
+func imageFileNames(image Image) []string {
+	names := []string{image.ImageUrl}
+
+	for _, variant := range image.Variants {
+		names = append(names, variant.Url)
+
+		if variant.WebpUrl != "" {
+			names = append(names, variant.WebpUrl)
+		}
+	}
+
+	return names
+}
+
+// removeUnusedFiles deletes the files of a removed images row unless
+// another row shares them.
+func (s *Service) removeUnusedFiles(image Image) error {
+	if image.ImageUrl == "" {
+		return nil
+	}
+
+	var shared bool
+
+	err := s.db.QueryRow(
+		context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM images WHERE image_url = $1)",
+		image.ImageUrl,
+	).Scan(&shared)
+	if err != nil || shared {
+		return err
+	}
+
+	for _, name := range imageFileNames(image) {
+		err = s.storage.Delete(name)
+		if err != nil && !errors.Is(err, utils.ErrObjectNotFound) {
+			return err
+		}
+	}
+
+	s.removeResizedFiles(image.ImageUrl)
+
+	return nil
+}
+
+// CollectMediaGarbage finds images rows nothing refers to and stored files
+// no images row refers to. Unless dryRun is set they are deleted.
+func (s *Service) CollectMediaGarbage(dryRun bool) (MediaGCReport, error) {
+	ctx := context.Background()
+
+	report := MediaGCReport{
+		DryRun:       dryRun,
+		OrphanImages: []string{},
+		OrphanFiles:  []string{},
+	}
+
+	cutoff := time.Now().Add(-mediaGCGracePeriod)
+
+	query := "SELECT i.id FROM images i WHERE " + orphanImagesCondition
+	if !dryRun {
+		query = "DELETE FROM images i WHERE " + orphanImagesCondition + " RETURNING i.id"
+	}
+
+	rows, err := s.db.Query(ctx, query, cutoff)
+	if err != nil {
+		return report, err
+	}
+
+	orphanIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return report, err
+	}
+
+	for _, id := range orphanIDs {
+		report.OrphanImages = append(report.OrphanImages, id.String())
+	}
+
+	// Files used only by orphan rows go with them, so those rows are left
+	// out even in a dry run, where they still exist.
+	rows, err = s.db.Query(ctx, `
+		SELECT
+		    image_url,
+		    variants
+		FROM
+		    images
+		WHERE
+		    NOT id = ANY ($1)`, orphanIDs)
+	if err != nil {
+		return report, err
+	}
+
+	referenced := map[string]bool{}
+
+	for rows.Next() {
+		var image Image
+		if err := rows.Scan(&image.ImageUrl, &image.Variants); err != nil {
+			return report, err
+		}
+
+		for _, name := range imageFileNames(image) {
+			referenced[name] = true
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	// Rich text may embed uploads in its HTML without an images row.
+	rows, err = s.db.Query(ctx, richTextQuery())
+	if err != nil {
+		return report, err
+	}
+
+	richText, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return report, err
+	}
+
+	objects, err := s.storage.List()
+	if err != nil {
+		return report, err
+	}
+
+	for _, object := range orphanFiles(objects, referenced, richText, cutoff) {
+		report.OrphanFiles = append(report.OrphanFiles, object.Name)
+		report.FreedBytes += object.Size
+
+		if dryRun {
+			continue
+		}
+
+		err = s.storage.Delete(object.Name)
+		if err != nil {
+			return report, err
+		}
+
+		s.removeResizedFiles(object.Name)
+	}
+
+	return report, nil
+}
+
+// orphanFiles returns the objects older than cutoff that are neither
+// referenced by an images row nor embedded in richText.
+func orphanFiles(objects []utils.ObjectInfo, referenced map[string]bool, richText []string, cutoff time.Time) []utils.ObjectInfo {
+	html := strings.Join(richText, "\n")
+
+	var orphans []utils.ObjectInfo
+
+	for _, object := range objects {
+		if referenced[object.Name] || object.LastModified.After(cutoff) || strings.Contains(html, mediaKey(object.Name)) {
+			continue
+		}
+
+		orphans = append(orphans, object)
+	}
+
+	return orphans
+}
+
+// RunMediaGC collects media garbage every interval and logs what it removed.
+func (s *Service) RunMediaGC(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := s.CollectMediaGarbage(false)
+		if err != nil {
+			log.Printf("media gc: %v", err)
+
+			continue
+		}
+
+		log.Printf(
+			"media gc: removed %d images rows and %d files (%d bytes)",
+			len(report.OrphanImages),
+			len(report.OrphanFiles),
+			report.FreedBytes,
+		)
+	}
+}
+
+// removeResizedFiles drops the cached renders of name made by ResizedFile.
+func (s *Service) removeResizedFiles(name string) {
+	matches, _ := filepath.Glob(filepath.Join(resizeCacheDir(), name+".w*"))
+	for _, match := range matches {
+		_ = os.Remove(match)
+	}
+}
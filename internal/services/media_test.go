@@ -0,0 +1,29 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+func TestOrphanFilesKeepsProductHTMLUploads(t *testing.T) {
+	if !slices.Contains(richTextColumns, "products.description") {
+		t.Fatalf("products.description is not scanned: %q", richTextColumns)
+	}
+
+	cutoff := time.Now().Add(-mediaGCGracePeriod)
+	old := cutoff.Add(-time.Hour)
+	objects := []utils.ObjectInfo{
+		{Name: "aB3dE9xZ.jpg", LastModified: old},
+		{Name: "aB3dE9xZ_thumbnail.webp", LastModified: old},
+		{Name: "orphan12.png", LastModified: old},
+	}
+	richText := []string{`<p>فیلتر روغن</p><img src="/files/aB3dE9xZ.jpg">`}
+
+	orphans := orphanFiles(objects, map[string]bool{}, richText, cutoff)
+	if len(orphans) != 1 || orphans[0].Name != "orphan12.png" {
+		t.Fatalf("got orphans %+v, want only orphan12.png", orphans)
+	}
+}
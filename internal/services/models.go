@@ -1,6 +1,7 @@
 package services
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,7 +14,7 @@ type Child struct {
 	Slug        pgtype.Text `json:"slug"`
 	ParentID    pgtype.UUID `json:"parentId"`
 	Description pgtype.Text `json:"description"`
-	Priority    pgtype.Text `json:"priority"`
+	Priority    int32       `json:"priority"`
 	CreatedAt   time.Time   `json:"createdAt"`
 }
 
@@ -23,15 +24,46 @@ type Category struct {
 	ParentID    pgtype.UUID `json:"parentId"`
 	ParentName  pgtype.Text `json:"parentName"`
 	Description pgtype.Text `json:"description"`
-	Priority    pgtype.Text `json:"priority"`
+	Priority    int32       `json:"priority"`
 	ImageID     pgtype.UUID `json:"imageId"`
 	ImageUrl    pgtype.Text `json:"imageUrl"`
 	Show        bool        `json:"show"`
 	Children    []Child     `json:"children"`
 	Slug        pgtype.Text `json:"slug"`
 	TotalCount  int32       `json:"total_count"`
-	CreatedAt   time.Time   `json:"createdAt"`
-	UpdatedAt   time.Time   `json:"updatedAt"`
+	// Breadcrumbs are the categories from the top level down to this one.
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+}
+
+// Breadcrumb is a category on the path from the top level to a category or
+// product.
+type Breadcrumb struct {
+	ID   pgtype.UUID `json:"id"`
+	Name pgtype.Text `json:"name"`
+	Slug pgtype.Text `json:"slug"`
+}
+
+// CategoryNode is a category in the category tree. Depth is how many levels
+// it is below the category a subtree was asked for.
+type CategoryNode struct {
+	ID       pgtype.UUID    `json:"id"`
+	Name     pgtype.Text    `json:"name"`
+	Slug     pgtype.Text    `json:"slug"`
+	ParentID pgtype.UUID    `json:"parentId"`
+	Priority int32          `json:"priority"`
+	Show     bool           `json:"show"`
+	Depth    int32          `json:"depth"`
+	Children []CategoryNode `json:"children,omitempty"`
+}
+
+// CategoryMove puts a category, with everything below it, under ParentID,
+// or at the top level without it, at Priority among its new siblings; by
+// default it goes last.
+type CategoryMove struct {
+	ParentID pgtype.UUID `json:"parentId"`
+	Priority pgtype.Int4 `json:"priority"`
 }
 
 type Entity struct {
@@ -62,13 +94,35 @@ type ChildEntity struct {
 }
 
 type Image struct {
-	ID         pgtype.UUID `json:"id"`
-	Name       string      `json:"name"`
-	ImageUrl   string      `json:"imageUrl"`
-	CategoryID pgtype.UUID `json:"categoryId"`
-	ProductID  pgtype.UUID `json:"productId"`
-	EntityID   pgtype.UUID `json:"EntityId"`
-	CreatedAt  time.Time   `json:"createdAt"`
+	ID         pgtype.UUID             `json:"id"`
+	Name       string                  `json:"name"`
+	ImageUrl   string                  `json:"imageUrl"`
+	CategoryID pgtype.UUID             `json:"categoryId"`
+	ProductID  pgtype.UUID             `json:"productId"`
+	EntityID   pgtype.UUID             `json:"EntityId"`
+	ArticleID  pgtype.UUID             `json:"articleId"`
+	Position   pgtype.Int4             `json:"position"`
+	Alt        pgtype.Text             `json:"alt"`
+	CreatedAt  time.Time               `json:"createdAt"`
+	Variants   map[string]ImageVariant `json:"variants"`
+	Size       pgtype.Int8             `json:"size"`
+	Width      pgtype.Int4             `json:"width"`
+	Height     pgtype.Int4             `json:"height"`
+	Mime       pgtype.Text             `json:"mime"`
+	Checksum   pgtype.Text             `json:"checksum"`
+}
+
+type ImageVariant struct {
+	Url     string `json:"url"`
+	WebpUrl string `json:"webpUrl,omitempty"`
+}
+
+// Gallery is the ordered images of a product, category or article and the
+// one among them shown as its main image.
+type Gallery struct {
+	ImageIDs       []pgtype.UUID `json:"imageIds"`
+	PrimaryImageID pgtype.UUID   `json:"primaryImageId"`
+	Images         []Image       `json:"images"`
 }
 
 type Article struct {
@@ -110,10 +164,360 @@ type Product struct {
 	Show                   pgtype.Bool             `json:"show"`
 	Position               pgtype.Text             `json:"position"`
 	Code                   pgtype.Text             `json:"code"`
+	RatingAverage          pgtype.Float8           `json:"ratingAverage"`
+	RatingCount            int32                   `json:"ratingCount"`
+	Relations              ProductRelations        `json:"relations,omitempty"`
+	Questions              []PublicQuestion        `json:"questions,omitempty"`
+	Breadcrumbs            []Breadcrumb            `json:"breadcrumbs,omitempty"`
 	CreatedAt              time.Time               `json:"createdAt"`
 	UpdatedAt              time.Time               `json:"updatedAt"`
 }
 
+// RelatedProduct is a product listed with another one.
+type RelatedProduct struct {
+	ID       pgtype.UUID `json:"id"`
+	Name     pgtype.Text `json:"name"`
+	Slug     pgtype.Text `json:"slug"`
+	Price    pgtype.Text `json:"price"`
+	Show     pgtype.Bool `json:"show"`
+	ImageUrl pgtype.Text `json:"imageUrl"`
+}
+
+// ProductRelations maps each relation kind, and boughtTogether, to the
+// products a product has of it, in order.
+type ProductRelations map[string][]RelatedProduct
+
+// RelatedProductIDs are the products, in order, a product has a relation of
+// one kind to.
+type RelatedProductIDs struct {
+	ProductIDs []pgtype.UUID `json:"productIds"`
+}
+
+// Bundle is what a product sold as one kit is made of. With "sum" Pricing
+// its price follows the prices of its items less Discount; with "fixed" it
+// keeps its own. A product with no Pricing is not a bundle.
+type Bundle struct {
+	Pricing  string       `json:"pricing"`
+	Discount pgtype.Text  `json:"discount"`
+	Items    []BundleItem `json:"items"`
+	// Available is how many whole bundles the stock of the items makes: 0
+	// while one of them is in the trash, or null when the count of one of
+	// them is not a number.
+	Available pgtype.Int8 `json:"available"`
+}
+
+type BundleItem struct {
+	ProductID pgtype.UUID `json:"productId"`
+	Name      pgtype.Text `json:"name"`
+	Price     pgtype.Text `json:"price"`
+	Count     pgtype.Text `json:"count"`
+	Quantity  int32       `json:"quantity"`
+}
+
+// StockMovement is what an invoice line of a bundle moved in or out of the
+// stock of one of its items: sells are negative and buys positive.
+type StockMovement struct {
+	ID            pgtype.UUID `json:"id"`
+	ProductID     pgtype.UUID `json:"productId"`
+	InvoiceItemID pgtype.UUID `json:"invoiceItemId"`
+	InvoiceID     pgtype.UUID `json:"invoiceId"`
+	InvoiceType   pgtype.Text `json:"invoiceType"`
+	Quantity      int32       `json:"quantity"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
+
+// PriceChange is a change of a product price, or of the price of one of its
+// variants when VariantID is set; Source names what made it: manual, bulk,
+// import, generator, schedule or bundle.
+type PriceChange struct {
+	ID        pgtype.UUID `json:"id"`
+	ProductID pgtype.UUID `json:"productId"`
+	VariantID pgtype.UUID `json:"variantId"`
+	OldPrice  pgtype.Text `json:"oldPrice"`
+	NewPrice  pgtype.Text `json:"newPrice"`
+	Source    string      `json:"source"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// ScheduledPrice sets the price of a product from StartsAt and restores the
+// previous one at EndsAt; without EndsAt the new price stays.
+type ScheduledPrice struct {
+	ID            pgtype.UUID `json:"id"`
+	ProductID     pgtype.UUID `json:"productId"`
+	Price         string      `json:"price"`
+	StartsAt      time.Time   `json:"startsAt"`
+	EndsAt        *time.Time  `json:"endsAt,omitempty"`
+	PreviousPrice pgtype.Text `json:"previousPrice"`
+	AppliedAt     *time.Time  `json:"appliedAt,omitempty"`
+	RevertedAt    *time.Time  `json:"revertedAt,omitempty"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
+
+// PublicImage is an image as the storefront shows it.
+type PublicImage struct {
+	Url      string                  `json:"url"`
+	Alt      pgtype.Text             `json:"alt"`
+	Variants map[string]ImageVariant `json:"variants"`
+}
+
+// PublicSpec is a parameter value of a product, named for shoppers.
+type PublicSpec struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PublicProduct is a product as the storefront shows it: no stock, code,
+// position or internal flags. Images and Specs are only filled in for a
+// single product.
+type PublicProduct struct {
+	ID           pgtype.UUID   `json:"id"`
+	Name         pgtype.Text   `json:"name"`
+	Slug         pgtype.Text   `json:"slug"`
+	Description  pgtype.Text   `json:"description"`
+	Info         pgtype.Text   `json:"info"`
+	Price        pgtype.Text   `json:"price"`
+	CategoryName pgtype.Text   `json:"categoryName"`
+	CategorySlug pgtype.Text   `json:"categorySlug"`
+	BrandName    pgtype.Text   `json:"brandName"`
+	Keywords     []pgtype.Text `json:"keywords"`
+	ImageUrl     pgtype.Text   `json:"imageUrl"`
+	// RatingAverage and RatingCount are of the approved reviews.
+	RatingAverage pgtype.Float8 `json:"ratingAverage"`
+	RatingCount   int32         `json:"ratingCount"`
+	Images        []PublicImage `json:"images,omitempty"`
+	Specs         []PublicSpec  `json:"specs,omitempty"`
+	// Axes and Variants are the variant matrix of a product that has
+	// variants; each variant names its value on every axis.
+	Axes     []PublicVariantAxis `json:"axes,omitempty"`
+	Variants []PublicVariant     `json:"variants,omitempty"`
+	// Related maps the relation kinds, and boughtTogether, to the visible
+	// products the product has of them.
+	Related map[string][]PublicProduct `json:"related,omitempty"`
+	// Questions are the answered questions about the product.
+	Questions []PublicQuestion `json:"questions,omitempty"`
+	// Contents are what a bundle is made of.
+	Contents []PublicBundleItem `json:"contents,omitempty"`
+	// Breadcrumbs are the categories from the top level down to the one of
+	// the product.
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+// PublicBundleItem is a product in a bundle; Available stands in for
+// whether its stock makes Quantity of it.
+type PublicBundleItem struct {
+	Product   PublicProduct `json:"product"`
+	Quantity  int32         `json:"quantity"`
+	Available bool          `json:"available"`
+}
+
+type PublicVariantAxis struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// PublicVariant is a variant as the storefront shows it; Available stands
+// in for its stock.
+type PublicVariant struct {
+	ID        pgtype.UUID       `json:"id"`
+	Price     pgtype.Text       `json:"price"`
+	Available bool              `json:"available"`
+	Values    map[string]string `json:"values"`
+	ImageUrl  pgtype.Text       `json:"imageUrl"`
+	Images    []PublicImage     `json:"images"`
+}
+
+type PublicProductPage struct {
+	Rows       []PublicProduct `json:"rows"`
+	TotalCount int32           `json:"totalCount"`
+}
+
+type PublicCategory struct {
+	ID          pgtype.UUID      `json:"id"`
+	Name        pgtype.Text      `json:"name"`
+	Slug        pgtype.Text      `json:"slug"`
+	Description pgtype.Text      `json:"description"`
+	ImageUrl    pgtype.Text      `json:"imageUrl"`
+	ParentID    pgtype.UUID      `json:"parentId"`
+	Children    []PublicCategory `json:"children,omitempty"`
+	// Breadcrumbs are only filled in for a single category.
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+type PublicArticle struct {
+	ID          pgtype.UUID   `json:"id"`
+	Name        pgtype.Text   `json:"name"`
+	Slug        pgtype.Text   `json:"slug"`
+	Description pgtype.Text   `json:"description"`
+	ImageUrl    pgtype.Text   `json:"imageUrl"`
+	Keywords    []pgtype.Text `json:"keywords"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
+
+// Comparison lines up the specs of products side by side: every row has a
+// value, or null, for each of Products in order, and Differs when they are
+// not all the same.
+type Comparison struct {
+	Products []PublicProduct   `json:"products"`
+	Groups   []ComparisonGroup `json:"groups"`
+}
+
+type ComparisonGroup struct {
+	Name string          `json:"name"`
+	Rows []ComparisonRow `json:"rows"`
+}
+
+type ComparisonRow struct {
+	ParameterID pgtype.UUID   `json:"parameterId"`
+	Name        string        `json:"name"`
+	Values      []pgtype.Text `json:"values"`
+	Differs     bool          `json:"differs"`
+}
+
+// Vehicle is a node of the vehicle catalog: a make, a model of a make, a
+// generation of a model, covering YearFrom to YearTo, or a trim of a
+// generation.
+type Vehicle struct {
+	ID        pgtype.UUID `json:"id"`
+	ParentID  pgtype.UUID `json:"parentId"`
+	Kind      string      `json:"kind"`
+	Name      string      `json:"name"`
+	Slug      pgtype.Text `json:"slug"`
+	YearFrom  pgtype.Int4 `json:"yearFrom"`
+	YearTo    pgtype.Int4 `json:"yearTo"`
+	Path      string      `json:"path,omitempty"`
+	Children  []Vehicle   `json:"children,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+// Fitment is the vehicles a product fits. Vehicles name each with its
+// path from the make.
+type Fitment struct {
+	VehicleIDs []pgtype.UUID `json:"vehicleIds"`
+	Vehicles   []Vehicle     `json:"vehicles"`
+}
+
+// BulkFitment adds VehicleIDs to, or with Remove takes them from, every
+// product in a category and its children.
+type BulkFitment struct {
+	CategoryID pgtype.UUID   `json:"categoryId"`
+	VehicleIDs []pgtype.UUID `json:"vehicleIds"`
+	Remove     bool          `json:"remove"`
+}
+
+// Review is the rating and review of a product by a customer, shown in the
+// storefront once an admin approves it. Reply is the answer of the shop.
+type Review struct {
+	ID          pgtype.UUID `json:"id"`
+	ProductID   pgtype.UUID `json:"productId"`
+	ProductName pgtype.Text `json:"productName"`
+	UserID      pgtype.UUID `json:"userId"`
+	Author      pgtype.Text `json:"author"`
+	Rating      int16       `json:"rating"`
+	Body        pgtype.Text `json:"body"`
+	Status      string      `json:"status"`
+	Reply       pgtype.Text `json:"reply"`
+	RepliedAt   *time.Time  `json:"repliedAt"`
+	ModeratedBy pgtype.UUID `json:"moderatedBy"`
+	ModeratedAt *time.Time  `json:"moderatedAt"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+type ReviewPage struct {
+	Rows       []Review `json:"rows"`
+	TotalCount int32    `json:"totalCount"`
+}
+
+// PublicReview is an approved review as the storefront shows it.
+type PublicReview struct {
+	ID        pgtype.UUID `json:"id"`
+	Author    pgtype.Text `json:"author"`
+	Rating    int16       `json:"rating"`
+	Body      pgtype.Text `json:"body"`
+	Reply     pgtype.Text `json:"reply"`
+	RepliedAt *time.Time  `json:"repliedAt"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+type PublicReviewPage struct {
+	Rows       []PublicReview `json:"rows"`
+	TotalCount int32          `json:"totalCount"`
+}
+
+// Question is a question of a customer about a product and the answer of
+// the shop, shown in the storefront once approved and answered.
+type Question struct {
+	ID          pgtype.UUID `json:"id"`
+	ProductID   pgtype.UUID `json:"productId"`
+	ProductName pgtype.Text `json:"productName"`
+	UserID      pgtype.UUID `json:"userId"`
+	Author      pgtype.Text `json:"author"`
+	Question    string      `json:"question"`
+	Answer      pgtype.Text `json:"answer"`
+	AnsweredBy  pgtype.UUID `json:"answeredBy"`
+	AnsweredAt  *time.Time  `json:"answeredAt"`
+	Status      string      `json:"status"`
+	ModeratedBy pgtype.UUID `json:"moderatedBy"`
+	ModeratedAt *time.Time  `json:"moderatedAt"`
+	NotifiedAt  *time.Time  `json:"notifiedAt"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+type QuestionPage struct {
+	Rows       []Question `json:"rows"`
+	TotalCount int32      `json:"totalCount"`
+}
+
+// PublicQuestion is an answered question as the storefront shows it.
+type PublicQuestion struct {
+	ID         pgtype.UUID `json:"id"`
+	Author     pgtype.Text `json:"author"`
+	Question   string      `json:"question"`
+	Answer     string      `json:"answer"`
+	AnsweredAt time.Time   `json:"answeredAt"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// VariantAxis is a selectable parameter a product varies by; Values are
+// the selectables of the parameter.
+type VariantAxis struct {
+	ParameterID pgtype.UUID   `json:"parameterId"`
+	Name        string        `json:"name"`
+	Values      []pgtype.Text `json:"values"`
+}
+
+// Variant is a version of a product with its own code, price, stock and
+// images. Values maps the parameter id of every axis of the product to the
+// selectable the variant has.
+type Variant struct {
+	ID        pgtype.UUID       `json:"id"`
+	ProductID pgtype.UUID       `json:"productId"`
+	Code      pgtype.Text       `json:"code"`
+	Price     pgtype.Text       `json:"price"`
+	Count     pgtype.Text       `json:"count"`
+	Show      pgtype.Bool       `json:"show"`
+	Position  int32             `json:"position"`
+	ImageID   pgtype.UUID       `json:"imageId"`
+	ImageIDs  []pgtype.UUID     `json:"imageIds"`
+	Values    map[string]string `json:"values"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// ProductVariants is the variant axes of a product and its variants.
+type ProductVariants struct {
+	Axes     []VariantAxis `json:"axes"`
+	Variants []Variant     `json:"variants"`
+}
+
+// VariantAxes are the parameters, in order, the variants of a product are
+// told apart by.
+type VariantAxes struct {
+	ParameterIDs []pgtype.UUID `json:"parameterIds"`
+}
+
 type Brand struct {
 	ID          pgtype.UUID `json:"id"`
 	Name        string      `json:"name"`
@@ -138,7 +542,25 @@ type Parameter struct {
 	Selectables      []pgtype.Text `json:"selectables"`
 	ParameterGroup   pgtype.Text   `json:"parameterGroup"`
 	Priority         pgtype.Text   `json:"priority"`
-	CreatedAt        time.Time     `json:"createdAt"`
+	// CategoryID is the category the parameter is defined in. For the
+	// parameters of a category, Inherited tells those defined above it and
+	// Overridden and Hidden those an override changes there.
+	CategoryID pgtype.UUID `json:"categoryId"`
+	Inherited  bool        `json:"inherited,omitempty"`
+	Overridden bool        `json:"overridden,omitempty"`
+	Hidden     bool        `json:"hidden,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// ParameterOverride changes an inherited parameter for a category and the
+// categories below it: Hidden leaves it out, and the other fields, when
+// set, replace those of the parameter.
+type ParameterOverride struct {
+	Hidden      bool          `json:"hidden"`
+	Name        pgtype.Text   `json:"name"`
+	Description pgtype.Text   `json:"description"`
+	Selectables []pgtype.Text `json:"selectables"`
+	Priority    pgtype.Text   `json:"priority"`
 }
 
 type ProductParameterValue struct {
@@ -152,13 +574,18 @@ type ProductParameterValue struct {
 }
 
 type User struct {
-	ID           pgtype.UUID `json:"id"`
-	Password     pgtype.Text `json:"password"`
-	Email        pgtype.Text `json:"email"`
-	Token        pgtype.Text `json:"token"`
-	TokenExpires *time.Time  `json:"tokenExpires"`
-	IsAdmin      bool        `json:"isAdmin"`
-	CreatedAt    time.Time   `json:"createdAt"`
+	ID                    pgtype.UUID `json:"id"`
+	Password              pgtype.Text `json:"password,omitzero"`
+	Email                 pgtype.Text `json:"email"`
+	PhoneNumber           pgtype.Text `json:"phoneNumber"`
+	Token                 pgtype.Text `json:"token,omitzero"`
+	TokenExpires          *time.Time  `json:"tokenExpires,omitempty"`
+	IsAdmin               bool        `json:"isAdmin"`
+	Disabled              bool        `json:"disabled"`
+	EmailVerified         bool        `json:"emailVerified"`
+	PasswordResetRequired bool        `json:"passwordResetRequired"`
+	SessionVersion        int32       `json:"-"`
+	CreatedAt             time.Time   `json:"createdAt"`
 }
 
 type InvoiceItem struct {
@@ -197,3 +624,74 @@ type Person struct {
 	CreatedAt   time.Time   `json:"createdAt"`
 	UpdatedAt   time.Time   `json:"updatedAt"`
 }
+
+type AuditLog struct {
+	ID           pgtype.UUID     `json:"id"`
+	UserID       pgtype.UUID     `json:"userId"`
+	UserEmail    pgtype.Text     `json:"userEmail"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resourceType"`
+	ResourceID   pgtype.UUID     `json:"resourceId"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	Diff         json.RawMessage `json:"diff"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+type TrashItem struct {
+	ID        pgtype.UUID     `json:"id"`
+	Name      pgtype.Text     `json:"name"`
+	DeletedAt time.Time       `json:"deletedAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type MediaGCReport struct {
+	DryRun       bool     `json:"dryRun"`
+	OrphanImages []string `json:"orphanImages"`
+	OrphanFiles  []string `json:"orphanFiles"`
+	FreedBytes   int64    `json:"freedBytes"`
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportReport tells what an import did, or would have done in a dry run.
+// Nothing is saved when any row has an error.
+type ImportReport struct {
+	DryRun    bool             `json:"dryRun"`
+	Committed bool             `json:"committed"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Errors    []ImportRowError `json:"errors"`
+	// Audit has an entry per saved product, with its snapshot from before,
+	// for the caller to record once it knows who imported.
+	Audit []AuditLog `json:"-"`
+}
+
+// BulkEdit applies one operation to the products with IDs or, without
+// IDs, to those matching filters as the product listing names them, out of
+// bulkEditFilterColumns. Price operations skip bundles priced by their
+// items.
+type BulkEdit struct {
+	IDs              []pgtype.UUID `json:"ids"`
+	Filters          []string      `json:"filter"`
+	FilterOperands   []string      `json:"filterOperand"`
+	FilterConditions []string      `json:"filterCondition"`
+	Operation        string        `json:"operation"`
+	Field            string        `json:"field"`
+	Value            string        `json:"value"`
+	Percent          bool          `json:"percent"`
+	Preview          bool          `json:"preview"`
+}
+
+type BulkEditResult struct {
+	Preview  bool      `json:"preview"`
+	Affected int       `json:"affected"`
+	Products []Product `json:"products"`
+	// Audit has an entry per changed product, with its snapshot from
+	// before, for the caller to record once it knows who edited.
+	Audit []AuditLog `json:"-"`
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
+)
+
+const (
+	otpLength      = 6
+	otpTTL         = 2 * time.Minute
+	otpResendDelay = time.Minute
+	otpMaxAttempts = 5
+)
+
+var (
+	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+	ErrOTPTooSoon         = errors.New("a code was sent recently, try again later")
+	ErrOTPInvalid         = errors.New("invalid or expired code")
+	ErrOTPTooManyAttempts = errors.New("too many attempts, request a new code")
+)
+
+// RequestOTP stores a new sign-in code for phoneNumber and texts it. The
+// code is committed before the SMS goes out, so the number stays locked
+// only as long as the database work takes.
+func (s *Service) RequestOTP(phoneNumber string) error {
+	ctx := context.Background()
+
+	phoneNumber, ok := utils.NormalizePhoneNumber(phoneNumber)
+	if !ok {
+		return ErrInvalidPhoneNumber
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Requests for the same number wait for each other, so only one of
+	// them gets past the resend delay.
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", phoneNumber)
+	if err != nil {
+		return err
+	}
+
+	var lastSentAt pgtype.Timestamptz
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    max(created_at)
+		FROM
+		    otp_codes
+		WHERE
+		    phone_number = $1`, phoneNumber).Scan(&lastSentAt)
+	if err != nil {
+		return err
+	}
+
+	if lastSentAt.Valid && time.Since(lastSentAt.Time) < otpResendDelay {
+		return ErrOTPTooSoon
+	}
+
+	code, err := utils.RandomDigits(otpLength)
+	if err != nil {
+		return err
+	}
+
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), 10)
+	if err != nil {
+		return err
+	}
+
+	// A new code supersedes any earlier one for the same number.
+	_, err = tx.Exec(ctx, `
+		UPDATE
+		    otp_codes
+		SET
+		    consumed_at = now()
+		WHERE
+		    phone_number = $1
+		    AND consumed_at IS NULL`, phoneNumber)
+	if err != nil {
+		return err
+	}
+
+	otpID := uuid.New()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO otp_codes (id, phone_number, code_hash, expires_at)
+		    VALUES ($1, $2, $3, $4)`,
+		otpID,
+		phoneNumber,
+		string(codeHash),
+		time.Now().Add(otpTTL),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.sms.Send(phoneNumber, "کد ورود شما به کار آپشن: "+code)
+	if err != nil {
+		// The code never arrived, so it must not hold back a retry.
+		_, deleteErr := s.db.Exec(ctx, "DELETE FROM otp_codes WHERE id = $1", otpID)
+		if deleteErr != nil {
+			log.Printf("otp code %s: %v", otpID, deleteErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// VerifyOTP checks the code sent to phoneNumber and returns the same JWT as
+// SignIn. A user is created for the number on first sign-in and linked to the
+// person with that phone number, if any.
+func (s *Service) VerifyOTP(phoneNumber string, code string) (string, error) {
+	ctx := context.Background()
+
+	phoneNumber, ok := utils.NormalizePhoneNumber(phoneNumber)
+	if !ok {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	code = utils.ReplacePersianDigits(code)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		otpID     pgtype.UUID
+		codeHash  string
+		attempts  int
+		expiresAt time.Time
+	)
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    id,
+		    code_hash,
+		    attempts,
+		    expires_at
+		FROM
+		    otp_codes
+		WHERE
+		    phone_number = $1
+		    AND consumed_at IS NULL
+		ORDER BY
+		    created_at DESC
+		LIMIT 1
+		FOR UPDATE`, phoneNumber).Scan(&otpID, &codeHash, &attempts, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrOTPInvalid
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().After(expiresAt) {
+		return "", ErrOTPInvalid
+	}
+
+	if attempts >= otpMaxAttempts {
+		return "", ErrOTPTooManyAttempts
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(codeHash), []byte(code))
+	if err != nil {
+		_, err = tx.Exec(ctx, "UPDATE otp_codes SET attempts = attempts + 1 WHERE id = $1", otpID)
+		if err != nil {
+			return "", err
+		}
+
+		err = tx.Commit(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		return "", ErrOTPInvalid
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE otp_codes SET consumed_at = now() WHERE id = $1", otpID)
+	if err != nil {
+		return "", err
+	}
+
+	var user User
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (id, phone_number, person_id)
+		    VALUES ($1, $2, (
+		            SELECT
+		                id
+		            FROM
+		                persons
+		            WHERE
+		                phone_number = $2
+		                AND deleted_at IS NULL))
+		ON CONFLICT (phone_number)
+		    DO UPDATE SET
+		        person_id = COALESCE(users.person_id, EXCLUDED.person_id)
+		    RETURNING
+		        id,
+		        email,
+		        phone_number,
+		        is_admin,
+		        COALESCE(disabled, FALSE),
+		        session_version`,
+		uuid.New(),
+		phoneNumber,
+	).Scan(&user.ID, &user.Email, &user.PhoneNumber, &user.IsAdmin, &user.Disabled, &user.SessionVersion)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Disabled {
+		return "", ErrUserDisabled
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return s.signToken(user)
+}
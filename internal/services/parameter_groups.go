@@ -192,13 +192,13 @@ func (s *Service) GetParameterGroup(id string) (ParameterGroup, error) {
 	return parameterGroup, nil
 }
 
-func (s *Service) CreateParameterGroup(parameterGroup ParameterGroup) error {
+func (s *Service) CreateParameterGroup(parameterGroup ParameterGroup) (uuid.UUID, error) {
 	query := "INSERT INTO parameter_groups (id,name,category_id) VALUES ($1,$2,$3);"
 	validate := utils.NewValidate()
 
 	err := validate.Struct(parameterGroup)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	id := uuid.New()
@@ -211,33 +211,10 @@ func (s *Service) CreateParameterGroup(parameterGroup ParameterGroup) error {
 		parameterGroup.CategoryId,
 	)
 	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (s *Service) EditParameterGroup(id string, parameterGroup ParameterGroup) error {
-	query := "UPDATE parameter_groups SET name=$1,category_id=$2 WHERE id=$3;"
-	validate := utils.NewValidate()
-
-	err := validate.Struct(parameterGroup)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.db.Exec(
-		context.Background(),
-		query,
-		parameterGroup.Name,
-		parameterGroup.CategoryId,
-		id,
-	)
-	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
+	return id, nil
 }
 
 func (s *Service) DeleteParameterGroup(id string) error {
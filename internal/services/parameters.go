@@ -3,12 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
 )
@@ -173,50 +175,163 @@ ON pgs.id = parameters.parameter_group_id
 	}
 }
 
-func (s *Service) ListParametersByCategory(category_id string) ([]Parameter, error) {
+var ErrNotInherited = errors.New("only parameters inherited from a category above can be overridden")
+
+// categoryParameters returns the parameter schema of the category with
+// categoryID, leaving out the hidden parameters unless withHidden.
+func (s *Service) categoryParameters(categoryID string, withHidden bool) ([]Parameter, error) {
+	parsedUUID, err := uuid.Parse(categoryID)
+	if err != nil {
+		return []Parameter{}, err
+	}
+
 	query := `
 		SELECT
-		    p.id,
-		    p.name,
-		    p.description,
-		    p.type,
-		    p.parameter_group_id,
-		    p.selectables,
-		    p.priority,
-		    p.created_at
+		    cp.id,
+		    cp.name,
+		    cp.description,
+		    cp.type,
+		    cp.parameter_group_id,
+		    cp.parameter_group,
+		    cp.selectables,
+		    cp.priority,
+		    cp.category_id,
+		    cp.inherited,
+		    cp.overridden,
+		    cp.hidden,
+		    cp.created_at
 		FROM
-		    parameters AS p
-		    JOIN parameter_groups AS pg ON pg.id = p.parameter_group_id
-		    JOIN categories AS c ON c.id = pg.category_id
+		    category_parameters ($1) cp
 		WHERE
-		    c.id IN (
-		        SELECT
-		            cc.parent_id
-		        FROM
-		            categories AS cc
-		        WHERE
-		            cc.id = $1)
+		    $2
+		    OR NOT cp.hidden
 		ORDER BY
-		    p.priority`
+		    cp.priority::INT,
+		    cp.name`
 
-	rows, err := s.db.Query(context.Background(), query, category_id)
+	rows, err := s.db.Query(context.Background(), query, parsedUUID, withHidden)
 	if err != nil {
 		return []Parameter{}, err
 	}
 	defer rows.Close()
 
-	var parameters []Parameter
+	parameters := []Parameter{}
 
 	for rows.Next() {
 		var parameter Parameter
-		if err := rows.Scan(&parameter.ID, &parameter.Name, &parameter.Description, &parameter.Type, &parameter.ParameterGroupId, &parameter.Selectables, &parameter.Priority, &parameter.CreatedAt); err != nil {
+		if err := rows.Scan(&parameter.ID, &parameter.Name, &parameter.Description, &parameter.Type, &parameter.ParameterGroupId, &parameter.ParameterGroup, &parameter.Selectables, &parameter.Priority, &parameter.CategoryID, &parameter.Inherited, &parameter.Overridden, &parameter.Hidden, &parameter.CreatedAt); err != nil {
 			return []Parameter{}, err
 		}
 
 		parameters = append(parameters, parameter)
 	}
 
-	return parameters, nil
+	return parameters, rows.Err()
+}
+
+// ListParametersByCategory returns the parameters products of a category
+// have: those of its groups and those it inherits from the categories
+// above it, as overridden there.
+func (s *Service) ListParametersByCategory(category_id string) ([]Parameter, error) {
+	return s.categoryParameters(category_id, false)
+}
+
+// ListCategoryParameterSchema is ListParametersByCategory with the hidden
+// parameters too, for editing the overrides of a category.
+func (s *Service) ListCategoryParameterSchema(categoryID string) ([]Parameter, error) {
+	return s.categoryParameters(categoryID, true)
+}
+
+// SetParameterOverride saves how the category with categoryID and those
+// below it change a parameter they inherit.
+func (s *Service) SetParameterOverride(categoryID string, parameterID string, override ParameterOverride) error {
+	parsedCategoryID, err := uuid.Parse(categoryID)
+	if err != nil {
+		return err
+	}
+
+	parsedParameterID, err := uuid.Parse(parameterID)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+
+	err = s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)", parsedCategoryID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return pgx.ErrNoRows
+	}
+
+	var inherited bool
+
+	err = s.db.QueryRow(ctx, `
+		SELECT
+		    EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            category_parameters ($1) cp
+		        WHERE
+		            cp.id = $2
+		            AND cp.inherited)`, parsedCategoryID, parsedParameterID).Scan(&inherited)
+	if err != nil {
+		return err
+	}
+
+	if !inherited {
+		return ErrNotInherited
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO category_parameter_overrides (category_id, parameter_id, hidden, name, description, selectables, priority)
+		    VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (category_id, parameter_id)
+		    DO UPDATE SET
+		        hidden = EXCLUDED.hidden,
+		        name = EXCLUDED.name,
+		        description = EXCLUDED.description,
+		        selectables = EXCLUDED.selectables,
+		        priority = EXCLUDED.priority,
+		        updated_at = now()`,
+		parsedCategoryID, parsedParameterID, override.Hidden, override.Name, override.Description, override.Selectables, override.Priority,
+	)
+
+	return err
+}
+
+// DeleteParameterOverride makes the category with categoryID inherit a
+// parameter as the categories above it have it again.
+func (s *Service) DeleteParameterOverride(categoryID string, parameterID string) error {
+	parsedCategoryID, err := uuid.Parse(categoryID)
+	if err != nil {
+		return err
+	}
+
+	parsedParameterID, err := uuid.Parse(parameterID)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(
+		context.Background(),
+		"DELETE FROM category_parameter_overrides WHERE category_id = $1 AND parameter_id = $2",
+		parsedCategoryID, parsedParameterID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
 }
 
 func (s *Service) GetParameter(id string) (Parameter, error) {
@@ -263,7 +378,7 @@ WHERE
 	return parameter, nil
 }
 
-func (s *Service) CreateParameter(parameter Parameter) error {
+func (s *Service) CreateParameter(parameter Parameter) (uuid.UUID, error) {
 	query := `
 		INSERT INTO parameters (id, name, description, type, parameter_group_id, selectables, priority)
 		    VALUES ($1, $2, $3, $4, $5, $6, $7)`
@@ -271,7 +386,7 @@ func (s *Service) CreateParameter(parameter Parameter) error {
 
 	err := validate.Struct(parameter)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	id := uuid.New()
@@ -288,48 +403,10 @@ func (s *Service) CreateParameter(parameter Parameter) error {
 		parameter.Priority,
 	)
 	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (s *Service) EditParameter(id string, parameter Parameter) error {
-	query := `
-		UPDATE
-		    parameters
-		SET
-		    name = $1,
-		    description = $2,
-		    type = $3,
-		    parameter_group_id = $4,
-		    selectables = $5,
-		    priority = $6
-		WHERE
-		    id = $7`
-	validate := utils.NewValidate()
-
-	err := validate.Struct(parameter)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.db.Exec(
-		context.Background(),
-		query,
-		parameter.Name,
-		parameter.Description,
-		parameter.Type,
-		parameter.ParameterGroupId,
-		parameter.Selectables,
-		parameter.Priority,
-		id,
-	)
-	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
+	return id, nil
 }
 
 func (s *Service) DeleteParameter(id string) error {
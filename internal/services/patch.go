@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrUnknownPatch       = errors.New("resource cannot be patched")
+	ErrPreconditionFailed = errors.New("resource was changed since it was read")
+)
+
+// patchColumn is the column a patch field is stored in; value returns a
+// pointer to decode the field into. A null field decodes to SQL NULL.
+type patchColumn struct {
+	name  string
+	value func() any
+}
+
+func column[T any](name string) patchColumn {
+	return patchColumn{name: name, value: func() any { return new(T) }}
+}
+
+type patchResource struct {
+	table   string
+	columns map[string]patchColumn
+	// related saves the fields kept outside the table.
+	related func(ctx context.Context, tx pgx.Tx, id uuid.UUID, patch map[string]json.RawMessage) error
+}
+
+// patchResources maps the resources editable by merge patch to the JSON
+// fields they accept. Other fields are read only and ignored.
+var patchResources = map[string]patchResource{
+	"products": {
+		table: "products",
+		columns: map[string]patchColumn{
+			"name":        column[pgtype.Text]("name"),
+			"description": column[pgtype.Text]("description"),
+			"info":        column[pgtype.Text]("info"),
+			"price":       column[pgtype.Text]("price"),
+			"count":       column[pgtype.Text]("count"),
+			"categoryId":  column[pgtype.UUID]("category_id"),
+			"brandId":     column[pgtype.UUID]("brand_id"),
+			"imageId":     column[pgtype.UUID]("image_id"),
+			"slug":        column[pgtype.Text]("slug"),
+			"keywords":    column[[]pgtype.Text]("keywords"),
+			"generatable": column[pgtype.Bool]("generatable"),
+			"show":        column[pgtype.Bool]("show"),
+			"position":    column[pgtype.Text]("position"),
+			"code":        column[pgtype.Text]("code"),
+		},
+		related: patchProductRelated,
+	},
+	"categories": {
+		table: "categories",
+		columns: map[string]patchColumn{
+			"name":        column[pgtype.Text]("name"),
+			"description": column[pgtype.Text]("description"),
+			"parentId":    column[pgtype.UUID]("parent_id"),
+			"imageId":     column[pgtype.UUID]("image_id"),
+			"priority":    column[pgtype.Int4]("priority"),
+			"slug":        column[pgtype.Text]("slug"),
+			"show":        column[pgtype.Bool]("show"),
+		},
+	},
+	"entities": {
+		table: "entities",
+		columns: map[string]patchColumn{
+			"name":       column[pgtype.Text]("name"),
+			"parentId":   column[pgtype.UUID]("parent_id"),
+			"imageId":    column[pgtype.UUID]("image_id"),
+			"priority":   column[pgtype.Text]("priority"),
+			"entitySlug": column[pgtype.Text]("entity_slug"),
+			"show":       column[pgtype.Bool]("show"),
+			"keywords":   column[[]pgtype.Text]("keywords"),
+		},
+	},
+	"brands": {
+		table: "brands",
+		columns: map[string]patchColumn{
+			"name":        column[pgtype.Text]("name"),
+			"description": column[pgtype.Text]("description"),
+		},
+	},
+	"articles": {
+		table: "articles",
+		columns: map[string]patchColumn{
+			"name":           column[pgtype.Text]("name"),
+			"description":    column[pgtype.Text]("description"),
+			"imageId":        column[pgtype.UUID]("image_id"),
+			"slug":           column[pgtype.Text]("slug"),
+			"keywords":       column[[]pgtype.Text]("keywords"),
+			"categoryId":     column[pgtype.UUID]("category_id"),
+			"showInProducts": column[pgtype.Bool]("show_in_products"),
+		},
+	},
+	// The file of an image is not patched: a new one is uploaded as a new
+	// image, which gets its variants then.
+	"images": {
+		table: "images",
+		columns: map[string]patchColumn{
+			"name":       column[pgtype.Text]("name"),
+			"alt":        column[pgtype.Text]("alt"),
+			"position":   column[pgtype.Int4]("position"),
+			"categoryId": column[pgtype.UUID]("category_id"),
+			"productId":  column[pgtype.UUID]("product_id"),
+			"EntityId":   column[pgtype.UUID]("entity_id"),
+			"articleId":  column[pgtype.UUID]("article_id"),
+		},
+	},
+	"parameter_groups": {
+		table: "parameter_groups",
+		columns: map[string]patchColumn{
+			"name":       column[pgtype.Text]("name"),
+			"categoryId": column[pgtype.UUID]("category_id"),
+		},
+	},
+	"parameters": {
+		table: "parameters",
+		columns: map[string]patchColumn{
+			"name":             column[pgtype.Text]("name"),
+			"description":      column[pgtype.Text]("description"),
+			"type":             column[pgtype.Text]("type"),
+			"parameterGroupId": column[pgtype.UUID]("parameter_group_id"),
+			"selectables":      column[[]pgtype.Text]("selectables"),
+			"priority":         column[pgtype.Text]("priority"),
+		},
+	},
+	"invoices": {
+		table: "invoices",
+		columns: map[string]patchColumn{
+			"personId": column[pgtype.UUID]("person_id"),
+			"type":     column[pgtype.Text]("type"),
+			"discount": column[pgtype.Text]("discount"),
+			"notes":    column[pgtype.Text]("notes"),
+			"date":     column[pgtype.Timestamptz]("date"),
+		},
+		related: patchInvoiceRelated,
+	},
+	"persons": {
+		table: "persons",
+		columns: map[string]patchColumn{
+			"firstName":   column[pgtype.Text]("first_name"),
+			"name":        column[pgtype.Text]("name"),
+			"address":     column[pgtype.Text]("address"),
+			"phoneNumber": column[pgtype.Text]("phone_number"),
+		},
+	},
+	"product_variants": {
+		table: "product_variants",
+		columns: map[string]patchColumn{
+			"code":     column[pgtype.Text]("code"),
+			"price":    column[pgtype.Text]("price"),
+			"count":    column[pgtype.Text]("count"),
+			"show":     column[pgtype.Bool]("show"),
+			"position": column[pgtype.Int4]("position"),
+			"imageId":  column[pgtype.UUID]("image_id"),
+		},
+		related: patchVariantRelated,
+	},
+	"vehicles": {
+		table: "vehicles",
+		columns: map[string]patchColumn{
+			"parentId": column[pgtype.UUID]("parent_id"),
+			"name":     column[pgtype.Text]("name"),
+			"slug":     column[pgtype.Text]("slug"),
+			"yearFrom": column[pgtype.Int4]("year_from"),
+			"yearTo":   column[pgtype.Int4]("year_to"),
+		},
+	},
+}
+
+// patchCondition matches the resourceType row with the id in $1, skipping
+// trashed rows of the resources that have a trash.
+func patchCondition(resourceType string) string {
+	if _, ok := trashResources[resourceType]; ok {
+		return "id = $1 AND deleted_at IS NULL"
+	}
+
+	return "id = $1"
+}
+
+// patchProductRelated replaces the gallery when imageIds is given and
+// upserts the given parameter values; values left out are kept.
+func patchProductRelated(ctx context.Context, tx pgx.Tx, id uuid.UUID, patch map[string]json.RawMessage) error {
+	if raw, ok := patch["imageIds"]; ok {
+		var imageIDs []pgtype.UUID
+		if err := json.Unmarshal(raw, &imageIDs); err != nil {
+			return fmt.Errorf("imageIds: %w", err)
+		}
+
+		if err := setGallery(ctx, tx, "products", id, imageIDs); err != nil {
+			return err
+		}
+	}
+
+	raw, ok := patch["productParameterValues"]
+	if !ok {
+		return nil
+	}
+
+	var values []ProductParameterValue
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return fmt.Errorf("productParameterValues: %w", err)
+	}
+
+	return saveProductParameterValues(ctx, tx, id, values)
+}
+
+// ResourceVersion returns the updated_at of the resourceType row id, which
+// its ETag is made from.
+func (s *Service) ResourceVersion(resourceType string, id string) (time.Time, error) {
+	var version time.Time
+
+	resource, ok := patchResources[resourceType]
+	if !ok {
+		return version, ErrUnknownPatch
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return version, err
+	}
+
+	err = s.db.QueryRow(
+		context.Background(),
+		fmt.Sprintf("SELECT updated_at FROM %s WHERE %s", resource.table, patchCondition(resourceType)),
+		parsedUUID,
+	).Scan(&version)
+
+	return version, err
+}
+
+// PatchResource applies patch to the resourceType row id as a JSON merge
+// patch: only the fields present change and null clears a field. When
+// versions are given the row must still have one of them as updated_at,
+// otherwise nothing changes and ErrPreconditionFailed is returned. It
+// returns the new updated_at.
+func (s *Service) PatchResource(
+	resourceType string,
+	id string,
+	patch map[string]json.RawMessage,
+	versions []time.Time,
+) (time.Time, error) {
+	var updatedAt time.Time
+
+	resource, ok := patchResources[resourceType]
+	if !ok {
+		return updatedAt, ErrUnknownPatch
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return updatedAt, err
+	}
+
+	var (
+		sets = []string{"updated_at = now()"}
+		args = []any{parsedUUID}
+	)
+
+	for field, raw := range patch {
+		column, ok := resource.columns[field]
+		if !ok {
+			continue
+		}
+
+		value := column.value()
+		if err := json.Unmarshal(raw, value); err != nil {
+			return updatedAt, fmt.Errorf("%s: %w", field, err)
+		}
+
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column.name, len(args)))
+	}
+
+	condition := patchCondition(resourceType)
+
+	if len(versions) > 0 {
+		args = append(args, versions)
+		condition += fmt.Sprintf(" AND updated_at = ANY($%d)", len(args))
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return updatedAt, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING updated_at", resource.table, strings.Join(sets, ", "), condition),
+		args...,
+	).Scan(&updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) && len(versions) > 0 {
+		// Tell a row changed by someone else from one that is gone.
+		if _, err := s.ResourceVersion(resourceType, id); err != nil {
+			return updatedAt, err
+		}
+
+		return updatedAt, ErrPreconditionFailed
+	}
+
+	if err != nil {
+		return updatedAt, err
+	}
+
+	if resource.related != nil {
+		err = resource.related(ctx, tx, parsedUUID, patch)
+		if err != nil {
+			return updatedAt, err
+		}
+	}
+
+	return updatedAt, tx.Commit(ctx)
+}
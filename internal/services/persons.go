@@ -11,16 +11,15 @@ import (
 	"github.com/google/uuid"
 )
 
-func (s *Service) ListPersonsWithSortFilterPagination(
+// personListClauses builds the WHERE and ORDER BY clauses of the person
+// listing and export from their query parameters.
+func personListClauses(
 	sort string,
 	sortDirection string,
 	filters []string,
 	filterOperands []string,
 	filterConditions []string,
-	countInPage string,
-	offset string,
-	w http.ResponseWriter,
-) {
+) (string, string) {
 	orderBy := ""
 
 	if sort != "" {
@@ -40,7 +39,9 @@ func (s *Service) ListPersonsWithSortFilterPagination(
 
 	var filterBy strings.Builder
 	if len(filters) > 0 {
-		filterBy.WriteString("WHERE ")
+		filterBy.WriteString("WHERE persons.deleted_at IS NULL AND ")
+	} else {
+		filterBy.WriteString("WHERE persons.deleted_at IS NULL ")
 	}
 
 	for index, filter := range filters {
@@ -75,6 +76,21 @@ func (s *Service) ListPersonsWithSortFilterPagination(
 		}
 	}
 
+	return filterBy.String(), orderBy
+}
+
+func (s *Service) ListPersonsWithSortFilterPagination(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	countInPage string,
+	offset string,
+	w http.ResponseWriter,
+) {
+	filterBy, orderBy := personListClauses(sort, sortDirection, filters, filterOperands, filterConditions)
+
 	pagedBy := ""
 	offsetNum := 0
 
@@ -110,7 +126,7 @@ func (s *Service) ListPersonsWithSortFilterPagination(
 		FROM
 		    persons
 		%s %s %s
-		`, filterBy.String(), orderBy, pagedBy)
+		`, filterBy, orderBy, pagedBy)
 
 	rows, err := s.db.Query(context.Background(), query)
 	if err != nil {
@@ -139,7 +155,7 @@ func (s *Service) ListPersonsWithSortFilterPagination(
 		SELECT COUNT(*) FROM
 		    persons
 		%s
-		`, filterBy.String())
+		`, filterBy)
 	row := s.db.QueryRow(context.Background(), newQuery)
 
 	var Count int32
@@ -175,7 +191,7 @@ func (s *Service) GetPerson(id string) (Person, error) {
 		return person, err
 	}
 
-	query := "SELECT * FROM persons WHERE id=$1"
+	query := "SELECT id,first_name,name,address,phone_number,created_at,updated_at FROM persons WHERE id=$1 AND deleted_at IS NULL"
 	row := s.db.QueryRow(context.Background(), query, parsedUUID)
 
 	err = row.Scan(
@@ -194,7 +210,7 @@ func (s *Service) GetPerson(id string) (Person, error) {
 	return person, nil
 }
 
-func (s *Service) CreatePerson(person Person) error {
+func (s *Service) CreatePerson(person Person) (uuid.UUID, error) {
 	query := `
 		INSERT INTO persons (id, first_name, name, address, phone_number)
 		    VALUES ($1, $2, $3, $4, $5)`
@@ -211,38 +227,12 @@ func (s *Service) CreatePerson(person Person) error {
 		person.PhoneNumber,
 	)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	return nil
-}
-
-func (s *Service) EditPerson(id string, person Person) error {
-	query := "UPDATE persons SET first_name=$1,name=$2,address=$3,phone_number=$4 WHERE id=$5;"
-
-	_, err := s.db.Exec(
-		context.Background(),
-		query,
-		person.FirstName,
-		person.Name,
-		person.Address,
-		person.PhoneNumber,
-		id,
-	)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return id, nil
 }
 
 func (s *Service) DeletePerson(id string) error {
-	query := "DELETE FROM persons WHERE id=$1"
-
-	_, err := s.db.Exec(context.Background(), query, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.softDelete("persons", id)
 }
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidSchedule = errors.New("invalid scheduled price")
+	ErrScheduleOverlap = errors.New("another scheduled price overlaps this period")
+)
+
+// setPriceSource names what changes prices in tx for the price history,
+// which a trigger records on every change; the default is manual.
+func setPriceSource(ctx context.Context, tx pgx.Tx, source string) error {
+	_, err := tx.Exec(ctx, "SELECT set_config('caroption.price_source', $1, TRUE)", source)
+
+	return err
+}
+
+// ListPriceHistory returns the price changes of a product, latest first.
+func (s *Service) ListPriceHistory(productID string) ([]PriceChange, error) {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(context.Background(), `
+		SELECT
+		    id,
+		    product_id,
+		    variant_id,
+		    old_price,
+		    new_price,
+		    source,
+		    created_at
+		FROM
+		    product_price_history
+		WHERE
+		    product_id = $1
+		ORDER BY
+		    created_at DESC`, parsedUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []PriceChange{}
+
+	for rows.Next() {
+		var change PriceChange
+		if err := rows.Scan(&change.ID, &change.ProductID, &change.VariantID, &change.OldPrice, &change.NewPrice, &change.Source, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// ListScheduledPrices returns the scheduled prices of a product in the
+// order they start.
+func (s *Service) ListScheduledPrices(productID string) ([]ScheduledPrice, error) {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(context.Background(), `
+		SELECT
+		    id,
+		    product_id,
+		    price,
+		    starts_at,
+		    ends_at,
+		    previous_price,
+		    applied_at,
+		    reverted_at,
+		    created_at
+		FROM
+		    scheduled_prices
+		WHERE
+		    product_id = $1
+		ORDER BY
+		    starts_at`, parsedUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []ScheduledPrice{}
+
+	for rows.Next() {
+		var schedule ScheduledPrice
+		if err := rows.Scan(
+			&schedule.ID,
+			&schedule.ProductID,
+			&schedule.Price,
+			&schedule.StartsAt,
+			&schedule.EndsAt,
+			&schedule.PreviousPrice,
+			&schedule.AppliedAt,
+			&schedule.RevertedAt,
+			&schedule.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// CreateScheduledPrice plans schedule for a product. Periods of a product
+// may not overlap; a schedule already applied without an end no longer
+// counts, as its price has become the regular one.
+func (s *Service) CreateScheduledPrice(productID string, schedule ScheduledPrice) (uuid.UUID, error) {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	schedule.Price = normalizeNumber(schedule.Price)
+	if _, err := strconv.ParseUint(schedule.Price, 10, 64); err != nil {
+		return uuid.Nil, fmt.Errorf("%w: price must be a non-negative whole number", ErrInvalidSchedule)
+	}
+
+	if schedule.StartsAt.IsZero() {
+		return uuid.Nil, fmt.Errorf("%w: startsAt is required", ErrInvalidSchedule)
+	}
+
+	if schedule.EndsAt != nil && !schedule.EndsAt.After(schedule.StartsAt) {
+		return uuid.Nil, fmt.Errorf("%w: endsAt must be after startsAt", ErrInvalidSchedule)
+	}
+
+	if schedule.EndsAt != nil && schedule.EndsAt.Before(time.Now()) {
+		return uuid.Nil, fmt.Errorf("%w: endsAt has passed", ErrInvalidSchedule)
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the product keeps concurrent schedules from both passing the
+	// overlap check.
+	var sumBundle bool
+
+	err = tx.QueryRow(ctx, "SELECT bundle_pricing IS NOT DISTINCT FROM 'sum' FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", parsedUUID).Scan(&sumBundle)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if sumBundle {
+		return uuid.Nil, fmt.Errorf("%w: the price of a sum bundle is derived from its items", ErrInvalidSchedule)
+	}
+
+	var overlaps bool
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            scheduled_prices
+		        WHERE
+		            product_id = $1
+		            AND reverted_at IS NULL
+		            AND NOT (applied_at IS NOT NULL
+		                AND ends_at IS NULL)
+		            AND tstzrange(starts_at, ends_at) && tstzrange($2::timestamptz, $3::timestamptz))`,
+		parsedUUID, schedule.StartsAt, schedule.EndsAt,
+	).Scan(&overlaps)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if overlaps {
+		return uuid.Nil, ErrScheduleOverlap
+	}
+
+	id := uuid.New()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO scheduled_prices (id, product_id, price, starts_at, ends_at)
+		    VALUES ($1, $2, $3, $4, $5)`,
+		id, parsedUUID, schedule.Price, schedule.StartsAt, schedule.EndsAt,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+// revertScheduledPrices ends the applied schedules that match condition
+// and restores the prices they replaced. A price changed since the
+// schedule applied, or of a product that became a sum bundle, is left
+// alone.
+func revertScheduledPrices(ctx context.Context, tx pgx.Tx, condition string, args ...any) (int64, error) {
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		WITH due AS (
+		    UPDATE
+		        scheduled_prices
+		    SET
+		        reverted_at = now()
+		    WHERE
+		        applied_at IS NOT NULL
+		        AND reverted_at IS NULL
+		        AND %s
+		    RETURNING
+		        product_id,
+		        price,
+		        previous_price)
+		UPDATE
+		    products
+		SET
+		    price = due.previous_price,
+		    updated_at = now()
+		FROM
+		    due
+		WHERE
+		    products.id = due.product_id
+		    AND products.price IS NOT DISTINCT FROM due.price
+		    AND products.bundle_pricing IS DISTINCT FROM 'sum'`, condition), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteScheduledPrice removes a scheduled price of a product. Deleting one
+// in effect restores the price it replaced right away.
+func (s *Service) DeleteScheduledPrice(productID string, id string) error {
+	parsedProductID, err := uuid.Parse(productID)
+	if err != nil {
+		return err
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = setPriceSource(ctx, tx, "schedule")
+	if err != nil {
+		return err
+	}
+
+	_, err = revertScheduledPrices(ctx, tx, "id = $1 AND product_id = $2", parsedUUID, parsedProductID)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM scheduled_prices WHERE id = $1 AND product_id = $2", parsedUUID, parsedProductID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ApplyScheduledPrices reverts the schedules that ended, then applies the
+// ones that started, and reports how many prices each changed. Ending
+// first lets a schedule start where the previous one ends. Schedules of
+// sum bundles stay unapplied, as their price cannot be written.
+func (s *Service) ApplyScheduledPrices() (int64, int64, error) {
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = setPriceSource(ctx, tx, "schedule")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reverted, err := revertScheduledPrices(ctx, tx, "ends_at IS NOT NULL AND ends_at <= now()")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Schedules whose whole period passed unapplied, as while the server
+	// was down, are skipped.
+	tag, err := tx.Exec(ctx, `
+		WITH due AS (
+		    UPDATE
+		        scheduled_prices
+		    SET
+		        applied_at = now(),
+		        previous_price = products.price
+		    FROM
+		        products
+		    WHERE
+		        products.id = scheduled_prices.product_id
+		        AND products.deleted_at IS NULL
+		        AND products.bundle_pricing IS DISTINCT FROM 'sum'
+		        AND scheduled_prices.applied_at IS NULL
+		        AND scheduled_prices.starts_at <= now()
+		        AND (scheduled_prices.ends_at IS NULL
+		            OR scheduled_prices.ends_at > now())
+		    RETURNING
+		        scheduled_prices.product_id,
+		        scheduled_prices.price)
+		UPDATE
+		    products
+		SET
+		    price = due.price,
+		    updated_at = now()
+		FROM
+		    due
+		WHERE
+		    products.id = due.product_id`)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return tag.RowsAffected(), reverted, tx.Commit(ctx)
+}
+
+// RunPriceSchedules applies scheduled prices every interval and logs what
+// it changed.
+func (s *Service) RunPriceSchedules(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		applied, reverted, err := s.ApplyScheduledPrices()
+		if err != nil {
+			log.Printf("price schedules: %v", err)
+
+			continue
+		}
+
+		if applied > 0 || reverted > 0 {
+			log.Printf("price schedules: applied %d and reverted %d prices", applied, reverted)
+		}
+	}
+}
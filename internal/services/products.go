@@ -5,29 +5,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/utils"
 )
 
-func (s *Service) DeleteGeneratedProducts() ([]Product, error) {
-	deleteGeneratedProductsQuery := `
-		DELETE FROM products
-		WHERE GENERATED = TRUE;
-		
-		`
+// DeleteGeneratedProducts moves the generated products to the trash and
+// returns an audit entry for each, for the caller to record.
+func (s *Service) DeleteGeneratedProducts() ([]AuditLog, error) {
+	ctx := context.Background()
 
-	_, err := s.db.Exec(context.Background(), deleteGeneratedProductsQuery)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "SELECT id FROM products WHERE generated = TRUE AND deleted_at IS NULL FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[pgtype.UUID])
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AuditLog, 0, len(ids))
+
+	for _, id := range ids {
+		entry := AuditLog{Action: "delete", ResourceType: "products", ResourceID: id}
+
+		entry.Before, err = auditSnapshot(ctx, tx, auditSnapshotQueries["products"], id)
+		if err != nil {
+			return nil, err
+		}
+
+		err = trashRow(ctx, tx, trashResources["products"], id)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
 
-	return []Product{}, nil
+	return entries, tx.Commit(ctx)
 }
 
 func uniqueStrings(input []pgtype.Text) []pgtype.Text {
@@ -43,7 +72,10 @@ func uniqueStrings(input []pgtype.Text) []pgtype.Text {
 	return result
 }
 
-func (s *Service) GenerateProducts() ([]Product, error) {
+// GenerateProducts creates or updates a product for every generatable
+// product and generator entity, and returns an audit entry for each, for
+// the caller to record.
+func (s *Service) GenerateProducts() ([]AuditLog, error) {
 	ctx := context.Background()
 
 	// ------------------------------
@@ -68,7 +100,8 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 		    products AS p
 		WHERE
 		    p.generatable = TRUE
-		    AND p.generated = FALSE;
+		    AND p.generated = FALSE
+		    AND p.deleted_at IS NULL;
 		
 		`
 
@@ -112,7 +145,8 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 		    entities AS e
 		WHERE
 		    e.parent_id IS NOT NULL
-		    AND e.show = TRUE;
+		    AND e.show = TRUE
+		    AND e.deleted_at IS NULL;
 		
 		`
 
@@ -144,10 +178,17 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 	}
 	defer tx.Rollback(ctx)
 
+	err = setPriceSource(ctx, tx, "generator")
+	if err != nil {
+		return nil, err
+	}
+
 	insertOrUpdateQuery := `
 		INSERT INTO products (id, name, description, info, price, count, entity_id, category_id, brand_id, slug, keywords, image_id, generated, generatable, show)
 		    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		ON CONFLICT (name)
+		WHERE
+		    deleted_at IS NULL
 		    DO UPDATE SET
 		        description = EXCLUDED.description,
 		        info = EXCLUDED.info,
@@ -161,10 +202,13 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 		        image_id = EXCLUDED.image_id,
 		        show = TRUE
 		    RETURNING
-		        id;
+		        id,
+		        xmax = 0;
 		
 		`
 
+	var entries []AuditLog
+
 	// ------------------------------
 	// 4) Generate new products
 	// ------------------------------
@@ -180,7 +224,10 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 			combinedKeywords := append(base.Keywords, generator.Keywords...)
 			combinedKeywords = uniqueStrings(combinedKeywords)
 
-			var newID uuid.UUID
+			var (
+				newID    uuid.UUID
+				inserted bool
+			)
 
 			// Insert/update product
 			err = tx.QueryRow(ctx, insertOrUpdateQuery,
@@ -199,19 +246,39 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 				true,  // generated
 				false, // generatable
 				true,
-			).Scan(&newID)
+			).Scan(&newID, &inserted)
 			if err != nil {
 				return nil, fmt.Errorf("insert or get product id failed: %v", err)
 			}
 
+			entry := AuditLog{Action: "create", ResourceType: "products", ResourceID: pgtype.UUID{Bytes: newID, Valid: true}}
+
+			if !slices.ContainsFunc(entries, func(saved AuditLog) bool { return saved.ResourceID == entry.ResourceID }) {
+				if !inserted {
+					// Until the commit, other connections still see the
+					// product as it was.
+					entry.Action = "update"
+
+					entry.Before, err = auditSnapshot(ctx, s.db, auditSnapshotQueries["products"], newID)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				entries = append(entries, entry)
+			}
+
 			// Copy images
 			_, err = tx.Exec(ctx, `
-				INSERT INTO images (id, name, image_url, product_id)
+				INSERT INTO images (id, name, image_url, product_id, position, alt, variants)
 				SELECT
 				    gen_random_uuid (),
 				    name,
 				    image_url,
-				    $1
+				    $1,
+				    position,
+				    alt,
+				    variants
 				FROM
 				    images
 				WHERE
@@ -224,6 +291,24 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 				return nil, fmt.Errorf("copying images failed for base %s: %w", base.ID, err)
 			}
 
+			// Copy fitments
+			_, err = tx.Exec(ctx, `
+				INSERT INTO product_fitments (product_id, vehicle_id)
+				SELECT
+				    $1,
+				    vehicle_id
+				FROM
+				    product_fitments
+				WHERE
+				    product_id = $2
+				ON CONFLICT
+				    DO NOTHING;
+				
+				`, newID, base.ID)
+			if err != nil {
+				return nil, fmt.Errorf("copying fitments failed for base %s: %w", base.ID, err)
+			}
+
 			// Copy parameters
 			_, err = tx.Exec(ctx, `
 				INSERT INTO product_parameter_values (id, product_id, parameter_id, bool_value, text_value, selectable_value)
@@ -263,7 +348,7 @@ func (s *Service) GenerateProducts() ([]Product, error) {
 		return nil, err
 	}
 
-	return []Product{}, nil
+	return entries, nil
 }
 
 func (s *Service) ListProducts() ([]Product, error) {
@@ -300,8 +385,8 @@ LEFT JOIN images i ON p.image_id = i.id
 LEFT JOIN (
     SELECT
         product_id,
-        array_agg(id) AS image_ids,
-        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+        array_agg(id ORDER BY position, created_at) AS image_ids,
+        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
     FROM images
     WHERE product_id IS NOT NULL
     GROUP BY product_id
@@ -323,7 +408,8 @@ LEFT JOIN (
         ) AS product_parameter_values
     FROM product_parameter_values
     GROUP BY product_id
-) ppv_agg ON ppv_agg.product_id = p.id;
+) ppv_agg ON ppv_agg.product_id = p.id
+WHERE p.deleted_at IS NULL;
 		`
 
 	rows, err := s.db.Query(context.Background(), query)
@@ -396,9 +482,9 @@ func (s *Service) ListProductForAccountsWithSortFilterPagination(
 
 	var filterBy strings.Builder
 	if len(filters) > 0 {
-		filterBy.WriteString("WHERE products.generated IS false AND ")
+		filterBy.WriteString("WHERE products.deleted_at IS NULL AND products.generated IS false AND ")
 	} else {
-		filterBy.WriteString("WHERE products.generated IS false ")
+		filterBy.WriteString("WHERE products.deleted_at IS NULL AND products.generated IS false ")
 	}
 	// create map for filters
 	// filterMap := make(map[string]string)
@@ -433,6 +519,16 @@ func (s *Service) ListProductForAccountsWithSortFilterPagination(
 					filterOperand,
 					filterCondition,
 				))
+			case "vehicle":
+				// The condition is the id of the vehicle products must fit.
+				vehicleID, err := uuid.Parse(filterCondition)
+				if err != nil {
+					filterBy.WriteString("FALSE")
+
+					break
+				}
+
+				filterBy.WriteString(fitsVehicle("products", fmt.Sprintf("'%s'::uuid", vehicleID)))
 			default:
 				filterBy.WriteString(fmt.Sprintf(
 					`%s %s '%s'`,
@@ -495,6 +591,8 @@ func (s *Service) ListProductForAccountsWithSortFilterPagination(
     products.position,
     products.code,
 		brands.name,
+    products.rating_average,
+    products.rating_count,
     COALESCE(img_agg.image_ids, ARRAY[]::UUID[]) AS image_ids,
     COALESCE(img_agg.images, '[]'::JSON) AS images,
     COALESCE(ppv_agg.product_parameter_values, '[]'::JSON) AS product_parameter_values
@@ -506,8 +604,8 @@ LEFT JOIN images i ON products.image_id = i.id
 LEFT JOIN (
     SELECT
         product_id,
-        array_agg(id) AS image_ids,
-        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+        array_agg(id ORDER BY position, created_at) AS image_ids,
+        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
     FROM images
     WHERE product_id IS NOT NULL
     GROUP BY product_id
@@ -544,7 +642,7 @@ LEFT JOIN (
 
 	for rows.Next() {
 		var product Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Info, &product.Price, &product.Count, &product.EntityID, &product.CategoryID, &product.CategoryName, &product.BrandID, &product.Slug, &product.Keywords, &product.CreatedAt, &product.UpdatedAt, &product.Generatable, &product.Generated, &product.ImageID, &product.ImageUrl, &product.Show, &product.Position, &product.Code, &product.BrandName, &product.ImageIDs, &product.Images, &product.ProductParameterValues); err != nil {
+		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Info, &product.Price, &product.Count, &product.EntityID, &product.CategoryID, &product.CategoryName, &product.BrandID, &product.Slug, &product.Keywords, &product.CreatedAt, &product.UpdatedAt, &product.Generatable, &product.Generated, &product.ImageID, &product.ImageUrl, &product.Show, &product.Position, &product.Code, &product.BrandName, &product.RatingAverage, &product.RatingCount, &product.ImageIDs, &product.Images, &product.ProductParameterValues); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 
 			return
@@ -564,8 +662,8 @@ LEFT JOIN images i ON products.image_id = i.id
 LEFT JOIN (
     SELECT
         product_id,
-        array_agg(id) AS image_ids,
-        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+        array_agg(id ORDER BY position, created_at) AS image_ids,
+        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
     FROM images
     WHERE product_id IS NOT NULL
     GROUP BY product_id
@@ -617,16 +715,15 @@ LEFT JOIN (
 	}
 }
 
-func (s *Service) ListProductsWithSortFilterPagination(
+// productListClauses builds the WHERE and ORDER BY clauses of the product
+// listing and export from their query parameters.
+func productListClauses(
 	sort string,
 	sortDirection string,
 	filters []string,
 	filterOperands []string,
 	filterConditions []string,
-	countInPage string,
-	offset string,
-	w http.ResponseWriter,
-) {
+) (string, string) {
 	orderBy := ""
 
 	if sort != "" {
@@ -661,6 +758,11 @@ func (s *Service) ListProductsWithSortFilterPagination(
 				`ORDER BY products.position %s`,
 				sortDirection,
 			)
+		case "rating":
+			orderBy = fmt.Sprintf(
+				`ORDER BY products.rating_average %[1]s NULLS LAST, products.rating_count %[1]s`,
+				sortDirection,
+			)
 		default:
 			orderBy = fmt.Sprintf(
 				`ORDER BY products.%s COLLATE "fa-IR-x-icu" %s`,
@@ -672,7 +774,9 @@ func (s *Service) ListProductsWithSortFilterPagination(
 
 	var filterBy strings.Builder
 	if len(filters) > 0 {
-		filterBy.WriteString("WHERE ")
+		filterBy.WriteString("WHERE products.deleted_at IS NULL AND ")
+	} else {
+		filterBy.WriteString("WHERE products.deleted_at IS NULL ")
 	}
 	// create map for filters
 	// filterMap := make(map[string]string)
@@ -712,6 +816,16 @@ func (s *Service) ListProductsWithSortFilterPagination(
 					filterOperand,
 					filterCondition,
 				))
+			case "vehicle":
+				// The condition is the id of the vehicle products must fit.
+				vehicleID, err := uuid.Parse(filterCondition)
+				if err != nil {
+					filterBy.WriteString("FALSE")
+
+					break
+				}
+
+				filterBy.WriteString(fitsVehicle("products", fmt.Sprintf("'%s'::uuid", vehicleID)))
 			default:
 				filterBy.WriteString(fmt.Sprintf(
 					`%s %s '%s'`,
@@ -727,6 +841,21 @@ func (s *Service) ListProductsWithSortFilterPagination(
 		}
 	}
 
+	return filterBy.String(), orderBy
+}
+
+func (s *Service) ListProductsWithSortFilterPagination(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	countInPage string,
+	offset string,
+	w http.ResponseWriter,
+) {
+	filterBy, orderBy := productListClauses(sort, sortDirection, filters, filterOperands, filterConditions)
+
 	pagedBy := ""
 	offsetNum := 0
 
@@ -774,6 +903,8 @@ func (s *Service) ListProductsWithSortFilterPagination(
     products.position,
     products.code,
 		brands.name,
+    products.rating_average,
+    products.rating_count,
     COALESCE(img_agg.image_ids, ARRAY[]::UUID[]) AS image_ids,
     COALESCE(img_agg.images, '[]'::JSON) AS images,
     COALESCE(ppv_agg.product_parameter_values, '[]'::JSON) AS product_parameter_values
@@ -785,8 +916,8 @@ LEFT JOIN images i ON products.image_id = i.id
 LEFT JOIN (
     SELECT
         product_id,
-        array_agg(id) AS image_ids,
-        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+        array_agg(id ORDER BY position, created_at) AS image_ids,
+        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
     FROM images
     WHERE product_id IS NOT NULL
     GROUP BY product_id
@@ -809,7 +940,7 @@ LEFT JOIN (
     FROM product_parameter_values
     GROUP BY product_id
 ) ppv_agg ON ppv_agg.product_id = products.id %s %s %s;
-		`, filterBy.String(), orderBy, pagedBy)
+		`, filterBy, orderBy, pagedBy)
 
 	rows, err := s.db.Query(context.Background(), query)
 	if err != nil {
@@ -823,7 +954,7 @@ LEFT JOIN (
 
 	for rows.Next() {
 		var product Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Info, &product.Price, &product.Count, &product.EntityID, &product.CategoryID, &product.CategoryName, &product.BrandID, &product.Slug, &product.Keywords, &product.CreatedAt, &product.UpdatedAt, &product.Generatable, &product.Generated, &product.ImageID, &product.ImageUrl, &product.Show, &product.Position, &product.Code, &product.BrandName, &product.ImageIDs, &product.Images, &product.ProductParameterValues); err != nil {
+		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Info, &product.Price, &product.Count, &product.EntityID, &product.CategoryID, &product.CategoryName, &product.BrandID, &product.Slug, &product.Keywords, &product.CreatedAt, &product.UpdatedAt, &product.Generatable, &product.Generated, &product.ImageID, &product.ImageUrl, &product.Show, &product.Position, &product.Code, &product.BrandName, &product.RatingAverage, &product.RatingCount, &product.ImageIDs, &product.Images, &product.ProductParameterValues); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 
 			return
@@ -843,8 +974,8 @@ LEFT JOIN images i ON products.image_id = i.id
 LEFT JOIN (
     SELECT
         product_id,
-        array_agg(id) AS image_ids,
-        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+        array_agg(id ORDER BY position, created_at) AS image_ids,
+        json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
     FROM images
     WHERE product_id IS NOT NULL
     GROUP BY product_id
@@ -868,7 +999,7 @@ LEFT JOIN (
     GROUP BY product_id
 ) ppv_agg ON ppv_agg.product_id = products.id %s ;
 
-		`, filterBy.String())
+		`, filterBy)
 	row := s.db.QueryRow(context.Background(), newQuery)
 
 	var Count int32
@@ -917,7 +1048,7 @@ func (s *Service) RecentlyAddedProducts() ([]Product, error) {
 		    products p
 		    LEFT JOIN images i ON p.image_id = i.id
 		WHERE
-		    p.created_at > $1 AND p.show IS TRUE
+		    p.created_at > $1 AND p.show IS TRUE AND p.deleted_at IS NULL
 		ORDER BY
 		    p.created_at ASC`
 
@@ -951,19 +1082,6 @@ func (s *Service) GetProduct(id string) (Product, error) {
 	}
 
 	query := `
-		WITH product_with_parent_Entity AS (
-		    SELECT
-		        p.id AS product_id,
-		        p.slug,
-		        c1.id AS category_id,
-		        c2.id AS parent_category_id
-		    FROM
-		        products p
-		        JOIN categories c1 ON p.category_id = c1.id
-		        LEFT JOIN categories c2 ON c1.parent_id = c2.id
-		    WHERE
-		        p.id = $1
-		)
 		SELECT
 		    p.id,
 		    p.name,
@@ -985,20 +1103,21 @@ func (s *Service) GetProduct(id string) (Product, error) {
 		    i.image_url,
 		    p.show,
 		    p.code,
+		    p.rating_average,
+		    p.rating_count,
 		    COALESCE(img_agg.image_ids, ARRAY[]::UUID[]) AS image_ids,
 		    COALESCE(img_agg.images, '[]'::JSON) AS images,
 		    COALESCE(ppv_agg.product_parameter_values, '[]'::JSON) AS product_parameter_values
 		FROM
 		    products p
-		    JOIN product_with_parent_Entity pwpc ON p.id = pwpc.product_id
 		    LEFT JOIN images i ON p.image_id = i.id
 		    LEFT JOIN brands b ON p.brand_id = b.id
 		    -- Aggregate images
 		    LEFT JOIN (
 		        SELECT
 		            product_id,
-		            array_agg(id) AS image_ids,
-		            json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+		            array_agg(id ORDER BY position, created_at) AS image_ids,
+		            json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
 		        FROM
 		            images
 		        WHERE
@@ -1014,19 +1133,18 @@ func (s *Service) GetProduct(id string) (Product, error) {
 		            product_parameter_values
 		        GROUP BY
 		            product_id) ppv_agg ON ppv_agg.product_id = p.id
-		    -- Aggregate parameters from parameter groups linked to parent Entity
-		    LEFT JOIN (
+		    -- Aggregate the parameters the category of the product has and inherits
+		    LEFT JOIN LATERAL (
 		        SELECT
-		            pg.category_id,
-		            json_agg(json_build_object('id', prm.id, 'name', prm.name, 'description', prm.description, 'type', prm.type, 'selectables', prm.selectables, 'priority', prm.priority, 'createdAt', prm.created_at)
-		            ORDER BY prm.priority::INT) AS parameters
+		            json_agg(json_build_object('id', cp.id, 'name', cp.name, 'description', cp.description, 'type', cp.type, 'parameterGroupId', cp.parameter_group_id, 'parameterGroup', cp.parameter_group, 'selectables', cp.selectables, 'priority', cp.priority, 'categoryId', cp.category_id, 'inherited', cp.inherited, 'overridden', cp.overridden, 'createdAt', cp.created_at)
+		            ORDER BY cp.priority::INT, cp.name) AS parameters
 		        FROM
-		            parameter_groups pg
-		            JOIN parameters prm ON prm.parameter_group_id = pg.id
-		        GROUP BY
-		            pg.category_id) p_agg ON p_agg.category_id = pwpc.parent_category_id
+		            category_parameters (p.category_id) cp
+		        WHERE
+		            NOT cp.hidden) p_agg ON TRUE
 		WHERE
-		    p.id = $1;
+		    p.id = $1
+		    AND p.deleted_at IS NULL;
 		
 		`
 	row := s.db.QueryRow(context.Background(), query, parsedUUID)
@@ -1054,6 +1172,8 @@ func (s *Service) GetProduct(id string) (Product, error) {
 		&product.ImageUrl,
 		&product.Show,
 		&product.Code,
+		&product.RatingAverage,
+		&product.RatingCount,
 		&product.ImageIDs,
 		&product.Images,
 		&productParameterValuesJSON,
@@ -1073,19 +1193,6 @@ func (s *Service) GetProductBySlug(slug string) (Product, error) {
 	var product Product
 
 	query := `
-		WITH product_with_parent_Entity AS (
-		    SELECT
-		        p.id AS product_id,
-		        p.slug,
-		        c1.id AS category_id,
-		        c2.id AS parent_category_id
-		    FROM
-		        products p
-		        JOIN categories c1 ON p.category_id = c1.id
-		        LEFT JOIN categories c2 ON c1.parent_id = c2.id
-		    WHERE
-		        p.slug = $1
-		)
 		SELECT
 		    p.id,
 		    p.name,
@@ -1107,20 +1214,21 @@ func (s *Service) GetProductBySlug(slug string) (Product, error) {
 		    i.image_url,
 		    p.show,
 		    p.code,
+		    p.rating_average,
+		    p.rating_count,
 		    COALESCE(img_agg.image_ids, ARRAY[]::UUID[]) AS image_ids,
 		    COALESCE(img_agg.images, '[]'::JSON) AS images,
 		    COALESCE(ppv_agg.product_parameter_values, '[]'::JSON) AS product_parameter_values
 		FROM
 		    products p
-		    JOIN product_with_parent_Entity pwpc ON p.id = pwpc.product_id
 		    LEFT JOIN images i ON p.image_id = i.id
 		    LEFT JOIN brands b ON p.brand_id = b.id
 		    -- Aggregate images
 		    LEFT JOIN (
 		        SELECT
 		            product_id,
-		            array_agg(id) AS image_ids,
-		            json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name)) AS images
+		            array_agg(id ORDER BY position, created_at) AS image_ids,
+		            json_agg(json_build_object('id', id, 'imageUrl', image_url, 'name', name, 'alt', alt, 'position', position, 'variants', variants) ORDER BY position, created_at) AS images
 		        FROM
 		            images
 		        WHERE
@@ -1136,19 +1244,18 @@ func (s *Service) GetProductBySlug(slug string) (Product, error) {
 		            product_parameter_values
 		        GROUP BY
 		            product_id) ppv_agg ON ppv_agg.product_id = p.id
-		    -- Aggregate parameters from parameter groups linked to parent Entity
-		    LEFT JOIN (
+		    -- Aggregate the parameters the category of the product has and inherits
+		    LEFT JOIN LATERAL (
 		        SELECT
-		            pg.category_id,
-		            json_agg(json_build_object('id', prm.id, 'name', prm.name, 'description', prm.description, 'type', prm.type, 'selectables', prm.selectables, 'priority', prm.priority, 'createdAt', prm.created_at)
-		            ORDER BY prm.priority::INT) AS parameters
+		            json_agg(json_build_object('id', cp.id, 'name', cp.name, 'description', cp.description, 'type', cp.type, 'parameterGroupId', cp.parameter_group_id, 'parameterGroup', cp.parameter_group, 'selectables', cp.selectables, 'priority', cp.priority, 'categoryId', cp.category_id, 'inherited', cp.inherited, 'overridden', cp.overridden, 'createdAt', cp.created_at)
+		            ORDER BY cp.priority::INT, cp.name) AS parameters
 		        FROM
-		            parameter_groups pg
-		            JOIN parameters prm ON prm.parameter_group_id = pg.id
-		        GROUP BY
-		            pg.category_id) p_agg ON p_agg.category_id = pwpc.parent_category_id
+		            category_parameters (p.category_id) cp
+		        WHERE
+		            NOT cp.hidden) p_agg ON TRUE
 		WHERE
-		    p.slug = $1;
+		    p.slug = $1
+		    AND p.deleted_at IS NULL;
 		
 		`
 
@@ -1177,6 +1284,8 @@ func (s *Service) GetProductBySlug(slug string) (Product, error) {
 		&product.ImageUrl,
 		&product.Show,
 		&product.Code,
+		&product.RatingAverage,
+		&product.RatingCount,
 		&product.ImageIDs,
 		&product.Images,
 		&productParameterValuesJSON,
@@ -1189,21 +1298,35 @@ func (s *Service) GetProductBySlug(slug string) (Product, error) {
 		return product, err
 	}
 
-	return product, nil
+	ctx := context.Background()
+
+	product.Relations, err = s.productRelations(ctx, product.ID)
+	if err != nil {
+		return product, err
+	}
+
+	product.Questions, err = s.storeQuestions(ctx, product.ID)
+	if err != nil {
+		return product, err
+	}
+
+	product.Breadcrumbs, err = s.breadcrumbs(ctx, product.CategoryID)
+
+	return product, err
 }
 
-func (s *Service) CreateProduct(product Product) error {
+func (s *Service) CreateProduct(product Product) (uuid.UUID, error) {
 	query := "INSERT INTO products (id,name,description,info,price,count,category_id,brand_id,image_id,slug,keywords,generatable,show,position,code) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15);"
 	validate := utils.NewValidate()
 
 	err := validate.Struct(product)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	tx, err := s.db.Begin(context.Background())
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 	defer tx.Rollback(context.Background())
 
@@ -1229,23 +1352,12 @@ func (s *Service) CreateProduct(product Product) error {
 		product.Code,
 	)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	for _, imgID := range product.ImageIDs {
-		_, err = tx.Exec(context.Background(),
-			`
-				UPDATE
-				    images
-				SET
-				    product_id = $1
-				WHERE
-				    id = $2`,
-			id, imgID,
-		)
-		if err != nil {
-			return err
-		}
+	err = setGallery(context.Background(), tx, "products", id, product.ImageIDs)
+	if err != nil {
+		return uuid.Nil, err
 	}
 
 	for _, ppv := range product.ProductParameterValues {
@@ -1261,86 +1373,26 @@ func (s *Service) CreateProduct(product Product) error {
 			ppv.SelectableValue,
 		)
 		if err != nil {
-			return err
+			return uuid.Nil, err
 		}
 	}
 
-	return tx.Commit(context.Background())
-}
-
-func (s *Service) EditProduct(id string, product Product) error {
-	query := "UPDATE products SET name=$1,description=$2,info=$3,price=$4,count=$5,category_id=$6,brand_id=$7,image_id=$8,slug=$9,keywords=$10,generatable=$11,show=$12,position=$13,code=$14 WHERE id=$15;"
-	validate := utils.NewValidate()
-
-	err := validate.Struct(product)
-	if err != nil {
-		return err
-	}
-
-	tx, err := s.db.Begin(context.Background())
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(context.Background())
-
-	_, err = s.db.Exec(
-		context.Background(),
-		query,
-		product.Name,
-		product.Description,
-		product.Info,
-		product.Price,
-		product.Count,
-		product.CategoryID,
-		product.BrandID,
-		product.ImageID,
-		product.Slug,
-		product.Keywords,
-		product.Generatable,
-		product.Show,
-		product.Position,
-		product.Code,
-		id,
-	)
-	if err != nil {
-		return err
-	}
-
-	_, err = tx.Exec(context.Background(),
-		`
-			UPDATE
-			    images
-			SET
-			    product_id = NULL
-			WHERE
-			    product_id = $1`,
-		id,
-	)
+	err = tx.Commit(context.Background())
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
-	for _, imgID := range product.ImageIDs {
-		_, err = tx.Exec(context.Background(),
-			`
-				UPDATE
-				    images
-				SET
-				    product_id = $1
-				WHERE
-				    id = $2`,
-			id, imgID,
-		)
-		if err != nil {
-			return err
-		}
-	}
+	return id, nil
+}
 
-	for _, ppv := range product.ProductParameterValues {
+// saveProductParameterValues upserts values for the product id; values of
+// other parameters are kept.
+func saveProductParameterValues(ctx context.Context, tx pgx.Tx, id uuid.UUID, values []ProductParameterValue) error {
+	for _, ppv := range values {
 		ppvId := uuid.New()
 
-		_, err = tx.Exec(
-			context.Background(),
+		_, err := tx.Exec(
+			ctx,
 			`
 				INSERT INTO product_parameter_values (id, product_id, parameter_id, bool_value, text_value, selectable_value)
 				    VALUES ($1, -- id
@@ -1369,18 +1421,11 @@ func (s *Service) EditProduct(id string, product Product) error {
 		}
 	}
 
-	return tx.Commit(context.Background())
+	return nil
 }
 
 func (s *Service) DeleteProduct(id string) error {
-	query := "DELETE FROM products WHERE id=$1"
-
-	_, err := s.db.Exec(context.Background(), query, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.softDelete("products", id)
 }
 
 func (s *Service) ProductsInCategory(category_id string) ([]Product, error) {
@@ -1404,15 +1449,15 @@ func (s *Service) ProductsInCategory(category_id string) ([]Product, error) {
 		    p.show,
 		    p.position,
 		    p.code,
-		    COALESCE(array_agg(ims.id) FILTER (WHERE ims.id IS NOT NULL), ARRAY[]::UUID[]) AS image_ids,
-		    COALESCE(json_agg(json_build_object('id', ims.id, 'imageUrl', ims.image_url, 'name', ims.name)) FILTER (WHERE ims.id IS NOT NULL), '[]'::JSON) AS images
+		    COALESCE(array_agg(ims.id ORDER BY ims.position, ims.created_at) FILTER (WHERE ims.id IS NOT NULL), ARRAY[]::UUID[]) AS image_ids,
+		    COALESCE(json_agg(json_build_object('id', ims.id, 'imageUrl', ims.image_url, 'name', ims.name, 'alt', ims.alt, 'position', ims.position, 'variants', ims.variants) ORDER BY ims.position, ims.created_at) FILTER (WHERE ims.id IS NOT NULL), '[]'::JSON) AS images
 		FROM
 		    products p
-		    LEFT JOIN categories c ON p.category_id = c.id
 		    LEFT JOIN images i ON p.image_id = i.id
 		    LEFT JOIN images ims ON ims.product_id = p.id
-		WHERE (c.parent_id = $1
-		    OR c.id = $1)
+		WHERE
+		    ` + inCategoryTree("p.category_id", "$1") + `
+		    AND p.deleted_at IS NULL
 		GROUP BY
 		    p.id,
 		    i.image_url;
@@ -1459,8 +1504,8 @@ func (s *Service) ProductsInEntity(entity_id string) ([]Product, error) {
 		    i.image_url,
 		    p.show,
 		    p.code,
-		    COALESCE(array_agg(ims.id) FILTER (WHERE ims.id IS NOT NULL), ARRAY[]::UUID[]) AS image_ids,
-		    COALESCE(json_agg(json_build_object('id', ims.id, 'imageUrl', ims.image_url, 'name', ims.name)) FILTER (WHERE ims.id IS NOT NULL), '[]'::JSON) AS images
+		    COALESCE(array_agg(ims.id ORDER BY ims.position, ims.created_at) FILTER (WHERE ims.id IS NOT NULL), ARRAY[]::UUID[]) AS image_ids,
+		    COALESCE(json_agg(json_build_object('id', ims.id, 'imageUrl', ims.image_url, 'name', ims.name, 'alt', ims.alt, 'position', ims.position, 'variants', ims.variants) ORDER BY ims.position, ims.created_at) FILTER (WHERE ims.id IS NOT NULL), '[]'::JSON) AS images
 		FROM
 		    products p
 		    LEFT JOIN categories c ON p.category_id = c.id
@@ -1471,6 +1516,7 @@ func (s *Service) ProductsInEntity(entity_id string) ([]Product, error) {
 		WHERE (p.entity_id = $1
 		    OR pe.id = $1)
 		AND p.generated = TRUE
+		AND p.deleted_at IS NULL
 		GROUP BY
 		    p.id,
 		    i.image_url;
@@ -1517,6 +1563,7 @@ func (s *Service) ProductsSearch(keywords string) ([]Product, error) {
 		    LEFT JOIN images AS i ON p.image_id = i.id
 		WHERE
 		    p.show IS TRUE
+		    AND p.deleted_at IS NULL
 		    AND (p.fts @@ phraseto_tsquery('simple', normalize_persian ($1))
 		        OR normalize_persian (p.name)
 		        ILIKE '%' || normalize_persian ($1) || '%')
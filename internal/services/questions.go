@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/pzonouz/pzonouz-caroption-back-golang/internal/mails"
+)
+
+var ErrInvalidQuestion = errors.New("invalid question")
+
+// maxQuestionLength is the longest question, in characters, a customer can
+// ask.
+const maxQuestionLength = 1000
+
+// AskQuestion saves the question of the user about the visible product with
+// slug, pending an answer.
+func (s *Service) AskQuestion(userID string, slug string, question Question) (uuid.UUID, error) {
+	question.Question = strings.TrimSpace(question.Question)
+
+	if question.Question == "" {
+		return uuid.Nil, fmt.Errorf("%w: question is required", ErrInvalidQuestion)
+	}
+
+	if utf8.RuneCountInString(question.Question) > maxQuestionLength {
+		return uuid.Nil, fmt.Errorf("%w: question must be at most %d characters", ErrInvalidQuestion, maxQuestionLength)
+	}
+
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	ctx := context.Background()
+
+	var productID pgtype.UUID
+
+	err = s.db.QueryRow(
+		ctx,
+		"SELECT p.id"+storeProductFrom+" WHERE p.slug = $1 AND "+visibleProduct+" LIMIT 1",
+		slug,
+	).Scan(&productID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id := uuid.New()
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO product_questions (id, product_id, user_id, question)
+		    VALUES ($1, $2, $3, $4)`,
+		id, productID, parsedUserID, question.Question,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// storeQuestions returns the approved and answered questions of a product,
+// the last answered first.
+func (s *Service) storeQuestions(ctx context.Context, productID pgtype.UUID) ([]PublicQuestion, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    q.id,
+		    `+authorName("q")+`,
+		    q.question,
+		    q.answer,
+		    q.answered_at,
+		    q.created_at
+		FROM
+		    product_questions q
+		WHERE
+		    q.product_id = $1
+		    AND q.status = 'approved'
+		    AND q.answer IS NOT NULL
+		ORDER BY
+		    q.answered_at DESC`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []PublicQuestion
+
+	for rows.Next() {
+		var question PublicQuestion
+		if err := rows.Scan(&question.ID, &question.Author, &question.Question, &question.Answer, &question.AnsweredAt, &question.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		questions = append(questions, question)
+	}
+
+	return questions, rows.Err()
+}
+
+var questionColumns = `
+	    q.id,
+	    q.product_id,
+	    p.name,
+	    q.user_id,
+	    ` + authorName("q") + `,
+	    q.question,
+	    q.answer,
+	    q.answered_by,
+	    q.answered_at,
+	    q.status,
+	    q.moderated_by,
+	    q.moderated_at,
+	    q.notified_at,
+	    q.created_at,
+	    q.updated_at`
+
+func scanQuestion(row pgx.Row, question *Question) error {
+	return row.Scan(
+		&question.ID,
+		&question.ProductID,
+		&question.ProductName,
+		&question.UserID,
+		&question.Author,
+		&question.Question,
+		&question.Answer,
+		&question.AnsweredBy,
+		&question.AnsweredAt,
+		&question.Status,
+		&question.ModeratedBy,
+		&question.ModeratedAt,
+		&question.NotifiedAt,
+		&question.CreatedAt,
+		&question.UpdatedAt,
+	)
+}
+
+// ListQuestions returns a page of the questions with status, pending ones
+// by default, optionally only of one product and only the unanswered ones.
+// The oldest come first so they are not left waiting.
+func (s *Service) ListQuestions(status string, productID string, unanswered bool, limit int, offset int) (QuestionPage, error) {
+	page := QuestionPage{Rows: []Question{}}
+
+	if status == "" {
+		status = "pending"
+	}
+
+	if !slices.Contains(moderationStatuses, status) {
+		return page, fmt.Errorf("%w: status must be one of %s", ErrInvalidStatus, strings.Join(moderationStatuses, ", "))
+	}
+
+	var (
+		conditions = []string{"q.status = $1"}
+		args       = []any{status}
+	)
+
+	if productID != "" {
+		parsedUUID, err := uuid.Parse(productID)
+		if err != nil {
+			return page, err
+		}
+
+		args = append(args, parsedUUID)
+		conditions = append(conditions, fmt.Sprintf("q.product_id = $%d", len(args)))
+	}
+
+	if unanswered {
+		conditions = append(conditions, "q.answer IS NULL")
+	}
+
+	if limit <= 0 {
+		limit = storePageSize
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+	from := " FROM product_questions q JOIN products p ON q.product_id = p.id"
+
+	ctx := context.Background()
+
+	err := s.db.QueryRow(ctx, "SELECT count(*)"+from+where, args...).Scan(&page.TotalCount)
+	if err != nil {
+		return page, err
+	}
+
+	args = append(args, limit, max(offset, 0))
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT"+questionColumns+from+where+fmt.Sprintf(" ORDER BY q.created_at, q.id LIMIT $%d OFFSET $%d", len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var question Question
+		if err := scanQuestion(rows, &question); err != nil {
+			return page, err
+		}
+
+		page.Rows = append(page.Rows, question)
+	}
+
+	return page, rows.Err()
+}
+
+func (s *Service) GetQuestion(id string) (Question, error) {
+	var question Question
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return question, err
+	}
+
+	err = scanQuestion(
+		s.db.QueryRow(
+			context.Background(),
+			"SELECT"+questionColumns+" FROM product_questions q JOIN products p ON q.product_id = p.id WHERE q.id = $1",
+			parsedUUID,
+		),
+		&question,
+	)
+
+	return question, err
+}
+
+// AnswerQuestion saves the answer of the admin answererID to a question and
+// approves it. The asker is mailed the first time it is answered.
+func (s *Service) AnswerQuestion(id string, answererID string, answer string) error {
+	answer = strings.TrimSpace(answer)
+
+	if answer == "" {
+		return fmt.Errorf("%w: answer is required", ErrInvalidQuestion)
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var answerer pgtype.UUID
+
+	_ = answerer.Scan(answererID)
+
+	tag, err := s.db.Exec(context.Background(), `
+		UPDATE
+		    product_questions
+		SET
+		    answer = $2,
+		    answered_by = $3,
+		    answered_at = now(),
+		    status = 'approved',
+		    moderated_by = $3,
+		    moderated_at = now(),
+		    updated_at = now()
+		WHERE
+		    id = $1`, parsedUUID, answer, answerer)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	go s.sendQuestionAnswered(parsedUUID)
+
+	return nil
+}
+
+// sendQuestionAnswered is best effort: an answer is saved even when the
+// asker has no verified email or the mail cannot be delivered. It runs in
+// the background so a slow mail server does not hold up the answer.
+// Claiming notified_at first keeps later edits of the answer, even ones
+// racing this one, from mailing again; it is cleared when the mail fails so
+// the next edit tries again.
+func (s *Service) sendQuestionAnswered(id uuid.UUID) {
+	var (
+		email pgtype.Text
+		data  mails.QuestionAnsweredData
+		slug  pgtype.Text
+	)
+
+	ctx := context.Background()
+
+	err := s.db.QueryRow(ctx, `
+		UPDATE
+		    product_questions q
+		SET
+		    notified_at = now()
+		FROM
+		    users u,
+		    products p
+		WHERE
+		    q.id = $1
+		    AND q.notified_at IS NULL
+		    AND u.id = q.user_id
+		    AND u.email_verified IS TRUE
+		    AND u.email IS NOT NULL
+		    AND p.id = q.product_id
+		RETURNING
+		    u.email,
+		    COALESCE(p.name, ''),
+		    p.slug,
+		    q.question,
+		    q.answer`, id).Scan(&email, &data.ProductName, &slug, &data.Question, &data.Answer)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+
+	if err != nil {
+		log.Printf("answer notification for question %s: %v", id, err)
+
+		return
+	}
+
+	data.Link = os.Getenv("BASE_URL") + "/products/" + slug.String
+
+	mail, err := mails.QuestionAnswered(email.String, data)
+	if err == nil {
+		err = s.mailer.Send(mail)
+	}
+
+	if err != nil {
+		log.Printf("answer notification for question %s: %v", id, err)
+
+		_, err = s.db.Exec(ctx, "UPDATE product_questions SET notified_at = NULL WHERE id = $1", id)
+		if err != nil {
+			log.Printf("answer notification for question %s: %v", id, err)
+		}
+	}
+}
+
+// ModerateQuestion approves or rejects a question, or puts it back as
+// pending, on behalf of the admin moderatorID.
+func (s *Service) ModerateQuestion(id string, moderatorID string, status string) error {
+	if !slices.Contains(moderationStatuses, status) {
+		return fmt.Errorf("%w: status must be one of %s", ErrInvalidStatus, strings.Join(moderationStatuses, ", "))
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var moderator pgtype.UUID
+
+	_ = moderator.Scan(moderatorID)
+
+	tag, err := s.db.Exec(context.Background(), `
+		UPDATE
+		    product_questions
+		SET
+		    status = $2,
+		    moderated_by = $3,
+		    moderated_at = now(),
+		    updated_at = now()
+		WHERE
+		    id = $1`, parsedUUID, status, moderator)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Service) DeleteQuestion(id string) error {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(context.Background(), "DELETE FROM product_questions WHERE id = $1", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalidRelationKind = errors.New("invalid relation kind")
+	ErrInvalidRelation     = errors.New("related products must be other products that exist")
+)
+
+// relationKinds are the relations curated between products.
+var relationKinds = []string{"related", "accessory", "replacement", "upsell"}
+
+// Products sold together in fewer than boughtTogetherMinimum sell invoices
+// are not listed as bought together, and no more than boughtTogetherLimit
+// are.
+const (
+	boughtTogetherMinimum = 2
+	boughtTogetherLimit   = 8
+)
+
+// boughtTogether is the products sold in the same sell invoices as the
+// product in $1, with how many, as long as it is at least $2.
+const boughtTogether = `
+	WITH together AS (
+	    SELECT
+	        other.product_id,
+	        count(DISTINCT ii.invoice_id) AS times
+	    FROM
+	        invoice_items ii
+	        JOIN invoices inv ON ii.invoice_id = inv.id
+	        JOIN invoice_items other ON other.invoice_id = ii.invoice_id
+	    WHERE
+	        ii.product_id = $1
+	        AND inv.type = 'sell'
+	        AND inv.deleted_at IS NULL
+	        AND ii.deleted_at IS NULL
+	        AND other.deleted_at IS NULL
+	        AND other.product_id <> ii.product_id
+	    GROUP BY
+	        other.product_id
+	    HAVING
+	        count(DISTINCT ii.invoice_id) >= $2
+	)`
+
+func emptyRelations() ProductRelations {
+	relations := ProductRelations{"boughtTogether": {}}
+
+	for _, kind := range relationKinds {
+		relations[kind] = []RelatedProduct{}
+	}
+
+	return relations
+}
+
+// productRelations returns the products a product has each relation to
+// and those bought together with it, leaving out trashed ones.
+func (s *Service) productRelations(ctx context.Context, productID pgtype.UUID) (ProductRelations, error) {
+	relations := emptyRelations()
+
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    pr.kind,
+		    p.id,
+		    p.name,
+		    p.slug,
+		    p.price,
+		    p.show,
+		    i.image_url
+		FROM
+		    product_relations pr
+		    JOIN products p ON pr.related_id = p.id
+		    LEFT JOIN images i ON p.image_id = i.id
+		WHERE
+		    pr.product_id = $1
+		    AND p.deleted_at IS NULL
+		ORDER BY
+		    pr.position`, productID)
+	if err != nil {
+		return relations, err
+	}
+
+	for rows.Next() {
+		var (
+			kind    string
+			product RelatedProduct
+		)
+
+		if err := rows.Scan(&kind, &product.ID, &product.Name, &product.Slug, &product.Price, &product.Show, &product.ImageUrl); err != nil {
+			rows.Close()
+
+			return relations, err
+		}
+
+		relations[kind] = append(relations[kind], product)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return relations, err
+	}
+
+	rows, err = s.db.Query(ctx, boughtTogether+`
+		SELECT
+		    p.id,
+		    p.name,
+		    p.slug,
+		    p.price,
+		    p.show,
+		    i.image_url
+		FROM
+		    together
+		    JOIN products p ON together.product_id = p.id
+		    LEFT JOIN images i ON p.image_id = i.id
+		WHERE
+		    p.deleted_at IS NULL
+		ORDER BY
+		    together.times DESC,
+		    p.name
+		LIMIT $3`, productID, boughtTogetherMinimum, boughtTogetherLimit)
+	if err != nil {
+		return relations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product RelatedProduct
+		if err := rows.Scan(&product.ID, &product.Name, &product.Slug, &product.Price, &product.Show, &product.ImageUrl); err != nil {
+			return relations, err
+		}
+
+		relations["boughtTogether"] = append(relations["boughtTogether"], product)
+	}
+
+	return relations, rows.Err()
+}
+
+// storeRelations returns the visible products related to a product, by
+// relation kind and boughtTogether, leaving out kinds without any.
+func (s *Service) storeRelations(ctx context.Context, productID pgtype.UUID) (map[string][]PublicProduct, error) {
+	related := map[string][]PublicProduct{}
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT"+storeProductColumns+", pr.kind"+storeProductFrom+`
+		    JOIN product_relations pr ON pr.related_id = p.id
+		WHERE
+		    pr.product_id = $1
+		    AND `+visibleProduct+`
+		ORDER BY
+		    pr.position`,
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			kind    string
+			product PublicProduct
+		)
+
+		if err := scanPublicProduct(rows, &product, &kind); err != nil {
+			rows.Close()
+
+			return nil, err
+		}
+
+		related[kind] = append(related[kind], product)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(
+		ctx,
+		boughtTogether+" SELECT"+storeProductColumns+storeProductFrom+`
+		    JOIN together ON together.product_id = p.id
+		WHERE
+		    `+visibleProduct+`
+		ORDER BY
+		    together.times DESC,
+		    p.name
+		LIMIT $3`,
+		productID, boughtTogetherMinimum, boughtTogetherLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product PublicProduct
+		if err := scanPublicProduct(rows, &product); err != nil {
+			return nil, err
+		}
+
+		related["boughtTogether"] = append(related["boughtTogether"], product)
+	}
+
+	return related, rows.Err()
+}
+
+// ListProductRelations returns the products a product has each relation
+// to, and those bought together with it.
+func (s *Service) ListProductRelations(productID string) (ProductRelations, error) {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return emptyRelations(), err
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+
+	err = s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", parsedUUID).Scan(&exists)
+	if err != nil {
+		return emptyRelations(), err
+	}
+
+	if !exists {
+		return emptyRelations(), pgx.ErrNoRows
+	}
+
+	return s.productRelations(ctx, pgtype.UUID{Bytes: parsedUUID, Valid: true})
+}
+
+// SetProductRelations makes ids, in this order, the products a product has
+// the relation kind to.
+func (s *Service) SetProductRelations(productID string, kind string, ids RelatedProductIDs) error {
+	if !slices.Contains(relationKinds, kind) {
+		return fmt.Errorf("%w: kind must be one of %s", ErrInvalidRelationKind, strings.Join(relationKinds, ", "))
+	}
+
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return err
+	}
+
+	if ids.ProductIDs == nil {
+		ids.ProductIDs = []pgtype.UUID{}
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int
+
+	err = tx.QueryRow(ctx, "SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", parsedUUID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	var found int
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    count(*)
+		FROM
+		    products
+		WHERE
+		    id = ANY ($2)
+		    AND id <> $1
+		    AND deleted_at IS NULL`, parsedUUID, ids.ProductIDs).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if found != len(ids.ProductIDs) {
+		return ErrInvalidRelation
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM product_relations WHERE product_id = $1 AND kind = $2", parsedUUID, kind)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_relations (product_id, related_id, kind, position)
+		SELECT
+		    $1,
+		    ordered.related_id,
+		    $2,
+		    ordered.ordinality - 1
+		FROM
+		    unnest($3::uuid[])
+		    WITH ORDINALITY AS ordered (related_id, ordinality)`, parsedUUID, kind, ids.ProductIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
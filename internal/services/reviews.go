@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalidReview = errors.New("invalid review")
+	ErrInvalidStatus = errors.New("invalid moderation status")
+)
+
+// maxReviewLength is the longest review body, in characters, a customer
+// can post.
+const maxReviewLength = 4000
+
+// moderationStatuses are the states of the reviews and questions customers
+// post; they start pending.
+var moderationStatuses = []string{"pending", "approved", "rejected"}
+
+// authorName is the first name the post aliased as alias is signed with,
+// from the person of its user.
+func authorName(alias string) string {
+	return fmt.Sprintf(`(
+	    SELECT
+	        NULLIF (trim(prs.first_name), '')
+	    FROM
+	        users u
+	        JOIN persons prs ON u.person_id = prs.id
+	    WHERE
+	        u.id = %s.user_id)`, alias)
+}
+
+// SubmitReview saves the rating and review of the user for the visible
+// product with slug. A user has one review of a product: posting again
+// replaces it and sends it back to moderation.
+func (s *Service) SubmitReview(userID string, slug string, review Review) (uuid.UUID, error) {
+	if review.Rating < 1 || review.Rating > 5 {
+		return uuid.Nil, fmt.Errorf("%w: rating must be from 1 to 5", ErrInvalidReview)
+	}
+
+	review.Body.String = strings.TrimSpace(review.Body.String)
+	review.Body.Valid = review.Body.String != ""
+
+	if utf8.RuneCountInString(review.Body.String) > maxReviewLength {
+		return uuid.Nil, fmt.Errorf("%w: review must be at most %d characters", ErrInvalidReview, maxReviewLength)
+	}
+
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	ctx := context.Background()
+
+	var productID pgtype.UUID
+
+	err = s.db.QueryRow(
+		ctx,
+		"SELECT p.id"+storeProductFrom+" WHERE p.slug = $1 AND "+visibleProduct+" LIMIT 1",
+		slug,
+	).Scan(&productID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var id uuid.UUID
+
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO product_reviews (product_id, user_id, rating, body)
+		    VALUES ($1, $2, $3, $4)
+		ON CONFLICT (product_id, user_id)
+		    DO UPDATE SET
+		        rating = EXCLUDED.rating,
+		        body = EXCLUDED.body,
+		        status = 'pending',
+		        moderated_by = NULL,
+		        moderated_at = NULL,
+		        updated_at = now()
+		    RETURNING
+		        id`,
+		productID, parsedUserID, review.Rating, review.Body,
+	).Scan(&id)
+
+	return id, err
+}
+
+// StoreReviews returns a page of the approved reviews of the visible
+// product with slug, newest first.
+func (s *Service) StoreReviews(slug string, limit int, offset int) (PublicReviewPage, error) {
+	page := PublicReviewPage{Rows: []PublicReview{}}
+
+	if limit <= 0 {
+		limit = storePageSize
+	}
+
+	limit = min(limit, maxStorePageSize)
+	offset = max(offset, 0)
+
+	ctx := context.Background()
+
+	var productID pgtype.UUID
+
+	err := s.db.QueryRow(
+		ctx,
+		"SELECT p.id, p.rating_count"+storeProductFrom+" WHERE p.slug = $1 AND "+visibleProduct+" LIMIT 1",
+		slug,
+	).Scan(&productID, &page.TotalCount)
+	if err != nil {
+		return page, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    r.id,
+		    `+authorName("r")+`,
+		    r.rating,
+		    r.body,
+		    r.reply,
+		    r.replied_at,
+		    r.created_at
+		FROM
+		    product_reviews r
+		WHERE
+		    r.product_id = $1
+		    AND r.status = 'approved'
+		ORDER BY
+		    r.created_at DESC
+		LIMIT $2 OFFSET $3`, productID, limit, offset)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review PublicReview
+		if err := rows.Scan(&review.ID, &review.Author, &review.Rating, &review.Body, &review.Reply, &review.RepliedAt, &review.CreatedAt); err != nil {
+			return page, err
+		}
+
+		page.Rows = append(page.Rows, review)
+	}
+
+	return page, rows.Err()
+}
+
+var reviewColumns = `
+	    r.id,
+	    r.product_id,
+	    p.name,
+	    r.user_id,
+	    ` + authorName("r") + `,
+	    r.rating,
+	    r.body,
+	    r.status,
+	    r.reply,
+	    r.replied_at,
+	    r.moderated_by,
+	    r.moderated_at,
+	    r.created_at,
+	    r.updated_at`
+
+func scanReview(row pgx.Row, review *Review) error {
+	return row.Scan(
+		&review.ID,
+		&review.ProductID,
+		&review.ProductName,
+		&review.UserID,
+		&review.Author,
+		&review.Rating,
+		&review.Body,
+		&review.Status,
+		&review.Reply,
+		&review.RepliedAt,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+}
+
+// ListReviews returns a page of the moderation queue: the reviews with
+// status, pending ones by default, optionally only of one product. The
+// oldest come first so they are not left waiting.
+func (s *Service) ListReviews(status string, productID string, limit int, offset int) (ReviewPage, error) {
+	page := ReviewPage{Rows: []Review{}}
+
+	if status == "" {
+		status = "pending"
+	}
+
+	if !slices.Contains(moderationStatuses, status) {
+		return page, fmt.Errorf("%w: status must be one of %s", ErrInvalidStatus, strings.Join(moderationStatuses, ", "))
+	}
+
+	var (
+		conditions = []string{"r.status = $1"}
+		args       = []any{status}
+	)
+
+	if productID != "" {
+		parsedUUID, err := uuid.Parse(productID)
+		if err != nil {
+			return page, err
+		}
+
+		args = append(args, parsedUUID)
+		conditions = append(conditions, fmt.Sprintf("r.product_id = $%d", len(args)))
+	}
+
+	if limit <= 0 {
+		limit = storePageSize
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+	from := " FROM product_reviews r JOIN products p ON r.product_id = p.id"
+
+	ctx := context.Background()
+
+	err := s.db.QueryRow(ctx, "SELECT count(*)"+from+where, args...).Scan(&page.TotalCount)
+	if err != nil {
+		return page, err
+	}
+
+	args = append(args, limit, max(offset, 0))
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT"+reviewColumns+from+where+fmt.Sprintf(" ORDER BY r.updated_at, r.id LIMIT $%d OFFSET $%d", len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review Review
+		if err := scanReview(rows, &review); err != nil {
+			return page, err
+		}
+
+		page.Rows = append(page.Rows, review)
+	}
+
+	return page, rows.Err()
+}
+
+func (s *Service) GetReview(id string) (Review, error) {
+	var review Review
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return review, err
+	}
+
+	err = scanReview(
+		s.db.QueryRow(
+			context.Background(),
+			"SELECT"+reviewColumns+" FROM product_reviews r JOIN products p ON r.product_id = p.id WHERE r.id = $1",
+			parsedUUID,
+		),
+		&review,
+	)
+
+	return review, err
+}
+
+// ModerateReview approves or rejects a review, or puts it back as pending,
+// on behalf of the admin moderatorID.
+func (s *Service) ModerateReview(id string, moderatorID string, status string) error {
+	if !slices.Contains(moderationStatuses, status) {
+		return fmt.Errorf("%w: status must be one of %s", ErrInvalidStatus, strings.Join(moderationStatuses, ", "))
+	}
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var moderator pgtype.UUID
+
+	_ = moderator.Scan(moderatorID)
+
+	tag, err := s.db.Exec(context.Background(), `
+		UPDATE
+		    product_reviews
+		SET
+		    status = $2,
+		    moderated_by = $3,
+		    moderated_at = now(),
+		    updated_at = now()
+		WHERE
+		    id = $1`, parsedUUID, status, moderator)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// ReplyToReview sets the public reply of the shop to a review; an empty
+// reply removes it.
+func (s *Service) ReplyToReview(id string, reply string) error {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(context.Background(), `
+		UPDATE
+		    product_reviews
+		SET
+		    reply = NULLIF ($2, ''),
+		    replied_at = CASE WHEN $2 = '' THEN
+		        NULL
+		    ELSE
+		        now()
+		    END,
+		    updated_at = now()
+		WHERE
+		    id = $1`, parsedUUID, strings.TrimSpace(reply))
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Service) DeleteReview(id string) error {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(context.Background(), "DELETE FROM product_reviews WHERE id = $1", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,596 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// storePageSize is the default and maxStorePageSize the largest page of
+// products the storefront lists.
+const (
+	storePageSize    = 24
+	maxStorePageSize = 100
+)
+
+// visibleCategory matches a category c shown in the storefront: shown and
+// not in the trash, and so is every category above it.
+const visibleCategory = `category_visible (c.id)`
+
+// visibleProduct matches a product p shown in the storefront; products in
+// a hidden category are hidden with it.
+const visibleProduct = `p.show IS TRUE
+    AND p.deleted_at IS NULL
+    AND (p.category_id IS NULL
+        OR (` + visibleCategory + `))`
+
+const storeProductFrom = `
+	FROM
+	    products p
+	    LEFT JOIN categories c ON p.category_id = c.id
+	    LEFT JOIN brands b ON p.brand_id = b.id
+	        AND b.deleted_at IS NULL
+	    LEFT JOIN images i ON p.image_id = i.id`
+
+const storeProductColumns = `
+	    p.id,
+	    p.name,
+	    p.slug,
+	    p.description,
+	    p.info,
+	    p.price,
+	    c.name,
+	    c.slug,
+	    b.name,
+	    p.keywords,
+	    i.image_url,
+	    p.rating_average,
+	    p.rating_count`
+
+// storeProductSorts maps the sort names of the storefront to their order.
+var storeProductSorts = map[string]string{
+	"newest":     "p.created_at DESC",
+	"price_asc":  "CASE WHEN p.price ~ '^[0-9]+(\\.[0-9]+)?$' THEN p.price::numeric END ASC NULLS LAST",
+	"price_desc": "CASE WHEN p.price ~ '^[0-9]+(\\.[0-9]+)?$' THEN p.price::numeric END DESC NULLS LAST",
+	"name":       "p.name",
+	"rating":     "p.rating_average DESC NULLS LAST, p.rating_count DESC",
+}
+
+func scanPublicProduct(row pgx.Row, product *PublicProduct, extra ...any) error {
+	return row.Scan(append([]any{
+		&product.ID,
+		&product.Name,
+		&product.Slug,
+		&product.Description,
+		&product.Info,
+		&product.Price,
+		&product.CategoryName,
+		&product.CategorySlug,
+		&product.BrandName,
+		&product.Keywords,
+		&product.ImageUrl,
+		&product.RatingAverage,
+		&product.RatingCount,
+	}, extra...)...)
+}
+
+// StoreProducts lists the visible products, optionally only those in the
+// category with categorySlug or below it, those fitting the vehicle
+// with vehicleID and those matching search. Unknown sorts list the newest
+// first.
+func (s *Service) StoreProducts(
+	categorySlug string,
+	vehicleID string,
+	search string,
+	sort string,
+	limit int,
+	offset int,
+) (PublicProductPage, error) {
+	page := PublicProductPage{Rows: []PublicProduct{}}
+
+	if limit <= 0 {
+		limit = storePageSize
+	}
+
+	limit = min(limit, maxStorePageSize)
+	offset = max(offset, 0)
+
+	var (
+		conditions = []string{visibleProduct}
+		args       []any
+	)
+
+	if categorySlug != "" {
+		args = append(args, categorySlug)
+		conditions = append(conditions, inCategoryTree("p.category_id", categoryBySlug(len(args))))
+	}
+
+	if vehicleID != "" {
+		parsedUUID, err := uuid.Parse(vehicleID)
+		if err != nil {
+			return page, fmt.Errorf("%w: %s", ErrInvalidVehicle, err)
+		}
+
+		args = append(args, parsedUUID)
+		conditions = append(conditions, fitsVehicle("p", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if search != "" {
+		args = append(args, search)
+		conditions = append(conditions, fmt.Sprintf(
+			"(p.fts @@ phraseto_tsquery('simple', normalize_persian ($%[1]d)) OR normalize_persian (p.name) ILIKE '%%' || normalize_persian ($%[1]d) || '%%')",
+			len(args),
+		))
+	}
+
+	orderBy, ok := storeProductSorts[sort]
+	if !ok {
+		orderBy = storeProductSorts["newest"]
+	}
+
+	where := "WHERE " + strings.Join(conditions, " AND ")
+	ctx := context.Background()
+
+	err := s.db.QueryRow(ctx, "SELECT count(*)"+storeProductFrom+" "+where, args...).Scan(&page.TotalCount)
+	if err != nil {
+		return page, err
+	}
+
+	rows, err := s.db.Query(
+		ctx,
+		fmt.Sprintf("SELECT%s%s %s ORDER BY %s, p.id LIMIT %d OFFSET %d", storeProductColumns, storeProductFrom, where, orderBy, limit, offset),
+		args...,
+	)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product PublicProduct
+		if err := scanPublicProduct(rows, &product); err != nil {
+			return page, err
+		}
+
+		page.Rows = append(page.Rows, product)
+	}
+
+	return page, rows.Err()
+}
+
+// StoreRecentProducts returns the visible products added last.
+func (s *Service) StoreRecentProducts() ([]PublicProduct, error) {
+	page, err := s.StoreProducts("", "", "", "newest", storePageSize, 0)
+
+	return page.Rows, err
+}
+
+// StoreProduct returns the visible product with slug, with its gallery,
+// parameter values, variants, related products and answered questions.
+func (s *Service) StoreProduct(slug string) (PublicProduct, error) {
+	var (
+		product    PublicProduct
+		categoryID pgtype.UUID
+	)
+
+	ctx := context.Background()
+
+	err := scanPublicProduct(s.db.QueryRow(
+		ctx,
+		"SELECT"+storeProductColumns+", c.id"+storeProductFrom+" WHERE p.slug = $1 AND "+visibleProduct+" LIMIT 1",
+		slug,
+	), &product, &categoryID)
+	if err != nil {
+		return product, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    image_url,
+		    alt,
+		    variants
+		FROM
+		    images
+		WHERE
+		    product_id = $1
+		ORDER BY
+		    position,
+		    created_at`, product.ID)
+	if err != nil {
+		return product, err
+	}
+
+	for rows.Next() {
+		var image PublicImage
+		if err := rows.Scan(&image.Url, &image.Alt, &image.Variants); err != nil {
+			rows.Close()
+
+			return product, err
+		}
+
+		product.Images = append(product.Images, image)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return product, err
+	}
+
+	// Specs are named and ordered as the category of the product has its
+	// parameters, without those it hides.
+	rows, err = s.db.Query(ctx, `
+		SELECT
+		    COALESCE(cp.name, prm.name),
+		    COALESCE(ppv.selectable_value, ppv.text_value, ppv.bool_value::text)
+		FROM
+		    product_parameter_values ppv
+		    JOIN parameters prm ON ppv.parameter_id = prm.id
+		    LEFT JOIN category_parameters ($2) cp ON cp.id = prm.id
+		WHERE
+		    ppv.product_id = $1
+		    AND cp.hidden IS NOT TRUE
+		    AND COALESCE(ppv.selectable_value, ppv.text_value, ppv.bool_value::text) <> ''
+		ORDER BY
+		    COALESCE(cp.priority, prm.priority)::int`, product.ID, categoryID)
+	if err != nil {
+		return product, err
+	}
+
+	for rows.Next() {
+		var spec PublicSpec
+		if err := rows.Scan(&spec.Name, &spec.Value); err != nil {
+			rows.Close()
+
+			return product, err
+		}
+
+		product.Specs = append(product.Specs, spec)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return product, err
+	}
+
+	err = s.storeVariants(ctx, &product)
+	if err != nil {
+		return product, err
+	}
+
+	product.Related, err = s.storeRelations(ctx, product.ID)
+	if err != nil {
+		return product, err
+	}
+
+	product.Questions, err = s.storeQuestions(ctx, product.ID)
+	if err != nil {
+		return product, err
+	}
+
+	product.Contents, err = s.storeBundleItems(ctx, product.ID)
+	if err != nil {
+		return product, err
+	}
+
+	product.Breadcrumbs, err = s.breadcrumbs(ctx, categoryID)
+
+	return product, err
+}
+
+// storeVariants adds the shown variants of product and the values they
+// take on each axis, named as in its category. A variant without its own
+// price has the product's.
+func (s *Service) storeVariants(ctx context.Context, product *PublicProduct) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT
+		    COALESCE(cp.name, prm.name),
+		    ARRAY (
+		        SELECT
+		            selectable.value
+		        FROM
+		            unnest(COALESCE(cp.selectables, prm.selectables))
+		            WITH ORDINALITY AS selectable (value, ordinality)
+		        WHERE
+		            EXISTS (
+		                SELECT
+		                    1
+		                FROM
+		                    product_variants pv
+		                    JOIN product_variant_values pvv ON pvv.variant_id = pv.id
+		                WHERE
+		                    pv.product_id = pva.product_id
+		                    AND pv.show
+		                    AND pvv.parameter_id = prm.id
+		                    AND pvv.value = selectable.value)
+		        ORDER BY
+		            selectable.ordinality)
+		FROM
+		    product_variant_axes pva
+		    JOIN products p ON pva.product_id = p.id
+		    JOIN parameters prm ON pva.parameter_id = prm.id
+		    LEFT JOIN LATERAL category_parameters (p.category_id) cp ON cp.id = prm.id
+		WHERE
+		    pva.product_id = $1
+		ORDER BY
+		    pva.position`, product.ID)
+	if err != nil {
+		return err
+	}
+
+	product.Axes, err = pgx.CollectRows(rows, pgx.RowToStructByPos[PublicVariantAxis])
+	if err != nil || len(product.Axes) == 0 {
+		product.Axes = nil
+
+		return err
+	}
+
+	rows, err = s.db.Query(ctx, `
+		SELECT
+		    pv.id,
+		    COALESCE(pv.price, p.price),
+		    CASE WHEN pv.count ~ '^[0-9]+$' THEN
+		        pv.count::numeric > 0
+		    ELSE
+		        FALSE
+		    END,
+		    COALESCE((
+		        SELECT
+		            jsonb_object_agg(COALESCE(cp.name, prm.name), pvv.value)
+		        FROM product_variant_values pvv
+		        JOIN parameters prm ON pvv.parameter_id = prm.id
+		        LEFT JOIN LATERAL category_parameters (p.category_id) cp ON cp.id = prm.id
+		        WHERE
+		            pvv.variant_id = pv.id), '{}'::jsonb),
+		    i.image_url
+		FROM
+		    product_variants pv
+		    JOIN products p ON pv.product_id = p.id
+		    LEFT JOIN images i ON pv.image_id = i.id
+		WHERE
+		    pv.product_id = $1
+		    AND pv.show
+		ORDER BY
+		    pv.position,
+		    pv.created_at`, product.ID)
+	if err != nil {
+		return err
+	}
+
+	byID := map[pgtype.UUID]int{}
+
+	for rows.Next() {
+		variant := PublicVariant{Images: []PublicImage{}}
+		if err := rows.Scan(&variant.ID, &variant.Price, &variant.Available, &variant.Values, &variant.ImageUrl); err != nil {
+			rows.Close()
+
+			return err
+		}
+
+		byID[variant.ID] = len(product.Variants)
+		product.Variants = append(product.Variants, variant)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(ctx, `
+		SELECT
+		    i.variant_id,
+		    i.image_url,
+		    i.alt,
+		    i.variants
+		FROM
+		    images i
+		    JOIN product_variants pv ON i.variant_id = pv.id
+		WHERE
+		    pv.product_id = $1
+		    AND pv.show
+		ORDER BY
+		    i.position,
+		    i.created_at`, product.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			variantID pgtype.UUID
+			image     PublicImage
+		)
+
+		if err := rows.Scan(&variantID, &image.Url, &image.Alt, &image.Variants); err != nil {
+			return err
+		}
+
+		if index, ok := byID[variantID]; ok {
+			product.Variants[index].Images = append(product.Variants[index].Images, image)
+		}
+	}
+
+	return rows.Err()
+}
+
+const storeCategoryQuery = `
+	SELECT
+	    c.id,
+	    c.name,
+	    c.slug,
+	    c.description,
+	    i.image_url,
+	    c.parent_id
+	FROM
+	    categories c
+	    LEFT JOIN images i ON c.image_id = i.id
+	WHERE
+	    ` + visibleCategory
+
+func (s *Service) storeCategories(condition string, args ...any) ([]PublicCategory, error) {
+	rows, err := s.db.Query(
+		context.Background(),
+		storeCategoryQuery+" AND "+condition+" ORDER BY c.priority, c.name",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []PublicCategory{}
+
+	for rows.Next() {
+		var category PublicCategory
+		if err := rows.Scan(&category.ID, &category.Name, &category.Slug, &category.Description, &category.ImageUrl, &category.ParentID); err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// nestStoreCategories returns the categories with parentID, each with the
+// categories below it nested.
+func nestStoreCategories(categories []PublicCategory, parentID pgtype.UUID) []PublicCategory {
+	var nested []PublicCategory
+
+	for _, category := range categories {
+		if category.ParentID == parentID {
+			category.Children = nestStoreCategories(categories, category.ID)
+			nested = append(nested, category)
+		}
+	}
+
+	return nested
+}
+
+// StoreCategories returns the visible category tree: the top level
+// categories with the visible ones below them at any depth.
+func (s *Service) StoreCategories() ([]PublicCategory, error) {
+	categories, err := s.storeCategories("TRUE")
+	if err != nil {
+		return nil, err
+	}
+
+	tree := nestStoreCategories(categories, pgtype.UUID{})
+	if tree == nil {
+		tree = []PublicCategory{}
+	}
+
+	return tree, nil
+}
+
+// StoreCategory returns the visible category with slug, the visible ones
+// below it at any depth and its breadcrumbs.
+func (s *Service) StoreCategory(slug string) (PublicCategory, error) {
+	categories, err := s.storeCategories("c.slug = $1", slug)
+	if err != nil {
+		return PublicCategory{}, err
+	}
+
+	if len(categories) == 0 {
+		return PublicCategory{}, pgx.ErrNoRows
+	}
+
+	category := categories[0]
+
+	below, err := s.storeCategories("c.id IN (SELECT id FROM category_descendants ($1))", category.ID)
+	if err != nil {
+		return category, err
+	}
+
+	category.Children = nestStoreCategories(below, category.ID)
+
+	category.Breadcrumbs, err = s.breadcrumbs(context.Background(), category.ID)
+
+	return category, err
+}
+
+const storeArticleQuery = `
+	SELECT
+	    a.id,
+	    a.name,
+	    a.slug,
+	    a.description,
+	    i.image_url,
+	    a.keywords,
+	    a.created_at
+	FROM
+	    articles a
+	    LEFT JOIN categories c ON a.category_id = c.id
+	    LEFT JOIN images i ON a.image_id = i.id
+	WHERE (a.category_id IS NULL
+	    OR (` + visibleCategory + `))`
+
+// categoryBySlug is the category, out of the trash, with the slug in the
+// query parameter numbered param.
+func categoryBySlug(param int) string {
+	return fmt.Sprintf("(SELECT id FROM categories WHERE slug = $%d AND deleted_at IS NULL LIMIT 1)", param)
+}
+
+func scanPublicArticle(row pgx.Row, article *PublicArticle) error {
+	return row.Scan(
+		&article.ID,
+		&article.Name,
+		&article.Slug,
+		&article.Description,
+		&article.ImageUrl,
+		&article.Keywords,
+		&article.CreatedAt,
+	)
+}
+
+// StoreArticles returns the articles outside hidden categories, newest
+// first, optionally only those in the category with categorySlug or below
+// it.
+func (s *Service) StoreArticles(categorySlug string) ([]PublicArticle, error) {
+	query := storeArticleQuery
+
+	var args []any
+
+	if categorySlug != "" {
+		query += " AND " + inCategoryTree("a.category_id", categoryBySlug(1))
+		args = append(args, categorySlug)
+	}
+
+	rows, err := s.db.Query(context.Background(), query+" ORDER BY a.created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := []PublicArticle{}
+
+	for rows.Next() {
+		var article PublicArticle
+		if err := scanPublicArticle(rows, &article); err != nil {
+			return nil, err
+		}
+
+		articles = append(articles, article)
+	}
+
+	return articles, rows.Err()
+}
+
+// StoreArticle returns the article with slug unless its category is hidden.
+func (s *Service) StoreArticle(slug string) (PublicArticle, error) {
+	var article PublicArticle
+
+	err := scanPublicArticle(
+		s.db.QueryRow(context.Background(), storeArticleQuery+" AND a.slug = $1 LIMIT 1", slug),
+		&article,
+	)
+
+	return article, err
+}
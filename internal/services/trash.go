@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrNotInTrash       = errors.New("not found in trash")
+	ErrTrashedReference = errors.New("restore the referenced record first")
+	ErrRestoreConflict  = errors.New("another record has taken its place")
+)
+
+type trashReference struct {
+	column string
+	table  string
+}
+
+type trashResource struct {
+	table string
+	// name is the SQL expression shown for a row in the trash view.
+	name string
+	// references must not be in the trash when the row is restored.
+	references []trashReference
+	// children are deleted and restored together with the row.
+	children []trashReference
+}
+
+var trashResources = map[string]trashResource{
+	"products": {
+		table: "products",
+		name:  "t.name",
+		references: []trashReference{
+			{column: "category_id", table: "categories"},
+			{column: "brand_id", table: "brands"},
+			{column: "entity_id", table: "entities"},
+		},
+	},
+	"categories": {
+		table:      "categories",
+		name:       "t.name",
+		references: []trashReference{{column: "parent_id", table: "categories"}},
+	},
+	"entities": {
+		table:      "entities",
+		name:       "t.name",
+		references: []trashReference{{column: "parent_id", table: "entities"}},
+	},
+	"brands": {
+		table: "brands",
+		name:  "t.name",
+	},
+	"persons": {
+		table: "persons",
+		name:  "CONCAT(t.name, ' ', t.first_name)",
+	},
+	"invoices": {
+		table:      "invoices",
+		name:       "t.number::text",
+		references: []trashReference{{column: "person_id", table: "persons"}},
+		children:   []trashReference{{column: "invoice_id", table: "invoice_items"}},
+	},
+}
+
+func getTrashResource(resourceType string) (trashResource, error) {
+	resource, ok := trashResources[resourceType]
+	if !ok {
+		return resource, fmt.Errorf("no trash for %s", resourceType)
+	}
+
+	return resource, nil
+}
+
+// softDelete moves a row and its children to the trash. A row that is
+// missing or already in the trash is pgx.ErrNoRows.
+func (s *Service) softDelete(resourceType string, id string) error {
+	ctx := context.Background()
+
+	resource, err := getTrashResource(resourceType)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = trashRow(ctx, tx, resource, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// trashRow is softDelete in tx. The row and its children get the same
+// deleted_at, since now() is fixed for the transaction.
+func trashRow(ctx context.Context, tx pgx.Tx, resource trashResource, id any) error {
+	tag, err := tx.Exec(
+		ctx,
+		fmt.Sprintf("UPDATE %s SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", resource.table),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	for _, child := range resource.children {
+		_, err = tx.Exec(
+			ctx,
+			fmt.Sprintf("UPDATE %s SET deleted_at = now() WHERE %s = $1 AND deleted_at IS NULL", child.table, child.column),
+			id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestoreFromTrash brings back a row together with the children that were
+// deleted with it. It fails with ErrTrashedReference while a row it points
+// to, like the category of a product, is still in the trash, and with
+// ErrRestoreConflict when a live row has taken its name.
+func (s *Service) RestoreFromTrash(resourceType string, id string) error {
+	ctx := context.Background()
+
+	resource, err := getTrashResource(resourceType)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt pgtype.Timestamptz
+
+	err = tx.QueryRow(
+		ctx,
+		fmt.Sprintf("SELECT deleted_at FROM %s WHERE id = $1 FOR UPDATE", resource.table),
+		id,
+	).Scan(&deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !deletedAt.Valid) {
+		return ErrNotInTrash
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, reference := range resource.references {
+		var trashed bool
+
+		err = tx.QueryRow(ctx, fmt.Sprintf(`
+			SELECT
+			    EXISTS (
+			        SELECT
+			            1
+			        FROM
+			            %s t
+			            JOIN %s r ON r.id = t.%s
+			        WHERE
+			            t.id = $1
+			            AND r.deleted_at IS NOT NULL)`, resource.table, reference.table, reference.column), id).Scan(&trashed)
+		if err != nil {
+			return err
+		}
+
+		if trashed {
+			return fmt.Errorf("%w: %s", ErrTrashedReference, reference.table)
+		}
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = $1", resource.table),
+		id,
+	)
+
+	// Names are only unique out of the trash; a live row may have taken it.
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", ErrRestoreConflict, pgErr.ConstraintName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, child := range resource.children {
+		_, err = tx.Exec(
+			ctx,
+			fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE %s = $1 AND deleted_at = $2", child.table, child.column),
+			id,
+			deletedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PurgeFromTrash permanently deletes a row that is already in the trash.
+func (s *Service) PurgeFromTrash(resourceType string, id string) error {
+	resource, err := getTrashResource(resourceType)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(
+		context.Background(),
+		fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND deleted_at IS NOT NULL", resource.table),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotInTrash
+	}
+
+	return nil
+}
+
+func (s *Service) ListTrash(resourceType string, countInPage string, offset string, w http.ResponseWriter) {
+	resource, err := getTrashResource(resourceType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	pagedBy := ""
+	offsetNum := 0
+
+	if countInPage != "" {
+		limit, err := strconv.Atoi(countInPage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if offset != "" {
+			offsetNum, err = strconv.Atoi(offset)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		pagedBy = fmt.Sprintf(`LIMIT %d OFFSET %d`, limit, offsetNum)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+		    t.id,
+		    %s,
+		    t.deleted_at,
+		    to_jsonb(t) - 'fts'
+		FROM
+		    %s t
+		WHERE
+		    t.deleted_at IS NOT NULL
+		ORDER BY
+		    t.deleted_at DESC %s
+		`, resource.name, resource.table, pagedBy)
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+
+	for rows.Next() {
+		var item TrashItem
+		if err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt, &item.Data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		items = append(items, item)
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	row := s.db.QueryRow(
+		context.Background(),
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE deleted_at IS NOT NULL", resource.table),
+	)
+
+	var Count int32
+
+	err = row.Scan(&Count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	var itemsWithTotalCount struct {
+		Rows       []TrashItem `json:"rows"`
+		TotalCount int32       `json:"totalCount"`
+	}
+
+	itemsWithTotalCount.Rows = items
+	itemsWithTotalCount.TotalCount = Count
+
+	err = json.NewEncoder(w).Encode(itemsWithTotalCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+}
@@ -2,11 +2,19 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"golang.org/x/crypto/bcrypt"
 
@@ -68,7 +76,7 @@ WHERE token = $1;
 }
 
 func (s *Service) SetUserPassword(id pgtype.UUID, password string) error {
-	query := `UPDATE users SET password=$1,token='' WHERE id=$2;`
+	query := `UPDATE users SET password=$1,token='',password_reset_required=FALSE WHERE id=$2;`
 
 	cryptedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
 	if err != nil {
@@ -111,8 +119,11 @@ func (s *Service) EditUser(user User) error {
 	return nil
 }
 
+// CreateUser signs up a user and mails them a verification link. The
+// account stays created when the mail cannot be sent; the link can be sent
+// again through /resend_verification.
 func (s *Service) CreateUser(user User) error {
-	query := `INSERT INTO users (id,email,password) VALUES ($1,$2,$3)`
+	query := `INSERT INTO users (id,email,password,email_verified) VALUES ($1,$2,$3,FALSE)`
 	validate := utils.NewValidate()
 
 	err := validate.Struct(user)
@@ -120,6 +131,11 @@ func (s *Service) CreateUser(user User) error {
 		return err
 	}
 
+	err = validate.Var(user.Email.String, "required,email")
+	if err != nil {
+		return err
+	}
+
 	id := uuid.New()
 
 	cryptedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password.String), 10)
@@ -138,11 +154,16 @@ func (s *Service) CreateUser(user User) error {
 		return err
 	}
 
+	err = s.SendVerificationEmail(user.Email.String)
+	if err != nil {
+		log.Printf("verification email for user %s: %v", id, err)
+	}
+
 	return nil
 }
 
 func (s *Service) SignIn(user User) (string, error) {
-	query := `SELECT id,email,password,is_admin FROM users  WHERE email = $1`
+	query := `SELECT id,email,password,is_admin,email_verified,disabled,password_reset_required,session_version FROM users  WHERE email = $1`
 	validate := utils.NewValidate()
 
 	err := validate.Struct(user)
@@ -152,6 +173,8 @@ func (s *Service) SignIn(user User) (string, error) {
 
 	var databaseUser User
 
+	var emailVerified, disabled, passwordResetRequired pgtype.Bool
+
 	result := s.db.QueryRow(
 		context.Background(),
 		query,
@@ -163,6 +186,10 @@ func (s *Service) SignIn(user User) (string, error) {
 		&databaseUser.Email,
 		&databaseUser.Password,
 		&databaseUser.IsAdmin,
+		&emailVerified,
+		&disabled,
+		&passwordResetRequired,
+		&databaseUser.SessionVersion,
 	)
 	if err != nil {
 		return "", err
@@ -176,10 +203,30 @@ func (s *Service) SignIn(user User) (string, error) {
 		return "", err
 	}
 
+	if disabled.Bool {
+		return "", ErrUserDisabled
+	}
+
+	if passwordResetRequired.Bool {
+		return "", ErrPasswordResetRequired
+	}
+
+	if !emailVerified.Bool {
+		return "", ErrEmailNotVerified
+	}
+
+	return s.signToken(databaseUser)
+}
+
+// signToken issues the JWT stored in the "token" cookie for a user, whichever
+// way they signed in.
+func (s *Service) signToken(user User) (string, error) {
 	Claim := &utils.AuthClaims{
-		ID:      databaseUser.ID.String(),
-		Email:   databaseUser.Email.String,
-		IsAdmin: databaseUser.IsAdmin,
+		ID:             user.ID.String(),
+		Email:          user.Email.String,
+		PhoneNumber:    user.PhoneNumber.String,
+		IsAdmin:        user.IsAdmin,
+		SessionVersion: user.SessionVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 30 * 12 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -196,13 +243,367 @@ func (s *Service) SignIn(user User) (string, error) {
 	return tokenString, nil
 }
 
-func (s *Service) DeleteUser(id string) error {
-	query := "DELETE FROM parameters WHERE id=$1"
+var (
+	ErrLastAdmin             = errors.New("cannot remove the last active admin")
+	ErrUserDisabled          = errors.New("user is disabled")
+	ErrPasswordResetRequired = errors.New("password reset required")
+)
+
+func (s *Service) ListUsersWithSortFilterPagination(
+	sort string,
+	sortDirection string,
+	filters []string,
+	filterOperands []string,
+	filterConditions []string,
+	countInPage string,
+	offset string,
+	w http.ResponseWriter,
+) {
+	orderBy := ""
+
+	if sort != "" {
+		switch sort {
+		case "is_admin", "disabled", "email_verified", "created_at":
+			orderBy = fmt.Sprintf(
+				`ORDER BY users.%s %s`,
+				sort,
+				sortDirection,
+			)
+		default:
+			orderBy = fmt.Sprintf(
+				`ORDER BY users.%s COLLATE "fa-IR-x-icu" %s`,
+				sort,
+				sortDirection,
+			)
+		}
+	}
+
+	var filterBy strings.Builder
+	if len(filters) > 0 {
+		filterBy.WriteString("WHERE ")
+	}
+
+	for index, filter := range filters {
+		filterOperand := filterOperands[index]
+		filterCondition := filterConditions[index]
+
+		if filterOperand == "contains" {
+			filterOperand = "ILIKE"
+
+			filterCondition = "%" + filterCondition + "%"
+		}
+
+		if len(filters) != 0 {
+			filterBy.WriteString(fmt.Sprintf(
+				`%s %s '%s'`,
+				"users."+filter,
+				filterOperand,
+				filterCondition,
+			))
+		}
+
+		if len(filters)-1 > index {
+			filterBy.WriteString(" AND ")
+		}
+	}
+
+	pagedBy := ""
+	offsetNum := 0
+
+	if countInPage != "" {
+		limit, err := strconv.Atoi(countInPage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if offset != "" {
+			offsetNum, err = strconv.Atoi(offset)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		pagedBy = fmt.Sprintf(`LIMIT %d OFFSET %d`, limit, offsetNum)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+		    users.id,
+		    users.email,
+		    users.phone_number,
+		    users.is_admin,
+		    COALESCE(users.disabled, FALSE),
+		    COALESCE(users.email_verified, FALSE),
+		    COALESCE(users.password_reset_required, FALSE),
+		    users.created_at
+		FROM
+		    users
+		%s %s %s
+		`, filterBy.String(), orderBy, pagedBy)
+
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Email, &user.PhoneNumber, &user.IsAdmin, &user.Disabled, &user.EmailVerified, &user.PasswordResetRequired, &user.CreatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		users = append(users, user)
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	newQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM
+		    users
+		%s
+		`, filterBy.String())
+	row := s.db.QueryRow(context.Background(), newQuery)
+
+	var Count int32
+
+	err = row.Scan(&Count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	var usersWithTotalCount struct {
+		Rows       []User `json:"rows"`
+		TotalCount int32  `json:"totalCount"`
+	}
+
+	usersWithTotalCount.Rows = users
+	usersWithTotalCount.TotalCount = Count
+
+	err = json.NewEncoder(w).Encode(usersWithTotalCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Service) GetUserByID(id string) (User, error) {
+	var user User
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return user, err
+	}
+
+	query := `
+		SELECT
+		    id,
+		    email,
+		    phone_number,
+		    is_admin,
+		    COALESCE(disabled, FALSE),
+		    COALESCE(email_verified, FALSE),
+		    COALESCE(password_reset_required, FALSE),
+		    created_at
+		FROM
+		    users
+		WHERE
+		    id = $1`
+
+	err = s.db.QueryRow(context.Background(), query, parsedUUID).Scan(
+		&user.ID,
+		&user.Email,
+		&user.PhoneNumber,
+		&user.IsAdmin,
+		&user.Disabled,
+		&user.EmailVerified,
+		&user.PasswordResetRequired,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
 
-	_, err := s.db.Exec(context.Background(), query, id)
+// ensureNotLastAdmin fails when id is the only enabled admin left. Admin rows
+// are locked so two concurrent demotions cannot both pass the check.
+func ensureNotLastAdmin(ctx context.Context, tx pgx.Tx, id string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT
+		    id
+		FROM
+		    users
+		WHERE
+		    is_admin IS TRUE
+		    AND disabled IS NOT TRUE
+		FOR UPDATE`)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	others := 0
+	isAdmin := false
+
+	for rows.Next() {
+		var adminID pgtype.UUID
+		if err := rows.Scan(&adminID); err != nil {
+			return err
+		}
+
+		if adminID.String() == id {
+			isAdmin = true
+		} else {
+			others++
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if isAdmin && others == 0 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
+// updateUserGuarded runs query (with id as its last argument) after checking
+// that it does not leave the system without an admin.
+func (s *Service) updateUserGuarded(id string, query string, args ...any) error {
+	ctx := context.Background()
+
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = ensureNotLastAdmin(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, query, append(args, id)...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return tx.Commit(ctx)
+}
+
+// updateUser runs query with id and reports pgx.ErrNoRows when no user has
+// it. Changes that can take away the last admin use updateUserGuarded.
+func (s *Service) updateUser(id string, query string) error {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(context.Background(), query, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 
 	return nil
 }
+
+func (s *Service) SetUserRole(id string, isAdmin bool) error {
+	if isAdmin {
+		return s.updateUser(id, "UPDATE users SET is_admin=TRUE,updated_at=now() WHERE id=$1")
+	}
+
+	return s.updateUserGuarded(id, "UPDATE users SET is_admin=FALSE,updated_at=now() WHERE id=$1")
+}
+
+func (s *Service) SetUserDisabled(id string, disabled bool) error {
+	if !disabled {
+		return s.updateUser(id, "UPDATE users SET disabled=FALSE,updated_at=now() WHERE id=$1")
+	}
+
+	return s.updateUserGuarded(id, "UPDATE users SET disabled=TRUE,updated_at=now() WHERE id=$1")
+}
+
+// ForcePasswordReset signs the user out, blocks password sign-in until they
+// set a new password and mails them a reset link when they have an email
+// address.
+func (s *Service) ForcePasswordReset(id string) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		context.Background(),
+		"UPDATE users SET password_reset_required=TRUE,session_version=session_version+1,updated_at=now() WHERE id=$1",
+		user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if !user.Email.Valid || user.Email.String == "" {
+		return nil
+	}
+
+	return s.SendPasswordResetEmail(user.Email.String)
+}
+
+// UserSession reports whether the user behind a token still exists, is
+// enabled and has not been signed out since sessionVersion, and whether they
+// are still an admin.
+func (s *Service) UserSession(id string, sessionVersion int32) (bool, bool, error) {
+	var (
+		isAdmin        bool
+		disabled       bool
+		currentVersion int32
+	)
+
+	err := s.db.QueryRow(
+		context.Background(),
+		"SELECT is_admin,COALESCE(disabled,FALSE),session_version FROM users WHERE id=$1",
+		id,
+	).Scan(&isAdmin, &disabled, &currentVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+
+	if err != nil {
+		return false, false, err
+	}
+
+	return !disabled && sessionVersion == currentVersion, isAdmin, nil
+}
+
+func (s *Service) DeleteUser(id string) error {
+	return s.updateUserGuarded(id, "DELETE FROM users WHERE id=$1")
+}
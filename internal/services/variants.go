@@ -0,0 +1,512 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalidVariant   = errors.New("invalid variant")
+	ErrDuplicateVariant = errors.New("another variant of the product has these values")
+)
+
+// variantAxesQuery returns the axes of a product with the name and
+// selectables their parameters have in its category.
+const variantAxesQuery = `
+	SELECT
+	    prm.id,
+	    COALESCE(cp.name, prm.name),
+	    COALESCE(cp.selectables, prm.selectables, '{}')
+	FROM
+	    product_variant_axes pva
+	    JOIN products p ON pva.product_id = p.id
+	    JOIN parameters prm ON pva.parameter_id = prm.id
+	    LEFT JOIN LATERAL category_parameters (p.category_id) cp ON cp.id = prm.id
+	WHERE
+	    pva.product_id = $1
+	ORDER BY
+	    pva.position`
+
+const variantColumns = `
+	    pv.id,
+	    pv.product_id,
+	    pv.code,
+	    pv.price,
+	    pv.count,
+	    pv.show,
+	    pv.position,
+	    pv.image_id,
+	    ARRAY (
+	        SELECT
+	            i.id
+	        FROM
+	            images i
+	        WHERE
+	            i.variant_id = pv.id
+	        ORDER BY
+	            i.position,
+	            i.created_at),
+	    COALESCE((
+	        SELECT
+	            jsonb_object_agg(pvv.parameter_id, pvv.value)
+	        FROM product_variant_values pvv
+	        WHERE
+	            pvv.variant_id = pv.id), '{}'::jsonb),
+	    pv.created_at,
+	    pv.updated_at`
+
+func scanVariant(row pgx.Row, variant *Variant) error {
+	return row.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.Code,
+		&variant.Price,
+		&variant.Count,
+		&variant.Show,
+		&variant.Position,
+		&variant.ImageID,
+		&variant.ImageIDs,
+		&variant.Values,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	)
+}
+
+// lockVariantProduct locks the product a variant is saved under, so that
+// its axes and the values of its other variants stay as they were read.
+func lockVariantProduct(ctx context.Context, tx pgx.Tx, productID any) error {
+	var locked int
+
+	err := tx.QueryRow(ctx, "SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", productID).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: product not found", ErrInvalidVariant)
+	}
+
+	return err
+}
+
+// saveVariantValues checks that values give one of its selectables for
+// every axis of the product and no other parameter, and that no other
+// variant of the product has the same ones, then makes them the values of
+// the variant.
+func saveVariantValues(ctx context.Context, tx pgx.Tx, productID any, variantID uuid.UUID, values map[string]string) error {
+	rows, err := tx.Query(ctx, variantAxesQuery, productID)
+	if err != nil {
+		return err
+	}
+
+	axes, err := pgx.CollectRows(rows, pgx.RowToStructByPos[VariantAxis])
+	if err != nil {
+		return err
+	}
+
+	if len(axes) == 0 {
+		return fmt.Errorf("%w: the product has no variant axes", ErrInvalidVariant)
+	}
+
+	if len(values) != len(axes) {
+		return fmt.Errorf("%w: values must be given for the %d axes of the product and nothing else", ErrInvalidVariant, len(axes))
+	}
+
+	parameterIDs := make([]pgtype.UUID, 0, len(axes))
+	chosen := make([]string, 0, len(axes))
+
+	for _, axis := range axes {
+		value, ok := values[uuid.UUID(axis.ParameterID.Bytes).String()]
+		if !ok {
+			return fmt.Errorf("%w: %s is required", ErrInvalidVariant, axis.Name)
+		}
+
+		selectable := false
+
+		for _, option := range axis.Values {
+			if option.String == value {
+				selectable = true
+
+				break
+			}
+		}
+
+		if !selectable {
+			return fmt.Errorf("%w: %q is not a value of %s", ErrInvalidVariant, value, axis.Name)
+		}
+
+		parameterIDs = append(parameterIDs, axis.ParameterID)
+		chosen = append(chosen, value)
+	}
+
+	var duplicate bool
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            product_variants pv
+		        WHERE
+		            pv.product_id = $1
+		            AND pv.id <> $2
+		            AND NOT EXISTS (
+		                SELECT
+		                    1
+		                FROM
+		                    unnest($3::uuid[], $4::text[]) AS chosen (parameter_id, value)
+		                WHERE
+		                    NOT EXISTS (
+		                        SELECT
+		                            1
+		                        FROM
+		                            product_variant_values pvv
+		                        WHERE
+		                            pvv.variant_id = pv.id
+		                            AND pvv.parameter_id = chosen.parameter_id
+		                            AND pvv.value = chosen.value)))`,
+		productID, variantID, parameterIDs, chosen,
+	).Scan(&duplicate)
+	if err != nil {
+		return err
+	}
+
+	if duplicate {
+		return ErrDuplicateVariant
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM product_variant_values WHERE variant_id = $1", variantID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_variant_values (variant_id, parameter_id, value)
+		SELECT
+		    $1,
+		    chosen.parameter_id,
+		    chosen.value
+		FROM
+		    unnest($2::uuid[], $3::text[]) AS chosen (parameter_id, value)`,
+		variantID, parameterIDs, chosen,
+	)
+
+	return err
+}
+
+// ListVariants returns the variant axes of a product and its variants.
+func (s *Service) ListVariants(productID string) (ProductVariants, error) {
+	variants := ProductVariants{
+		Axes:     []VariantAxis{},
+		Variants: []Variant{},
+	}
+
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return variants, err
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+
+	err = s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", parsedUUID).Scan(&exists)
+	if err != nil {
+		return variants, err
+	}
+
+	if !exists {
+		return variants, pgx.ErrNoRows
+	}
+
+	rows, err := s.db.Query(ctx, variantAxesQuery, parsedUUID)
+	if err != nil {
+		return variants, err
+	}
+
+	variants.Axes, err = pgx.CollectRows(rows, pgx.RowToStructByPos[VariantAxis])
+	if err != nil {
+		return variants, err
+	}
+
+	rows, err = s.db.Query(ctx, "SELECT"+variantColumns+" FROM product_variants pv WHERE pv.product_id = $1 ORDER BY pv.position, pv.created_at", parsedUUID)
+	if err != nil {
+		return variants, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variant Variant
+		if err := scanVariant(rows, &variant); err != nil {
+			return variants, err
+		}
+
+		variants.Variants = append(variants.Variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// SetVariantAxes makes the parameters of axes, in this order, the axes the
+// variants of a product differ in. They must be parameters its category
+// shows that have selectables there. Axes can only be added
+// while the product has no variants; the values of removed ones are
+// deleted, as long as the variants stay distinct without them.
+func (s *Service) SetVariantAxes(productID string, axes VariantAxes) error {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return err
+	}
+
+	if axes.ParameterIDs == nil {
+		axes.ParameterIDs = []pgtype.UUID{}
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockVariantProduct(ctx, tx, parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	var selectable, added int
+
+	var hasVariants bool
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    (
+		        SELECT
+		            count(*)
+		        FROM
+		            products p
+		            CROSS JOIN LATERAL category_parameters (p.category_id) cp
+		        WHERE
+		            p.id = $1
+		            AND cp.id = ANY ($2)
+		            AND NOT cp.hidden
+		            AND cardinality(cp.selectables) > 0),
+		    (
+		        SELECT
+		            count(*)
+		        FROM
+		            unnest($2::uuid[]) AS axis (parameter_id)
+		        WHERE
+		            NOT EXISTS (
+		                SELECT
+		                    1
+		                FROM
+		                    product_variant_axes pva
+		                WHERE
+		                    pva.product_id = $1
+		                    AND pva.parameter_id = axis.parameter_id)),
+		    EXISTS (
+		        SELECT
+		            1
+		        FROM
+		            product_variants
+		        WHERE
+		            product_id = $1)`, parsedUUID, axes.ParameterIDs).Scan(&selectable, &added, &hasVariants)
+	if err != nil {
+		return err
+	}
+
+	if selectable != len(axes.ParameterIDs) {
+		return fmt.Errorf("%w: axes must be distinct parameters of the product category with selectables", ErrInvalidVariant)
+	}
+
+	if added > 0 && hasVariants {
+		return fmt.Errorf("%w: axes cannot be added to a product that has variants", ErrInvalidVariant)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM product_variant_values pvv USING product_variants pv
+		WHERE pvv.variant_id = pv.id
+		    AND pv.product_id = $1
+		    AND NOT pvv.parameter_id = ANY ($2)`, parsedUUID, axes.ParameterIDs)
+	if err != nil {
+		return err
+	}
+
+	var duplicates bool
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+		    count(*) <> count(DISTINCT combination)
+		FROM (
+		    SELECT
+		        COALESCE((
+		            SELECT
+		                string_agg(pvv.parameter_id || '=' || pvv.value, ',' ORDER BY pvv.parameter_id)
+		            FROM product_variant_values pvv
+		            WHERE
+		                pvv.variant_id = pv.id), '') AS combination
+		    FROM
+		        product_variants pv
+		    WHERE
+		        pv.product_id = $1) variants`, parsedUUID).Scan(&duplicates)
+	if err != nil {
+		return err
+	}
+
+	if duplicates {
+		return ErrDuplicateVariant
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM product_variant_axes WHERE product_id = $1", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_variant_axes (product_id, parameter_id, position)
+		SELECT
+		    $1,
+		    ordered.parameter_id,
+		    ordered.ordinality - 1
+		FROM
+		    unnest($2::uuid[])
+		    WITH ORDINALITY AS ordered (parameter_id, ordinality)`, parsedUUID, axes.ParameterIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *Service) GetVariant(id string) (Variant, error) {
+	var variant Variant
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return variant, err
+	}
+
+	err = scanVariant(
+		s.db.QueryRow(context.Background(), "SELECT"+variantColumns+" FROM product_variants pv WHERE pv.id = $1", parsedUUID),
+		&variant,
+	)
+
+	return variant, err
+}
+
+// CreateVariant adds a variant under variant.ProductID with its values and
+// gallery. A variant is shown unless Show is false.
+func (s *Service) CreateVariant(variant Variant) (uuid.UUID, error) {
+	if !variant.ProductID.Valid {
+		return uuid.Nil, fmt.Errorf("%w: productId is required", ErrInvalidVariant)
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockVariantProduct(ctx, tx, variant.ProductID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id := uuid.New()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_variants (id, product_id, code, price, count, show, position, image_id)
+		    VALUES ($1, $2, $3, $4, $5, COALESCE($6, TRUE), $7, $8)`,
+		id,
+		variant.ProductID,
+		variant.Code,
+		variant.Price,
+		variant.Count,
+		variant.Show,
+		variant.Position,
+		variant.ImageID,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = saveVariantValues(ctx, tx, variant.ProductID, id, variant.Values)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if variant.ImageIDs != nil {
+		err = setGallery(ctx, tx, "product_variants", id, variant.ImageIDs)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+// patchVariantRelated replaces the gallery when imageIds is given and the
+// values of the variant when values is.
+func patchVariantRelated(ctx context.Context, tx pgx.Tx, id uuid.UUID, patch map[string]json.RawMessage) error {
+	if raw, ok := patch["imageIds"]; ok {
+		var imageIDs []pgtype.UUID
+		if err := json.Unmarshal(raw, &imageIDs); err != nil {
+			return fmt.Errorf("imageIds: %w", err)
+		}
+
+		if err := setGallery(ctx, tx, "product_variants", id, imageIDs); err != nil {
+			return err
+		}
+	}
+
+	raw, ok := patch["values"]
+	if !ok {
+		return nil
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return fmt.Errorf("values: %w", err)
+	}
+
+	var productID pgtype.UUID
+
+	err := tx.QueryRow(ctx, "SELECT product_id FROM product_variants WHERE id = $1", id).Scan(&productID)
+	if err != nil {
+		return err
+	}
+
+	err = lockVariantProduct(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+
+	return saveVariantValues(ctx, tx, productID, id, values)
+}
+
+// DeleteVariant deletes a variant and its values; its images are detached
+// and left to the media garbage collector.
+func (s *Service) DeleteVariant(id string) error {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.db.Exec(context.Background(), "DELETE FROM product_variants WHERE id = $1", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
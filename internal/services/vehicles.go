@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalidVehicle     = errors.New("invalid vehicle")
+	ErrInvalidFitment     = errors.New("categoryId and vehicleIds are required")
+	ErrVehicleHasChildren = errors.New("delete the vehicles under this one first")
+)
+
+// vehicleKinds are the levels of the vehicle catalog from the top; each
+// vehicle belongs to one of the level above, which the database checks.
+var vehicleKinds = []string{"make", "model", "generation", "trim"}
+
+// fitsVehicle matches the products aliased as alias that fit the vehicle
+// given by the SQL expression vehicle, as vehicle_lineage defines it.
+func fitsVehicle(alias string, vehicle string) string {
+	return fmt.Sprintf(`EXISTS (
+    SELECT
+        1
+    FROM
+        product_fitments pf
+    WHERE
+        pf.product_id = %s.id
+        AND pf.vehicle_id IN (
+            SELECT
+                vehicle_lineage (%s)))`, alias, vehicle)
+}
+
+const vehicleColumns = `
+	    v.id,
+	    v.parent_id,
+	    v.kind,
+	    v.name,
+	    v.slug,
+	    v.year_from,
+	    v.year_to,
+	    v.created_at,
+	    v.updated_at`
+
+func scanVehicle(row pgx.Row, vehicle *Vehicle, extra ...any) error {
+	return row.Scan(append([]any{
+		&vehicle.ID,
+		&vehicle.ParentID,
+		&vehicle.Kind,
+		&vehicle.Name,
+		&vehicle.Slug,
+		&vehicle.YearFrom,
+		&vehicle.YearTo,
+		&vehicle.CreatedAt,
+		&vehicle.UpdatedAt,
+	}, extra...)...)
+}
+
+// vehicleTree nests the vehicles under parentID, keyed by parent.
+func vehicleTree(byParent map[pgtype.UUID][]Vehicle, parentID pgtype.UUID) []Vehicle {
+	children := byParent[parentID]
+
+	for index := range children {
+		children[index].Children = vehicleTree(byParent, children[index].ID)
+	}
+
+	return children
+}
+
+// ListVehicleTree returns the makes with their models, generations and
+// trims nested under them. Generations are in the order of their years.
+func (s *Service) ListVehicleTree() ([]Vehicle, error) {
+	rows, err := s.db.Query(context.Background(), `
+		SELECT`+vehicleColumns+`
+		FROM
+		    vehicles v
+		ORDER BY
+		    v.year_from NULLS FIRST,
+		    v.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byParent := map[pgtype.UUID][]Vehicle{}
+
+	for rows.Next() {
+		var vehicle Vehicle
+		if err := scanVehicle(rows, &vehicle); err != nil {
+			return nil, err
+		}
+
+		byParent[vehicle.ParentID] = append(byParent[vehicle.ParentID], vehicle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	makes := vehicleTree(byParent, pgtype.UUID{})
+	if makes == nil {
+		makes = []Vehicle{}
+	}
+
+	return makes, nil
+}
+
+func (s *Service) GetVehicle(id string) (Vehicle, error) {
+	var vehicle Vehicle
+
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return vehicle, err
+	}
+
+	err = scanVehicle(
+		s.db.QueryRow(context.Background(), "SELECT"+vehicleColumns+" FROM vehicles v WHERE v.id = $1", parsedUUID),
+		&vehicle,
+	)
+
+	return vehicle, err
+}
+
+func (s *Service) CreateVehicle(vehicle Vehicle) (uuid.UUID, error) {
+	if !slices.Contains(vehicleKinds, vehicle.Kind) {
+		return uuid.Nil, fmt.Errorf("%w: kind must be one of %s", ErrInvalidVehicle, strings.Join(vehicleKinds, ", "))
+	}
+
+	if strings.TrimSpace(vehicle.Name) == "" {
+		return uuid.Nil, fmt.Errorf("%w: name is required", ErrInvalidVehicle)
+	}
+
+	id := uuid.New()
+
+	_, err := s.db.Exec(context.Background(), `
+		INSERT INTO vehicles (id, parent_id, kind, name, slug, year_from, year_to)
+		    VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		id,
+		vehicle.ParentID,
+		vehicle.Kind,
+		strings.TrimSpace(vehicle.Name),
+		vehicle.Slug,
+		vehicle.YearFrom,
+		vehicle.YearTo,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// DeleteVehicle deletes a vehicle without children, and its fitments.
+func (s *Service) DeleteVehicle(id string) error {
+	parsedUUID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	var hasChildren bool
+
+	err = s.db.QueryRow(
+		context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM vehicles WHERE parent_id = $1)",
+		parsedUUID,
+	).Scan(&hasChildren)
+	if err != nil {
+		return err
+	}
+
+	if hasChildren {
+		return ErrVehicleHasChildren
+	}
+
+	tag, err := s.db.Exec(context.Background(), "DELETE FROM vehicles WHERE id = $1", parsedUUID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetFitment returns the vehicles a product fits.
+func (s *Service) GetFitment(productID string) (Fitment, error) {
+	fitment := Fitment{
+		VehicleIDs: []pgtype.UUID{},
+		Vehicles:   []Vehicle{},
+	}
+
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return fitment, err
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+
+	err = s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", parsedUUID).Scan(&exists)
+	if err != nil {
+		return fitment, err
+	}
+
+	if !exists {
+		return fitment, pgx.ErrNoRows
+	}
+
+	rows, err := s.db.Query(ctx, `
+		WITH RECURSIVE lineage AS (
+		    SELECT
+		        pf.vehicle_id,
+		        v.parent_id,
+		        v.name AS path
+		    FROM
+		        product_fitments pf
+		        JOIN vehicles v ON v.id = pf.vehicle_id
+		    WHERE
+		        pf.product_id = $1
+		    UNION ALL
+		    SELECT
+		        l.vehicle_id,
+		        p.parent_id,
+		        p.name || ' › ' || l.path
+		    FROM
+		        lineage l
+		        JOIN vehicles p ON p.id = l.parent_id
+		)
+		SELECT`+vehicleColumns+`,
+		    l.path
+		FROM
+		    lineage l
+		    JOIN vehicles v ON v.id = l.vehicle_id
+		WHERE
+		    l.parent_id IS NULL
+		ORDER BY
+		    l.path`, parsedUUID)
+	if err != nil {
+		return fitment, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vehicle Vehicle
+		if err := scanVehicle(rows, &vehicle, &vehicle.Path); err != nil {
+			return fitment, err
+		}
+
+		fitment.VehicleIDs = append(fitment.VehicleIDs, vehicle.ID)
+		fitment.Vehicles = append(fitment.Vehicles, vehicle)
+	}
+
+	return fitment, rows.Err()
+}
+
+// SetFitment makes fitment.VehicleIDs the vehicles a product fits.
+func (s *Service) SetFitment(productID string, fitment Fitment) error {
+	parsedUUID, err := uuid.Parse(productID)
+	if err != nil {
+		return err
+	}
+
+	if fitment.VehicleIDs == nil {
+		fitment.VehicleIDs = []pgtype.UUID{}
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int
+
+	err = tx.QueryRow(ctx, "SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", parsedUUID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM product_fitments
+		WHERE product_id = $1
+		    AND NOT vehicle_id = ANY ($2)`, parsedUUID, fitment.VehicleIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_fitments (product_id, vehicle_id)
+		SELECT
+		    $1,
+		    unnest($2::uuid[])
+		ON CONFLICT
+		    DO NOTHING`, parsedUUID, fitment.VehicleIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// BulkFitCategory adds the vehicles of bulk to the fitment of every product
+// in its category and the categories below it at any depth, or takes them
+// away with Remove. It returns how many fitments were added or removed.
+func (s *Service) BulkFitCategory(bulk BulkFitment) (int64, error) {
+	if !bulk.CategoryID.Valid || len(bulk.VehicleIDs) == 0 {
+		return 0, ErrInvalidFitment
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+
+	err := s.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)", bulk.CategoryID).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, pgx.ErrNoRows
+	}
+
+	query := `
+		INSERT INTO product_fitments (product_id, vehicle_id)
+		SELECT
+		    p.id,
+		    fitted.vehicle_id
+		FROM
+		    products p
+		    CROSS JOIN unnest($2::uuid[]) AS fitted (vehicle_id)
+		WHERE
+		    p.deleted_at IS NULL
+		    AND ` + inCategoryTree("p.category_id", "$1") + `
+		ON CONFLICT
+		    DO NOTHING`
+
+	if bulk.Remove {
+		query = `
+			DELETE FROM product_fitments pf USING products p
+			WHERE pf.product_id = p.id
+			    AND pf.vehicle_id = ANY ($2)
+			    AND p.deleted_at IS NULL
+			    AND ` + inCategoryTree("p.category_id", "$1")
+	}
+
+	tag, err := s.db.Exec(ctx, query, bulk.CategoryID, bulk.VehicleIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
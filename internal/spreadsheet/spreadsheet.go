@@ -0,0 +1,251 @@
+// Package spreadsheet reads the first sheet of CSV and XLSX files as rows
+// of strings and streams rows out as CSV, XLSX or JSON. XLSX is handled
+// with archive/zip and encoding/xml only, so formatting, formulas and all
+// but the first sheet are ignored.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupportedFormat = errors.New("only CSV and XLSX files are accepted")
+
+// utf8BOM starts CSV files saved by Excel.
+const utf8BOM = "\uFEFF"
+
+// Read parses data as XLSX when it is a zip archive and as CSV when name
+// ends in .csv or the content is plain text.
+func Read(data []byte, name string) ([][]string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return ReadXLSX(data)
+	case strings.EqualFold(path.Ext(name), ".csv"), !bytes.ContainsRune(data, 0):
+		return ReadCSV(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// ReadCSV parses comma separated rows. Rows may differ in length.
+func ReadCSV(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte(utf8BOM))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return reader.ReadAll()
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is either a plain <t> or rich text split into <r><t> runs.
+type xlsxText struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (t xlsxText) String() string {
+	return t.Text + strings.Join(t.Runs, "")
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXML(files map[string]*zip.File, name string, v any) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx: missing %s", name)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return xml.NewDecoder(reader).Decode(v)
+}
+
+// firstSheet finds the part of the first sheet listed in the workbook.
+func firstSheet(files map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbook
+
+	err := readXML(files, "xl/workbook.xml", &workbook)
+	if err != nil {
+		return "", err
+	}
+
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("xlsx: workbook has no sheets")
+	}
+
+	var relationships xlsxRelationships
+
+	err = readXML(files, "xl/_rels/workbook.xml.rels", &relationships)
+	if err != nil {
+		return "", err
+	}
+
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID != workbook.Sheets[0].ID {
+			continue
+		}
+
+		if strings.HasPrefix(relationship.Target, "/") {
+			return strings.TrimPrefix(relationship.Target, "/"), nil
+		}
+
+		return path.Join("xl", relationship.Target), nil
+	}
+
+	return "", errors.New("xlsx: first sheet not found")
+}
+
+// columnIndex turns the letters of a cell reference like "AB12" into a
+// zero based column number.
+func columnIndex(ref string) int {
+	index := 0
+
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+
+		index = index*26 + int(r-'A') + 1
+	}
+
+	return index - 1
+}
+
+// ReadXLSX returns the cell values of the first sheet, one row per sheet
+// row including empty ones. Numbers are written without exponent and
+// trailing zeros, booleans as TRUE or FALSE.
+func ReadXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetName, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var sharedStrings xlsxSharedStrings
+
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		err = readXML(files, "xl/sharedStrings.xml", &sharedStrings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxSheet
+
+	err = readXML(files, sheetName, &sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+
+	for _, sheetRow := range sheet.Rows {
+		// Empty rows are left out of the sheet; keep row numbers intact.
+		for sheetRow.Number > len(rows)+1 {
+			rows = append(rows, nil)
+		}
+
+		var row []string
+
+		for position, cell := range sheetRow.Cells {
+			column := position
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+			}
+
+			if column < 0 || column < len(row) {
+				continue
+			}
+
+			for len(row) < column {
+				row = append(row, "")
+			}
+
+			value, err := cellValue(cell.Type, cell.Value, cell.Inline, sharedStrings.Items)
+			if err != nil {
+				return nil, fmt.Errorf("xlsx: cell %s: %w", cell.Ref, err)
+			}
+
+			row = append(row, value)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func cellValue(cellType string, value string, inline xlsxText, sharedStrings []xlsxText) (string, error) {
+	switch cellType {
+	case "s":
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(sharedStrings) {
+			return "", errors.New("invalid shared string")
+		}
+
+		return sharedStrings[index].String(), nil
+	case "inlineStr":
+		return inline.String(), nil
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+
+		return "FALSE", nil
+	case "", "n":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value, nil
+		}
+
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	default:
+		return value, nil
+	}
+}
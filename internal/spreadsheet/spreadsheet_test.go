@@ -0,0 +1,129 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var exportRows = [][]string{
+	{"code", "name", "price"},
+	{"0012", "روغن <موتور> & فیلتر", "1250000"},
+	{"13", "", "-2.5"},
+}
+
+func writeRows(t *testing.T, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range exportRows {
+		err = writer.WriteRow(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, format := range []string{"csv", "xlsx"} {
+		rows, err := Read(writeRows(t, format), "export."+format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if !reflect.DeepEqual(rows, exportRows) {
+			t.Fatalf("%s: got %q\nwant %q", format, rows, exportRows)
+		}
+	}
+
+	want := `[
+{"code":"0012","name":"روغن \u003cموتور\u003e \u0026 فیلتر","price":"1250000"},
+{"code":"13","name":"","price":"-2.5"}
+]
+`
+	if got := string(writeRows(t, "json")); got != want {
+		t.Fatalf("json: got %s", got)
+	}
+}
+
+func xlsxFile(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	archive := zip.NewWriter(&buf)
+
+	for name, content := range parts {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writer.Write([]byte(content))
+	}
+
+	err := archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := xlsxFile(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Products" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>name</t></si><si><t>price</t></si><si><r><t>روغن </t></r><r><t>موتور</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+			<row r="3"><c r="A3" t="s"><v>2</v></c><c r="C3"><v>1.2E+6</v></c></row>
+			<row r="4"><c r="B4" t="inlineStr"><is><t>x</t></is></c><c r="C4" t="b"><v>1</v></c></row>
+		</sheetData></worksheet>`,
+	})
+
+	rows, err := Read(data, "products.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"name", "price"},
+		nil,
+		{"روغن موتور", "", "1200000"},
+		{"", "x", "TRUE"},
+	}
+
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %q\nwant %q", rows, want)
+	}
+}
+
+func TestReadCSVWithBOM(t *testing.T) {
+	rows, err := Read([]byte("\uFEFFname,price\nفیلتر,\"1,500\"\n"), "products.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"name", "price"}, {"فیلتر", "1,500"}}
+
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %q, want %q", rows, want)
+	}
+}
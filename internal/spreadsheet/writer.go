@@ -0,0 +1,266 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var ErrUnknownFormat = errors.New("format must be csv, xlsx or json")
+
+// Writer streams rows to a file. The first row is the header. Nothing is
+// written to the underlying writer before the first row, so a caller can
+// still answer with an error until then.
+type Writer interface {
+	WriteRow(row []string) error
+	// Close finishes the file; it does not close the underlying writer.
+	Close() error
+}
+
+// ContentType returns the MIME type of files written in format.
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/json"
+	}
+}
+
+// NewWriter returns a writer for format: csv, xlsx, or json for an array
+// of objects keyed by the header.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return &csvWriter{w: w}, nil
+	case "xlsx":
+		return &xlsxWriter{w: w}, nil
+	case "json":
+		return &jsonWriter{w: bufio.NewWriter(w)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvWriter struct {
+	w      io.Writer
+	writer *csv.Writer
+}
+
+// start writes a byte order mark so Excel reads the file as UTF-8 instead
+// of the system code page.
+func (c *csvWriter) start() error {
+	if c.writer != nil {
+		return nil
+	}
+
+	c.writer = csv.NewWriter(c.w)
+
+	_, err := io.WriteString(c.w, utf8BOM)
+
+	return err
+}
+
+func (c *csvWriter) WriteRow(row []string) error {
+	err := c.start()
+	if err != nil {
+		return err
+	}
+
+	return c.writer.Write(row)
+}
+
+func (c *csvWriter) Close() error {
+	err := c.start()
+	if err != nil {
+		return err
+	}
+
+	c.writer.Flush()
+
+	return c.writer.Error()
+}
+
+type jsonWriter struct {
+	w      *bufio.Writer
+	header []string
+	rows   int
+}
+
+func (j *jsonWriter) WriteRow(row []string) error {
+	if j.header == nil {
+		j.header = row
+
+		return nil
+	}
+
+	separator := ",\n"
+	if j.rows == 0 {
+		separator = "[\n"
+	}
+
+	j.rows++
+
+	_, err := j.w.WriteString(separator + "{")
+	if err != nil {
+		return err
+	}
+
+	// Written by hand to keep the column order of the header.
+	for index, key := range j.header {
+		value := ""
+		if index < len(row) {
+			value = row[index]
+		}
+
+		if index > 0 {
+			j.w.WriteByte(',')
+		}
+
+		encodedKey, _ := json.Marshal(key)
+		encodedValue, _ := json.Marshal(value)
+
+		j.w.Write(encodedKey)
+		j.w.WriteByte(':')
+		j.w.Write(encodedValue)
+	}
+
+	return j.w.WriteByte('}')
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if j.rows == 0 {
+		closing = "[]\n"
+	}
+
+	_, err := j.w.WriteString(closing)
+	if err != nil {
+		return err
+	}
+
+	return j.w.Flush()
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	// The sheet is shown right to left, as the catalog is in Persian.
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0" rightToLeft="1"/></sheetViews><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxNumber matches values written as numbers. Leading zeros, as in
+// product codes, keep a value text.
+var xlsxNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,14})(\.[0-9]+)?$`)
+
+// xlsxWriter streams a single sheet. Cells are written inline rather than
+// as shared strings so nothing has to be kept until Close.
+type xlsxWriter struct {
+	w       io.Writer
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	rows    int
+}
+
+func (x *xlsxWriter) start() error {
+	if x.archive != nil {
+		return nil
+	}
+
+	x.archive = zip.NewWriter(x.w)
+
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRelationships},
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	} {
+		writer, err := x.archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(writer, part.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	sheet, err := x.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	x.sheet = bufio.NewWriter(sheet)
+
+	_, err = x.sheet.WriteString(xlsxSheetStart)
+
+	return err
+}
+
+func (x *xlsxWriter) WriteRow(row []string) error {
+	err := x.start()
+	if err != nil {
+		return err
+	}
+
+	x.rows++
+
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+
+	for _, value := range row {
+		if xlsxNumber.MatchString(value) {
+			fmt.Fprintf(x.sheet, `<c><v>%s</v></c>`, value)
+
+			continue
+		}
+
+		x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+
+		err = xml.EscapeText(x.sheet, []byte(value))
+		if err != nil {
+			return err
+		}
+
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+
+	_, err = x.sheet.WriteString(`</row>`)
+
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	err := x.start()
+	if err != nil {
+		return err
+	}
+
+	_, err = x.sheet.WriteString(xlsxSheetEnd)
+	if err != nil {
+		return err
+	}
+
+	err = x.sheet.Flush()
+	if err != nil {
+		return err
+	}
+
+	return x.archive.Close()
+}
@@ -1,12 +1,101 @@
 package utils
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/gomail.v2"
 )
 
+// Mail is a rendered transactional email with HTML and plain-text parts.
+type Mail struct {
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers transactional emails.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// SMTPMailer sends mails through the SMTP server configured by the SMTP_*
+// environment variables.
+type SMTPMailer struct {
+	From    string
+	CcsAddr string
+	CcsName string
+}
+
+func (m *SMTPMailer) Send(mail Mail) error {
+	return SendMail(m.From, mail.To, mail.Subject, m.CcsAddr, m.CcsName, mail.HTML, mail.Text)
+}
+
+// FileMailer writes each mail to Dir as an .eml file instead of sending it,
+// for local development.
+type FileMailer struct {
+	From string
+	Dir  string
+}
+
+func (m *FileMailer) Send(mail Mail) error {
+	err := os.MkdirAll(m.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	suffix, err := RandomString(6)
+	if err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(
+		m.Dir,
+		fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), suffix),
+	)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(mail.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mail.Subject)
+	b.WriteString("\r\n--- text/plain ---\r\n")
+	b.WriteString(mail.Text)
+	b.WriteString("\r\n--- text/html ---\r\n")
+	b.WriteString(mail.HTML)
+
+	return os.WriteFile(fileName, []byte(b.String()), 0o644)
+}
+
+// NewMailer picks the mailer from MAILER ("smtp" or "file"), using MAIL_FROM
+// as the sender and, for SMTP, MAIL_CC_ADDR/MAIL_CC_NAME as an optional copy.
+func NewMailer() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "info@caroptionshop.ir"
+	}
+
+	if os.Getenv("MAILER") == "file" {
+		dir := os.Getenv("MAIL_OUTPUT_DIR")
+		if dir == "" {
+			dir = "./tmp/mails"
+		}
+
+		return &FileMailer{From: from, Dir: dir}
+	}
+
+	return &SMTPMailer{
+		From:    from,
+		CcsAddr: os.Getenv("MAIL_CC_ADDR"),
+		CcsName: os.Getenv("MAIL_CC_NAME"),
+	}
+}
+
 func SendMail(
 	from string,
 	addrs []string,
@@ -14,13 +103,24 @@ func SendMail(
 	ccsAddr string,
 	ccsName string,
 	htmlMessage string,
+	textMessage string,
 ) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", from)
 	m.SetHeader("To", addrs...)
-	m.SetAddressHeader("Cc", ccsAddr, ccsName)
+
+	if ccsAddr != "" {
+		m.SetAddressHeader("Cc", ccsAddr, ccsName)
+	}
+
 	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", htmlMessage)
+
+	if textMessage != "" {
+		m.SetBody("text/plain", textMessage)
+		m.AddAlternative("text/html", htmlMessage)
+	} else {
+		m.SetBody("text/html", htmlMessage)
+	}
 
 	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
 	if err != nil {
@@ -34,7 +134,6 @@ func SendMail(
 		os.Getenv("SMTP_PASSWORD"),
 	)
 
-	// Send the email to Bob, Cora and Dan.
 	if err := d.DialAndSend(m); err != nil {
 		return err
 	}
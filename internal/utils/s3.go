@@ -0,0 +1,430 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm        = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	s3TimeFormat       = "20060102T150405Z"
+	s3ShortTimeFormat  = "20060102"
+	s3MaxSignedExpires = 7 * 24 * time.Hour
+	// s3Timeout bounds a whole request, including reading the body, so a
+	// stalled server cannot hold an upload or listing forever.
+	s3Timeout = time.Minute
+)
+
+// S3Storage keeps files in a bucket of S3 or a compatible server such as
+// MinIO. Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	// Endpoint is the server URL, e.g. https://s3.amazonaws.com or
+	// http://localhost:9000.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as endpoint/bucket instead of
+	// bucket.endpoint, which MinIO and most stand-ins need.
+	PathStyle bool
+
+	// Client sends the requests; it should have a timeout. Without one,
+	// a client with s3Timeout is used.
+	Client *http.Client
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// s3Client is used by S3Storage values made without a Client.
+var s3Client = &http.Client{Timeout: s3Timeout}
+
+func (s *S3Storage) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	return s3Client
+}
+
+func (s *S3Storage) time() time.Time {
+	if s.now != nil {
+		return s.now().UTC()
+	}
+
+	return time.Now().UTC()
+}
+
+func (s *S3Storage) objectURL(name string) (*url.URL, error) {
+	err := checkFileName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.bucketURL(name)
+}
+
+// bucketURL addresses the bucket itself when key is empty.
+func (s *S3Storage) bucketURL(key string) (*url.URL, error) {
+	bucketURL, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.PathStyle {
+		bucketURL.Path = "/" + s.Bucket + "/" + key
+	} else {
+		bucketURL.Host = s.Bucket + "." + bucketURL.Host
+		bucketURL.Path = "/" + key
+	}
+
+	return bucketURL, nil
+}
+
+func (s *S3Storage) do(method string, name string, body []byte, header http.Header) (*http.Response, error) {
+	objectURL, err := s.objectURL(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.send(method, objectURL, body, header)
+}
+
+func (s *S3Storage) send(method string, objectURL *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequest(method, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	payloadHash := sha256.Sum256(body)
+	s.sign(request, hex.EncodeToString(payloadHash[:]))
+
+	return s.client().Do(request)
+}
+
+func s3Error(response *http.Response) error {
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+	return fmt.Errorf("s3: %s: %s", response.Status, message)
+}
+
+func objectInfo(name string, header http.Header) ObjectInfo {
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+
+	return ObjectInfo{
+		Name:         name,
+		Size:         size,
+		ContentType:  header.Get("Content-Type"),
+		LastModified: lastModified,
+		ETag:         header.Get("ETag"),
+	}
+}
+
+func (s *S3Storage) Put(name string, content []byte, contentType string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+
+	response, err := s.do(http.MethodPut, name, content, header)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return s3Error(response)
+	}
+
+	return response.Body.Close()
+}
+
+func (s *S3Storage) Get(name string) (io.ReadCloser, ObjectInfo, error) {
+	response, err := s.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, ObjectInfo{}, s3Error(response)
+	}
+
+	return response.Body, objectInfo(name, response.Header), nil
+}
+
+func (s *S3Storage) Stat(name string) (ObjectInfo, error) {
+	response, err := s.do(http.MethodHead, name, nil, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return ObjectInfo{}, s3Error(response)
+	}
+
+	response.Body.Close()
+
+	return objectInfo(name, response.Header), nil
+}
+
+func (s *S3Storage) Delete(name string) error {
+	response, err := s.do(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	// Deleting a missing key succeeds on S3 too.
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return s3Error(response)
+	}
+
+	return response.Body.Close()
+}
+
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+}
+
+// List pages through the bucket with ListObjectsV2.
+func (s *S3Storage) List() ([]ObjectInfo, error) {
+	var (
+		objects []ObjectInfo
+		token   string
+	)
+
+	for {
+		bucketURL, err := s.bucketURL("")
+		if err != nil {
+			return nil, err
+		}
+
+		query := url.Values{}
+		query.Set("list-type", "2")
+
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		bucketURL.RawQuery = s3CanonicalQuery(query)
+
+		response, err := s.send(http.MethodGet, bucketURL, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			return nil, s3Error(response)
+		}
+
+		var result s3ListResult
+
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, ObjectInfo{
+				Name:         content.Key,
+				Size:         content.Size,
+				ContentType:  contentTypeOf(content.Key),
+				LastModified: content.LastModified,
+				ETag:         content.ETag,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+// SignedURL returns a presigned GET URL, valid for at most seven days.
+func (s *S3Storage) SignedURL(name string, expires time.Duration) (string, error) {
+	objectURL, err := s.objectURL(name)
+	if err != nil {
+		return "", err
+	}
+
+	expires = min(expires, s3MaxSignedExpires)
+	now := s.time()
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		s3EscapePath(objectURL.Path),
+		s3CanonicalQuery(query),
+		"host:" + objectURL.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(now, canonicalRequest))
+	objectURL.RawQuery = s3CanonicalQuery(query)
+
+	return objectURL.String(), nil
+}
+
+func (s *S3Storage) scope(now time.Time) string {
+	return now.Format(s3ShortTimeFormat) + "/" + s.Region + "/s3/aws4_request"
+}
+
+// sign adds the Authorization header of Signature Version 4, covering the
+// host, x-amz-* and content-type headers.
+func (s *S3Storage) sign(request *http.Request, payloadHash string) {
+	now := s.time()
+
+	request.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+
+	for key, values := range request.Header {
+		lowerKey := strings.ToLower(key)
+		if strings.HasPrefix(lowerKey, "x-amz-") || lowerKey == "content-type" {
+			headers[lowerKey] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3EscapePath(request.URL.Path),
+		s3CanonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm,
+		s.AccessKey,
+		s.scope(now),
+		signedHeaders,
+		s.signature(now, canonicalRequest),
+	))
+}
+
+func (s *S3Storage) signature(now time.Time, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		s.scope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.Format(s3ShortTimeFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything except the unreserved characters of
+// RFC 3986, as Signature Version 4 requires.
+func s3Escape(value string) string {
+	var escaped strings.Builder
+
+	for _, b := range []byte(value) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+
+	return escaped.String()
+}
+
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for index, segment := range segments {
+		segments[index] = s3Escape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var pairs []string
+
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
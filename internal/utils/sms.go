@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers a text message to a mobile number.
+type SMSSender interface {
+	Send(phoneNumber string, message string) error
+}
+
+// DevSMSSender writes messages to the console, or appends them to a file
+// when FilePath is set, so OTP codes can be read during local development.
+type DevSMSSender struct {
+	FilePath string
+
+	mu sync.Mutex
+}
+
+func (d *DevSMSSender) Send(phoneNumber string, message string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var out io.Writer = os.Stdout
+
+	if d.FilePath != "" {
+		file, err := os.OpenFile(d.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		out = file
+	}
+
+	_, err := fmt.Fprintf(
+		out,
+		"[SMS %s] to=%s %s\n",
+		time.Now().Format(time.RFC3339),
+		phoneNumber,
+		message,
+	)
+
+	return err
+}
+
+func NewSMSSender() SMSSender {
+	return &DevSMSSender{FilePath: os.Getenv("SMS_OUTPUT_FILE")}
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrObjectNotFound  = errors.New("file not found")
+	ErrInvalidFileName = errors.New("invalid file name")
+)
+
+// ObjectInfo describes a stored file.
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+	ETag         string
+}
+
+// Storage keeps uploaded files. Names are flat, like the ones UploadImage
+// generates; they never contain a directory.
+type Storage interface {
+	Put(name string, content []byte, contentType string) error
+	// Get returns the file content; callers must close it. For LocalStorage
+	// it is an *os.File and can be seeked.
+	Get(name string) (io.ReadCloser, ObjectInfo, error)
+	Stat(name string) (ObjectInfo, error)
+	Delete(name string) error
+	List() ([]ObjectInfo, error)
+	// SignedURL returns a URL that gives read access to the file until
+	// expires has passed.
+	SignedURL(name string, expires time.Duration) (string, error)
+}
+
+func checkFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return ErrInvalidFileName
+	}
+
+	return nil
+}
+
+func contentTypeOf(name string) string {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+
+	return contentType
+}
+
+// LocalStorage keeps files in a directory on the server. Its signed URLs
+// point at the /files handler, which checks them with VerifyFileSignature.
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+func (l *LocalStorage) path(name string) (string, error) {
+	err := checkFileName(name)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.Dir, name), nil
+}
+
+func (l *LocalStorage) Put(name string, content []byte, contentType string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(l.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Write under a temporary name first so readers never see half a file.
+	tmp := path + ".tmp"
+
+	err = os.WriteFile(tmp, content, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (l *LocalStorage) Get(name string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := l.Stat(name)
+	if err != nil {
+		return nil, info, err
+	}
+
+	path, _ := l.path(name)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, info, err
+	}
+
+	return file, info, nil
+}
+
+func (l *LocalStorage) Stat(name string) (ObjectInfo, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	stat, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Name:         name,
+		Size:         stat.Size(),
+		ContentType:  contentTypeOf(name),
+		LastModified: stat.ModTime(),
+		ETag:         fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()),
+	}, nil
+}
+
+func (l *LocalStorage) Delete(name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (l *LocalStorage) List() ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+
+		info, err := l.Stat(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, info)
+	}
+
+	return objects, nil
+}
+
+// FileURL is the public address of an uploaded file, served by the API
+// under /files whichever storage keeps it.
+func FileURL(name string) string {
+	return strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/") + "/files/" + url.PathEscape(name)
+}
+
+func (l *LocalStorage) SignedURL(name string, expires time.Duration) (string, error) {
+	err := checkFileName(name)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expiresAt)
+	query.Set("signature", fileSignature(name, expiresAt))
+
+	return strings.TrimSuffix(l.BaseURL, "/") + "/files/" + url.PathEscape(name) + "?" + query.Encode(), nil
+}
+
+func fileSignature(name string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SECRET")))
+	mac.Write([]byte(name + "\n" + expires))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyFileSignature checks the expires and signature query parameters of a
+// URL made by LocalStorage.SignedURL.
+func VerifyFileSignature(name string, expires string, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(fileSignature(name, expires)))
+}
+
+// NewStorage picks the backend from STORAGE: "s3" for S3Storage configured
+// by the S3_* variables, anything else for LocalStorage in UPLOAD_DIR.
+func NewStorage() Storage {
+	if os.Getenv("STORAGE") == "s3" {
+		return &S3Storage{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    DefaultInput(os.Getenv("S3_REGION"), "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
+			Client:    &http.Client{Timeout: s3Timeout},
+		}
+	}
+
+	return &LocalStorage{
+		Dir:     DefaultInput(os.Getenv("UPLOAD_DIR"), UploadDir),
+		BaseURL: os.Getenv("PUBLIC_URL"),
+	}
+}
@@ -5,13 +5,18 @@ import "github.com/golang-jwt/jwt/v5"
 type AuthClaims struct {
 	jwt.RegisteredClaims
 
-	ID      string `json:"userId"`
-	Email   string `json:"email"`
-	IsAdmin bool   `json:"isAdmin"`
+	ID          string `json:"userId"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	IsAdmin     bool   `json:"isAdmin"`
+	// SessionVersion is the session_version of the user at sign in.
+	SessionVersion int32 `json:"sessionVersion"`
 }
 
 type User struct {
-	ID      string
-	Email   string
-	IsAdmin bool
+	ID             string
+	Email          string
+	PhoneNumber    string
+	IsAdmin        bool
+	SessionVersion int32
 }
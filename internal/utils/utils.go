@@ -3,8 +3,9 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
+	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -132,53 +133,58 @@ func DecodeBody[T any](r *http.Request, w http.ResponseWriter) (T, error) {
 	return t, nil
 }
 
-func Uploader(w http.ResponseWriter, r *http.Request) error {
-	// Limit upload size (e.g. 10MB)
-	r.ParseMultipartForm(10 << 20) // 10 MB
+const (
+	UploadDir     = "./uploads"
+	maxUploadSize = 10 << 20 // 10 MB
+)
 
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
+// VariantFileName names a resized variant stored next to its original, e.g.
+// "aB3dE9xZ_thumbnail.webp" for "aB3dE9xZ.jpg".
+func VariantFileName(fileName string, variant string, ext string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_" + variant + ext
+}
 
-		return err
-	}
-	defer file.Close()
+// ReadUpload reads the "file" field of a multipart upload of at most
+// 10 MB and returns its content and original file name.
+func ReadUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 
-	randomString, err := RandomString(8)
+	err := r.ParseMultipartForm(maxUploadSize)
 	if err != nil {
-		return err
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return nil, "", err
 	}
-	// Create destination file
-	ext := filepath.Ext(handler.Filename)
-	fileName := fmt.Sprintf("./uploads/%s%s", randomString, ext)
 
-	dst, err := os.Create(fileName)
+	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "Unable to create file", http.StatusInternalServerError)
+		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
 
-		return err
+		return nil, "", err
 	}
-	defer dst.Close()
+	defer file.Close()
 
-	// Copy uploaded content to destination
-	_, err = io.Copy(dst, file)
+	data, err := io.ReadAll(file)
 	if err != nil {
-		http.Error(w, "Error saving file", http.StatusInternalServerError)
+		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
 
-		return err
+		return nil, "", err
 	}
 
-	fmt.Fprint(w, strings.Split(fileName, "/")[2])
-
-	return nil
+	return data, header.Filename, nil
 }
 
-func GetUserFromRequest(w http.ResponseWriter, r *http.Request) User {
+var (
+	ErrMissingToken = errors.New("missing authorization token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// ParseUserFromRequest reads the user from the "token" cookie without writing
+// to the response.
+func ParseUserFromRequest(r *http.Request) (User, error) {
 	AuthCookie, err := r.Cookie("token")
 	if err != nil {
-		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
-
-		return User{}
+		return User{}, ErrMissingToken
 	}
 
 	tokenString := AuthCookie.Value
@@ -194,31 +200,98 @@ func GetUserFromRequest(w http.ResponseWriter, r *http.Request) User {
 		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
 	)
 	if err != nil || !token.Valid {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return User{}, ErrInvalidToken
+	}
+
+	user := &User{
+		ID:             claims.ID,
+		Email:          claims.Email,
+		PhoneNumber:    claims.PhoneNumber,
+		IsAdmin:        claims.IsAdmin,
+		SessionVersion: claims.SessionVersion,
+	}
+
+	return *user, nil
+}
+
+func GetUserFromRequest(w http.ResponseWriter, r *http.Request) User {
+	user, err := ParseUserFromRequest(r)
+	if errors.Is(err, ErrMissingToken) {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
 
 		return User{}
 	}
 
-	user := &User{
-		ID:      claims.ID,
-		Email:   claims.Email,
-		IsAdmin: claims.IsAdmin,
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+
+		return User{}
 	}
 
-	return *user
+	return user
 }
 
+// ReplacePersianDigits writes Persian and Arabic-Indic digits, as typed on
+// Persian and Arabic keyboards, as ASCII digits.
 func ReplacePersianDigits(s string) string {
 	persian := []rune("۰۱۲۳۴۵۶۷۸۹")
+	arabic := []rune("٠١٢٣٤٥٦٧٨٩")
 
 	english := []rune("0123456789")
 	for i, p := range persian {
 		s = strings.ReplaceAll(s, string(p), string(english[i]))
+		s = strings.ReplaceAll(s, string(arabic[i]), string(english[i]))
 	}
 
 	return s
 }
 
+// NormalizePhoneNumber converts an Iranian mobile number written with Persian
+// digits, spaces or a +98/0098 prefix to the 09XXXXXXXXX form stored in the
+// database. The second return value is false when the input is not a mobile number.
+func NormalizePhoneNumber(phoneNumber string) (string, bool) {
+	phoneNumber = ReplacePersianDigits(phoneNumber)
+	phoneNumber = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(phoneNumber)
+
+	switch {
+	case strings.HasPrefix(phoneNumber, "+98"):
+		phoneNumber = "0" + strings.TrimPrefix(phoneNumber, "+98")
+	case strings.HasPrefix(phoneNumber, "0098"):
+		phoneNumber = "0" + strings.TrimPrefix(phoneNumber, "0098")
+	case strings.HasPrefix(phoneNumber, "9") && len(phoneNumber) == 10:
+		phoneNumber = "0" + phoneNumber
+	}
+
+	if len(phoneNumber) != 11 || !strings.HasPrefix(phoneNumber, "09") {
+		return "", false
+	}
+
+	for _, r := range phoneNumber {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+	}
+
+	return phoneNumber, true
+}
+
+// RandomDigits returns a numeric code of the given length, e.g. for OTPs,
+// with every digit equally likely.
+func RandomDigits(length int) (string, error) {
+	digits := make([]byte, length)
+
+	for i := range digits {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+
+		digits[i] = '0' + byte(digit.Int64())
+	}
+
+	return string(digits), nil
+}
+
 func DefaultInput(input string, defaultOutput string) string {
 	if input == "" {
 		return defaultOutput
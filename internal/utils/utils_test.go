@@ -0,0 +1,26 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	cases := map[string]string{
+		"09121234567":      "09121234567",
+		"۰۹۱۲۱۲۳۴۵۶۷":      "09121234567",
+		"+98 912 123 4567": "09121234567",
+		"00989121234567":   "09121234567",
+		"9121234567":       "09121234567",
+	}
+
+	for input, want := range cases {
+		got, ok := NormalizePhoneNumber(input)
+		if !ok || got != want {
+			t.Errorf("NormalizePhoneNumber(%q) = %q, %v; want %q", input, got, ok, want)
+		}
+	}
+
+	for _, input := range []string{"", "02112345678", "0912123456", "0912abc4567"} {
+		if _, ok := NormalizePhoneNumber(input); ok {
+			t.Errorf("NormalizePhoneNumber(%q) accepted an invalid number", input)
+		}
+	}
+}